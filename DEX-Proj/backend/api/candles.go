@@ -0,0 +1,148 @@
+package api
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// K 线数量默认值和上限
+const (
+	DefaultCandleLimit = 200
+	MaxCandleLimit     = 1000
+)
+
+// CandleIntervals sync 服务维护的 K 线周期
+var CandleIntervals = []string{"1m", "5m", "1h", "1d"}
+
+// Candle 一根 OHLCV K 线
+type Candle struct {
+	Time             time.Time `json:"time"` // K 线开始时间（UTC）
+	Open             string    `json:"open"`
+	High             string    `json:"high"`
+	Low              string    `json:"low"`
+	Close            string    `json:"close"`
+	Volume0          string    `json:"volume0"` // token0 成交量（最小单位，买卖两个方向之和）
+	Volume1          string    `json:"volume1"` // token1 成交量（最小单位）
+	Volume0Formatted string    `json:"volume0Formatted,omitempty"`
+	Volume1Formatted string    `json:"volume1Formatted,omitempty"`
+	SwapCount        int64     `json:"swapCount"`
+}
+
+// CandleSeries 池子某个周期的 K 线
+// 价格为 1 个 BaseToken 可兑换的 QuoteToken 数量（已按精度调整）；默认 base 为 token0，Inverted 时 base 为 token1
+type CandleSeries struct {
+	Pool       string    `json:"pool"`
+	Interval   string    `json:"interval"`
+	Inverted   bool      `json:"inverted"`
+	BaseToken  TokenInfo `json:"baseToken"`
+	QuoteToken TokenInfo `json:"quoteToken"`
+	Candles    []Candle  `json:"candles"` // 按时间升序
+}
+
+// CandleQuery K 线查询条件
+type CandleQuery struct {
+	Interval string
+	From     *time.Time // K 线开始时间下限（含）
+	To       *time.Time // K 线开始时间上限（含）
+	Limit    int        // 返回时间范围内最近的 Limit 根
+	Invert   bool       // 以 token1 为 base 计价
+}
+
+// IsCandleInterval 判断是否为支持的 K 线周期
+func IsCandleInterval(interval string) bool {
+	for _, iv := range CandleIntervals {
+		if iv == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// ListCandles 查询池子的 K 线，返回时间范围内最近的 query.Limit 根，按时间升序
+func (q *Quote) ListCandles(poolAddress string, query CandleQuery) (*CandleSeries, error) {
+	pool, err := q.GetPool(poolAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	var where sqlFilter
+	where.add("pool_address = %s", pool.Address)
+	where.add("period = %s", query.Interval)
+	if query.From != nil {
+		where.add("bucket_start >= %s", *query.From)
+	}
+	if query.To != nil {
+		where.add("bucket_start <= %s", *query.To)
+	}
+
+	rows, err := q.db.Query(`
+		SELECT bucket_start, CAST(open AS TEXT), CAST(high AS TEXT), CAST(low AS TEXT), CAST(close AS TEXT),
+			CAST(volume0 AS TEXT), CAST(volume1 AS TEXT), swap_count
+		FROM candles`+where.clause()+fmt.Sprintf(" ORDER BY bucket_start DESC LIMIT %d", query.Limit), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询 K 线失败: %w", err)
+	}
+	defer rows.Close()
+
+	candles := []Candle{}
+	for rows.Next() {
+		var candle Candle
+		var open, high, low, close string
+		if err := rows.Scan(&candle.Time, &open, &high, &low, &close,
+			&candle.Volume0, &candle.Volume1, &candle.SwapCount); err != nil {
+			return nil, err
+		}
+		if err := setCandlePrices(&candle, open, high, low, close, query.Invert); err != nil {
+			return nil, err
+		}
+		candle.Time = candle.Time.UTC()
+		candle.Volume0Formatted = formatAmount(candle.Volume0, pool.Token0.Decimals)
+		candle.Volume1Formatted = formatAmount(candle.Volume1, pool.Token1.Decimals)
+		candles = append(candles, candle)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 查询按时间倒序取最近的 K 线，返回时改为升序
+	for i, j := 0, len(candles)-1; i < j; i, j = i+1, j-1 {
+		candles[i], candles[j] = candles[j], candles[i]
+	}
+
+	series := &CandleSeries{
+		Pool:       pool.Address,
+		Interval:   query.Interval,
+		Inverted:   query.Invert,
+		BaseToken:  pool.Token0,
+		QuoteToken: pool.Token1,
+		Candles:    candles,
+	}
+	if query.Invert {
+		series.BaseToken, series.QuoteToken = pool.Token1, pool.Token0
+	}
+	return series, nil
+}
+
+// setCandlePrices 格式化开高低收价格；反向计价时取倒数，最高价和最低价互换
+func setCandlePrices(candle *Candle, open, high, low, close string, invert bool) error {
+	prices := make([]*big.Float, 4)
+	for i, raw := range []string{open, high, low, close} {
+		price, ok := new(big.Float).SetPrec(256).SetString(raw)
+		if !ok {
+			return fmt.Errorf("K 线价格无法解析: %s", raw)
+		}
+		if invert && price.Sign() != 0 {
+			price.Quo(new(big.Float).SetPrec(256).SetInt64(1), price)
+		}
+		prices[i] = price
+	}
+	if invert {
+		prices[1], prices[2] = prices[2], prices[1]
+	}
+	candle.Open = formatPrice(prices[0])
+	candle.High = formatPrice(prices[1])
+	candle.Low = formatPrice(prices[2])
+	candle.Close = formatPrice(prices[3])
+	return nil
+}
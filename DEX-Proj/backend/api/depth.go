@@ -0,0 +1,321 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"dex-bot/pkg/swapmath"
+)
+
+const (
+	// MaxDepthSizes 滑点曲线最多的输入金额档位数
+	MaxDepthSizes = 20
+)
+
+var (
+	errTokenNotInPool = errors.New("代币不属于该池子")
+	errNoPairPools    = errors.New("交易对没有可用的池子")
+)
+
+// defaultDepthLadder 未指定输入金额时，按所有池子可成交的最大输入（capacity）的百分比生成档位
+var defaultDepthLadder = []int64{1, 2, 5, 10, 25, 50, 75, 100}
+
+// DepthResult 流动性分布和滑点曲线
+type DepthResult struct {
+	Token0        TokenInfo       `json:"token0"`        // 价格区间的价格以 token1/token0 表示
+	Token1        TokenInfo       `json:"token1"`        // token1
+	Pools         []DepthPool     `json:"pools"`         // 参与计算的池子
+	Bands         []LiquidityBand `json:"bands"`         // 按 tick 升序排列的价格区间，只包含有流动性的区间
+	TokenIn       string          `json:"tokenIn"`       // 滑点曲线的输入代币
+	TokenOut      string          `json:"tokenOut"`      // 滑点曲线的输出代币
+	Capacity      string          `json:"capacity"`      // 所有池子到达价格区间边界前最多可成交的输入金额（含手续费）
+	SlippageCurve []SlippagePoint `json:"slippageCurve"` // 各档输入金额的报价
+}
+
+// DepthPool 参与深度计算的池子
+type DepthPool struct {
+	Address   string `json:"address"`
+	PoolIndex *int64 `json:"poolIndex"`
+	Fee       int64  `json:"fee"`
+	Tick      int64  `json:"tick"`
+	Price     string `json:"price"` // 当前价格 token1/token0（按精度调整）
+	Liquidity string `json:"liquidity"`
+}
+
+// LiquidityBand 相邻两个已初始化 tick 之间的价格区间
+type LiquidityBand struct {
+	TickLower  int64  `json:"tickLower"`
+	TickUpper  int64  `json:"tickUpper"`
+	PriceLower string `json:"priceLower"` // tickLower 对应的价格 token1/token0（按精度调整）
+	PriceUpper string `json:"priceUpper"` // tickUpper 对应的价格
+	Liquidity  string `json:"liquidity"`  // 区间内的活跃流动性（所有池子之和）
+	Amount0    string `json:"amount0"`    // 按各池子当前价格，区间内锁定的 token0 数量（最小单位）
+	Amount1    string `json:"amount1"`    // 区间内锁定的 token1 数量（最小单位）
+}
+
+// SlippagePoint 滑点曲线上的一档
+type SlippagePoint struct {
+	AmountIn           string  `json:"amountIn"`
+	AmountInFormatted  string  `json:"amountInFormatted,omitempty"`
+	AmountOut          string  `json:"amountOut"`
+	AmountOutFormatted string  `json:"amountOutFormatted,omitempty"`
+	ExecutionPrice     string  `json:"executionPrice"` // tokenOut/tokenIn（按精度调整）
+	PriceImpact        float64 `json:"priceImpact"`    // 成交均价相对中间价变差的百分比
+	MidPriceImpact     float64 `json:"midPriceImpact"` // 中间价变差的百分比
+	Filled             bool    `json:"filled"`         // 输入全部成交，没有池子价格区间耗尽后剩余的输入
+}
+
+// PoolDepth 计算单个池子的流动性分布和滑点曲线，tokenIn 为空时以 token0 为输入
+func (q *Quote) PoolDepth(address, tokenIn string, sizes []*big.Int) (*DepthResult, error) {
+	pool, err := q.GetPoolState(address)
+	if err != nil {
+		return nil, err
+	}
+	if tokenIn == "" {
+		tokenIn = pool.Token0
+	}
+	if !strings.EqualFold(tokenIn, pool.Token0) && !strings.EqualFold(tokenIn, pool.Token1) {
+		return nil, fmt.Errorf("%w: %s", errTokenNotInPool, tokenIn)
+	}
+	return q.depth([]*PoolState{pool}, tokenIn, sizes)
+}
+
+// PairDepth 汇总交易对所有有流动性的池子，tokenIn 为滑点曲线的输入代币
+func (q *Quote) PairDepth(tokenIn, tokenOut string, sizes []*big.Int) (*DepthResult, error) {
+	if strings.EqualFold(tokenIn, tokenOut) {
+		return nil, fmt.Errorf("输入代币和输出代币不能相同")
+	}
+	all, err := q.loadRoutablePools()
+	if err != nil {
+		return nil, fmt.Errorf("加载池子失败: %w", err)
+	}
+
+	key := pairKey(tokenIn, tokenOut)
+	var pools []*PoolState
+	for _, pool := range all {
+		if pairKey(pool.Token0, pool.Token1) == key {
+			pools = append(pools, pool)
+		}
+	}
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("%w: %s/%s", errNoPairPools, tokenIn, tokenOut)
+	}
+	return q.depth(pools, tokenIn, sizes)
+}
+
+// depth 计算同一交易对若干池子的流动性分布和滑点曲线；sizes 为空时按 capacity 的百分比生成档位
+func (q *Quote) depth(pools []*PoolState, tokenIn string, sizes []*big.Int) (*DepthResult, error) {
+	first := pools[0]
+	tokenOut := first.Token0
+	if strings.EqualFold(tokenIn, first.Token0) {
+		tokenOut = first.Token1
+	}
+
+	result := &DepthResult{
+		Token0:   q.depthTokenInfo(first.Token0),
+		Token1:   q.depthTokenInfo(first.Token1),
+		TokenIn:  tokenIn,
+		TokenOut: tokenOut,
+	}
+
+	for _, pool := range pools {
+		result.Pools = append(result.Pools, DepthPool{
+			Address:   pool.Address,
+			PoolIndex: pool.PoolIndex,
+			Fee:       pool.Fee,
+			Tick:      pool.Tick,
+			Price:     formatPrice(midPrice(pool, pool.SqrtPriceX96, true)),
+			Liquidity: pool.Liquidity.String(),
+		})
+	}
+
+	bands, err := q.liquidityBands(pools)
+	if err != nil {
+		return nil, err
+	}
+	result.Bands = bands
+
+	zeroForOne := strings.EqualFold(tokenIn, first.Token0)
+	capacity := big.NewInt(0)
+	for _, pool := range pools {
+		if max := q.poolInputCapacity(pool, zeroForOne); max != nil {
+			capacity.Add(capacity, max)
+		}
+	}
+	result.Capacity = capacity.String()
+
+	if len(sizes) == 0 {
+		for _, percent := range defaultDepthLadder {
+			size := new(big.Int).Div(new(big.Int).Mul(capacity, big.NewInt(percent)), big.NewInt(100))
+			if size.Sign() > 0 {
+				sizes = append(sizes, size)
+			}
+		}
+	}
+
+	decimalsIn, decimalsOut := first.tokenDecimals(tokenIn), first.tokenDecimals(tokenOut)
+	for _, size := range sizes {
+		point := SlippagePoint{
+			AmountIn:          size.String(),
+			AmountInFormatted: formatUnits(size, decimalsIn),
+			AmountOut:         "0",
+		}
+		if hop := q.splitHop(pools, tokenIn, tokenOut, size); hop != nil {
+			point.AmountOut = hop.AmountOut
+			point.ExecutionPrice = hop.ExecutionPrice
+			point.PriceImpact = hop.PriceImpact
+			point.MidPriceImpact = hop.MidPriceImpact
+			point.Filled = !hop.PartialFill
+		}
+		point.AmountOutFormatted = formatAmount(point.AmountOut, &decimalsOut)
+		result.SlippageCurve = append(result.SlippageCurve, point)
+	}
+
+	return result, nil
+}
+
+// depthTokenInfo 查询代币信息，tokens 表中没有记录时只返回地址
+func (q *Quote) depthTokenInfo(address string) TokenInfo {
+	token, err := q.ResolveToken(address)
+	if err != nil {
+		return TokenInfo{Address: address}
+	}
+	return *token
+}
+
+// poolInputCapacity 返回池子价格到达交易方向的区间边界前最多可消耗的输入（含手续费），无法成交时返回 nil
+func (q *Quote) poolInputCapacity(pool *PoolState, zeroForOne bool) *big.Int {
+	// 足够大的输入一定会把价格推到区间边界，computeSwapStep 返回的就是到达边界所需的输入
+	unlimited := new(big.Int).Lsh(big.NewInt(1), 200)
+	result, err := q.swapInPool(pool, unlimited, zeroForOne, nil)
+	if err != nil {
+		return nil
+	}
+	return result.AmountIn
+}
+
+// liquidityBands 按 tick 遍历各池子的 liquidity_net，返回相邻已初始化 tick 之间的活跃流动性
+// ticks 表中没有记录的池子按其固定价格区间 [tick_lower, tick_upper] 和当前流动性计算
+func (q *Quote) liquidityBands(pools []*PoolState) ([]LiquidityBand, error) {
+	type poolTicks struct {
+		pool  *PoolState
+		ticks []TickInfo
+	}
+
+	var all []poolTicks
+	boundaries := make(map[int64]bool)
+	for _, pool := range pools {
+		ticks, err := q.GetTicksInRange(pool.Address, swapmath.MinTick, swapmath.MaxTick)
+		if err != nil {
+			return nil, fmt.Errorf("查询池子 %s 的 ticks 失败: %w", pool.Address, err)
+		}
+		if len(ticks) == 0 && pool.Liquidity.Sign() > 0 {
+			ticks = []TickInfo{
+				{TickIndex: pool.TickLower, LiquidityNet: pool.Liquidity},
+				{TickIndex: pool.TickUpper, LiquidityNet: new(big.Int).Neg(pool.Liquidity)},
+			}
+		}
+		for _, tick := range ticks {
+			boundaries[tick.TickIndex] = true
+		}
+		all = append(all, poolTicks{pool: pool, ticks: ticks})
+	}
+
+	sorted := make([]int64, 0, len(boundaries))
+	for tick := range boundaries {
+		sorted = append(sorted, tick)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// active[i] 为第 i 个池子在当前区间的活跃流动性，next[i] 为该池子下一个未处理的 tick
+	active := make([]*big.Int, len(all))
+	next := make([]int, len(all))
+	for i := range active {
+		active[i] = big.NewInt(0)
+	}
+
+	var bands []LiquidityBand
+	for b := 0; b+1 < len(sorted); b++ {
+		lower, upper := sorted[b], sorted[b+1]
+		for i, pt := range all {
+			for next[i] < len(pt.ticks) && pt.ticks[next[i]].TickIndex <= lower {
+				active[i].Add(active[i], pt.ticks[next[i]].LiquidityNet)
+				next[i]++
+			}
+		}
+
+		band, err := newLiquidityBand(pools[0], lower, upper)
+		if err != nil {
+			return nil, err
+		}
+		liquidity, amount0, amount1 := big.NewInt(0), big.NewInt(0), big.NewInt(0)
+		for i, pt := range all {
+			if active[i].Sign() <= 0 {
+				continue
+			}
+			liquidity.Add(liquidity, active[i])
+			a0, a1, err := bandAmounts(pt.pool.SqrtPriceX96, lower, upper, active[i])
+			if err != nil {
+				return nil, err
+			}
+			amount0.Add(amount0, a0)
+			amount1.Add(amount1, a1)
+		}
+		if liquidity.Sign() == 0 {
+			continue
+		}
+		band.Liquidity, band.Amount0, band.Amount1 = liquidity.String(), amount0.String(), amount1.String()
+		bands = append(bands, *band)
+	}
+	return bands, nil
+}
+
+// newLiquidityBand 创建价格区间并计算两端的价格
+func newLiquidityBand(pool *PoolState, tickLower, tickUpper int64) (*LiquidityBand, error) {
+	sqrtLower, err := swapmath.GetSqrtPriceAtTick(tickLower)
+	if err != nil {
+		return nil, err
+	}
+	sqrtUpper, err := swapmath.GetSqrtPriceAtTick(tickUpper)
+	if err != nil {
+		return nil, err
+	}
+	return &LiquidityBand{
+		TickLower:  tickLower,
+		TickUpper:  tickUpper,
+		PriceLower: formatPrice(midPrice(pool, sqrtLower, true)),
+		PriceUpper: formatPrice(midPrice(pool, sqrtUpper, true)),
+	}, nil
+}
+
+// bandAmounts 返回流动性 liquidity 在 [tickLower, tickUpper] 内、池子价格为 sqrtPriceX96 时锁定的 token0 和 token1 数量
+// 价格低于区间时全部为 token0，高于区间时全部为 token1，位于区间内时两者都有（与 Pool.mint 的计算相同，向下取整）
+func bandAmounts(sqrtPriceX96 *big.Int, tickLower, tickUpper int64, liquidity *big.Int) (*big.Int, *big.Int, error) {
+	sqrtLower, err := swapmath.GetSqrtPriceAtTick(tickLower)
+	if err != nil {
+		return nil, nil, err
+	}
+	sqrtUpper, err := swapmath.GetSqrtPriceAtTick(tickUpper)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case sqrtPriceX96.Cmp(sqrtLower) <= 0:
+		amount0, err := swapmath.GetAmount0Delta(sqrtLower, sqrtUpper, liquidity, false)
+		return amount0, big.NewInt(0), err
+	case sqrtPriceX96.Cmp(sqrtUpper) >= 0:
+		amount1, err := swapmath.GetAmount1Delta(sqrtLower, sqrtUpper, liquidity, false)
+		return big.NewInt(0), amount1, err
+	default:
+		amount0, err := swapmath.GetAmount0Delta(sqrtPriceX96, sqrtUpper, liquidity, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		amount1, err := swapmath.GetAmount1Delta(sqrtLower, sqrtPriceX96, liquidity, false)
+		return amount0, amount1, err
+	}
+}
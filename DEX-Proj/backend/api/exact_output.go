@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+)
+
+// 报价类型
+const (
+	TradeTypeExactInput  = "EXACT_INPUT"  // 精确输入：给定 amountIn，计算 amountOut
+	TradeTypeExactOutput = "EXACT_OUTPUT" // 精确输出：给定 amountOut，计算所需的 amountIn
+)
+
+// CalculateQuoteExactOutput 使用Uniswap V3模型计算精确输出报价
+// 对应链上的 SwapRouter.quoteExactOutput：给定想要得到的 amountOut，返回需要支付的 amountIn（含手续费）
+// sqrtPriceLimitX96 与 Pool.swap 的同名参数相同，为 nil 时只受池子价格区间限制
+func (q *Quote) CalculateQuoteExactOutput(poolAddress, tokenIn, amountOut string, sqrtPriceLimitX96 *big.Int) (*QuoteResult, error) {
+	// 获取池子状态
+	poolState, err := q.GetPoolState(poolAddress)
+	if err != nil {
+		return nil, fmt.Errorf("获取池子状态失败: %w", err)
+	}
+
+	// 检查池子状态
+	if poolState.Liquidity.Cmp(big.NewInt(0)) == 0 {
+		return nil, fmt.Errorf("池子流动性为0，无法进行交易")
+	}
+	if poolState.SqrtPriceX96.Cmp(big.NewInt(0)) == 0 {
+		return nil, fmt.Errorf("池子价格为0，无法进行交易")
+	}
+
+	// 解析输出金额
+	amountOutBig, ok := new(big.Int).SetString(amountOut, 10)
+	if !ok {
+		return nil, fmt.Errorf("无效的输出金额: %s", amountOut)
+	}
+
+	if amountOutBig.Cmp(big.NewInt(0)) <= 0 {
+		return nil, fmt.Errorf("输出金额必须大于0")
+	}
+
+	return q.quoteExactOutputInPool(poolState, tokenIn, amountOutBig, sqrtPriceLimitX96)
+}
+
+// quoteExactOutputInPool 在给定的池子状态上计算精确输出报价
+func (q *Quote) quoteExactOutputInPool(poolState *PoolState, tokenIn string, amountOutBig, sqrtPriceLimitX96 *big.Int) (*QuoteResult, error) {
+	log.Printf("[QuoteExactOutput] Pool State: Address=%s, Token0=%s, Token1=%s, Fee=%d, Liquidity=%s, SqrtPriceX96=%s, Tick=%d, Range=[%d, %d]",
+		poolState.Address, poolState.Token0, poolState.Token1, poolState.Fee,
+		poolState.Liquidity.String(), poolState.SqrtPriceX96.String(), poolState.Tick,
+		poolState.TickLower, poolState.TickUpper)
+
+	log.Printf("[QuoteExactOutput] Input: tokenIn=%s, amountOut=%s", tokenIn, amountOutBig.String())
+
+	// 判断交易方向
+	isToken0 := strings.ToLower(tokenIn) == strings.ToLower(poolState.Token0)
+
+	// 执行swap计算：computeSwapStep 按精确输出计算所需输入，输入金额已包含手续费
+	result, err := q.swapExactOutput(poolState, amountOutBig, isToken0, sqrtPriceLimitX96)
+	if err != nil {
+		return nil, fmt.Errorf("swap计算失败: %w", err)
+	}
+
+	log.Printf("[QuoteExactOutput] Swap Result: amountIn=%s, feeAmount=%s, newTick=%d",
+		result.AmountIn.String(), result.FeeAmount.String(), result.NewTick)
+
+	quote := &QuoteResult{
+		AmountOut:       amountOutBig.String(),
+		AmountIn:        result.AmountIn.String(),
+		NewSqrtPriceX96: result.NewSqrtPriceX96.String(),
+		NewTick:         result.NewTick,
+		CrossedTicks:    result.CrossedTicks,
+	}
+	// 精确输出要么全部成交，要么返回错误
+	quote.setFill(poolState, isToken0, result.AmountIn, result.AmountIn, result.NewSqrtPriceX96)
+	// 计算交易前后的中间价、成交均价和价格影响
+	quote.setPrices(poolState, isToken0, result.AmountIn, amountOutBig, result.NewSqrtPriceX96)
+	return quote, nil
+}
+
+// swapExactOutput 执行精确输出的swap计算
+//
+// 与 swapExactInput 相同，只执行一次 computeSwapStep，amountSpecified 取 -amountOut（对应 Pool.swap 的精确输出）。
+// 池子价格到达价格区间边界（或价格限制）时仍无法输出 amountOut，说明该池子无法满足请求，返回错误
+func (q *Quote) swapExactOutput(
+	poolState *PoolState,
+	amountOut *big.Int,
+	zeroForOne bool, // true: token0 -> token1, false: token1 -> token0
+	sqrtPriceLimitX96 *big.Int, // 价格限制，为 nil 时只受池子价格区间限制
+) (*SwapResult, error) {
+	result, err := q.swapInPool(poolState, new(big.Int).Neg(amountOut), zeroForOne, sqrtPriceLimitX96)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.AmountOut.Cmp(amountOut) < 0 {
+		return nil, fmt.Errorf("池子价格区间 [%d, %d] 内（或价格限制内）的流动性不足以输出 %s，最多可输出 %s",
+			poolState.TickLower, poolState.TickUpper, amountOut.String(), result.AmountOut.String())
+	}
+
+	return result, nil
+}
@@ -0,0 +1,463 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// 分页参数默认值和上限
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Pagination 分页信息
+type Pagination struct {
+	Total      int64 `json:"total"`      // 符合条件的总条数
+	Page       int   `json:"page"`       // 当前页码（从1开始）
+	Limit      int   `json:"limit"`      // 每页数量
+	TotalPages int64 `json:"totalPages"` // 总页数
+}
+
+// PageResult 分页查询结果
+type PageResult struct {
+	Items      interface{} `json:"items"`
+	Pagination Pagination  `json:"pagination"`
+}
+
+// TokenInfo 代币信息（来自 sync 服务写入的 tokens 表）
+type TokenInfo struct {
+	Address  string `json:"address"`
+	Symbol   string `json:"symbol,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Decimals *int64 `json:"decimals,omitempty"` // 未同步到代币信息时为 null，此时不返回格式化金额
+}
+
+// PoolDetail 池子信息
+type PoolDetail struct {
+	Address           string    `json:"address"`
+	Token0            TokenInfo `json:"token0"`
+	Token1            TokenInfo `json:"token1"`
+	PoolIndex         *int64    `json:"poolIndex"` // 池子在交易对下的序号，未同步时为 null
+	Fee               int64     `json:"fee"`
+	TickLower         int64     `json:"tickLower"`
+	TickUpper         int64     `json:"tickUpper"`
+	Liquidity         string    `json:"liquidity"`
+	SqrtPriceX96      string    `json:"sqrtPriceX96"`
+	Tick              int64     `json:"tick"`
+	Price             string    `json:"price,omitempty"` // 按 decimals 调整后的价格（1 token0 = price token1）
+	Reserve0          string    `json:"reserve0"`
+	Reserve1          string    `json:"reserve1"`
+	Reserve0Formatted string    `json:"reserve0Formatted,omitempty"`
+	Reserve1Formatted string    `json:"reserve1Formatted,omitempty"`
+	CreatedBlock      *int64    `json:"createdBlock,omitempty"`
+}
+
+// SwapInfo 交易记录
+type SwapInfo struct {
+	TransactionHash  string    `json:"transactionHash"`
+	LogIndex         int64     `json:"logIndex"`
+	Sender           string    `json:"sender"`
+	Recipient        string    `json:"recipient"`
+	Amount0          string    `json:"amount0"` // 池子视角：正数为流入池子，负数为流出池子
+	Amount1          string    `json:"amount1"`
+	Amount0Formatted string    `json:"amount0Formatted,omitempty"`
+	Amount1Formatted string    `json:"amount1Formatted,omitempty"`
+	SqrtPriceX96     string    `json:"sqrtPriceX96"`
+	Liquidity        string    `json:"liquidity"`
+	Tick             int64     `json:"tick"`
+	BlockNumber      int64     `json:"blockNumber"`
+	BlockTimestamp   time.Time `json:"blockTimestamp"`
+}
+
+// LiquidityEventInfo 流动性事件记录（MINT/BURN）
+type LiquidityEventInfo struct {
+	TransactionHash  string    `json:"transactionHash"`
+	LogIndex         int64     `json:"logIndex"`
+	Type             string    `json:"type"` // MINT 或 BURN
+	Owner            string    `json:"owner"`
+	PositionID       string    `json:"positionId,omitempty"` // BURN 事件对应的 position，无法确定时为空
+	Amount           string    `json:"amount"`               // 流动性数量
+	Amount0          string    `json:"amount0"`
+	Amount1          string    `json:"amount1"`
+	Amount0Formatted string    `json:"amount0Formatted,omitempty"`
+	Amount1Formatted string    `json:"amount1Formatted,omitempty"`
+	BlockNumber      int64     `json:"blockNumber"`
+	BlockTimestamp   time.Time `json:"blockTimestamp"`
+}
+
+// PoolFilter 池子列表过滤条件
+type PoolFilter struct {
+	Token string // 交易对中包含该代币（token0 或 token1）
+	Fee   *int64 // 手续费等级
+}
+
+// SwapFilter 交易记录过滤条件
+type SwapFilter struct {
+	Account   string // sender 或 recipient
+	FromBlock *int64
+	ToBlock   *int64
+}
+
+// LiquidityEventFilter 流动性事件过滤条件
+type LiquidityEventFilter struct {
+	Type      string // MINT 或 BURN
+	Owner     string
+	FromBlock *int64
+	ToBlock   *int64
+}
+
+// poolSelect 查询池子及其两个代币的信息，与 scanPoolDetail 的字段顺序一致
+const poolSelect = `
+	SELECT p.address, p.token0, t0.symbol, t0.name, t0.decimals, p.token1, t1.symbol, t1.name, t1.decimals,
+		p.pool_index, p.fee, p.tick_lower, p.tick_upper, p.liquidity, p.sqrt_price_x96, p.tick,
+		p.reserve0, p.reserve1, p.created_block
+	FROM pools p
+	LEFT JOIN tokens t0 ON t0.address = p.token0
+	LEFT JOIN tokens t1 ON t1.address = p.token1
+`
+
+// ListPools 分页查询池子列表，按流动性降序
+func (q *Quote) ListPools(filter PoolFilter, page, limit int) (*PageResult, error) {
+	var where sqlFilter
+	if filter.Token != "" {
+		where.add("(LOWER(p.token0) = LOWER(%[1]s) OR LOWER(p.token1) = LOWER(%[1]s))", filter.Token)
+	}
+	if filter.Fee != nil {
+		where.add("p.fee = %s", *filter.Fee)
+	}
+
+	total, err := q.count("FROM pools p"+where.clause(), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询池子数量失败: %w", err)
+	}
+
+	rows, err := q.db.Query(poolSelect+where.clause()+where.page("ORDER BY p.liquidity DESC, p.address", page, limit), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询池子列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	pools := []PoolDetail{}
+	for rows.Next() {
+		pool, err := scanPoolDetail(rows)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, *pool)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPageResult(pools, total, page, limit), nil
+}
+
+// GetPool 查询单个池子，池子不存在时返回 sql.ErrNoRows
+func (q *Quote) GetPool(address string) (*PoolDetail, error) {
+	return scanPoolDetail(q.db.QueryRow(poolSelect+" WHERE LOWER(p.address) = LOWER($1)", address))
+}
+
+// ListTokens 分页查询代币列表，symbol 不为空时按 symbol 过滤（大小写不敏感）
+func (q *Quote) ListTokens(symbol string, page, limit int) (*PageResult, error) {
+	var where sqlFilter
+	if symbol != "" {
+		where.add("LOWER(symbol) = LOWER(%s)", symbol)
+	}
+
+	total, err := q.count("FROM tokens"+where.clause(), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询代币数量失败: %w", err)
+	}
+
+	rows, err := q.db.Query("SELECT address, symbol, name, decimals FROM tokens"+where.clause()+
+		where.page("ORDER BY symbol, address", page, limit), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询代币列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []TokenInfo{}
+	for rows.Next() {
+		var symbol, name sql.NullString
+		var decimals sql.NullInt64
+		var token TokenInfo
+		if err := rows.Scan(&token.Address, &symbol, &name, &decimals); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, newTokenInfo(token.Address, symbol, name, decimals))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPageResult(tokens, total, page, limit), nil
+}
+
+// ListSwaps 分页查询池子的交易记录，按区块倒序
+func (q *Quote) ListSwaps(poolAddress string, filter SwapFilter, page, limit int) (*PageResult, error) {
+	pool, err := q.GetPool(poolAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	var where sqlFilter
+	where.add("pool_address = %s", pool.Address)
+	if filter.Account != "" {
+		where.add("(LOWER(sender) = LOWER(%[1]s) OR LOWER(recipient) = LOWER(%[1]s))", filter.Account)
+	}
+	where.addBlockRange(filter.FromBlock, filter.ToBlock)
+
+	total, err := q.count("FROM swaps"+where.clause(), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询交易数量失败: %w", err)
+	}
+
+	rows, err := q.db.Query(`
+		SELECT transaction_hash, log_index, sender, recipient, amount0, amount1,
+			sqrt_price_x96, liquidity, tick, block_number, block_timestamp
+		FROM swaps`+where.clause()+where.page("ORDER BY block_number DESC, log_index DESC", page, limit), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询交易记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	swaps := []SwapInfo{}
+	for rows.Next() {
+		var swap SwapInfo
+		if err := rows.Scan(&swap.TransactionHash, &swap.LogIndex, &swap.Sender, &swap.Recipient,
+			&swap.Amount0, &swap.Amount1, &swap.SqrtPriceX96, &swap.Liquidity, &swap.Tick,
+			&swap.BlockNumber, &swap.BlockTimestamp); err != nil {
+			return nil, err
+		}
+		swap.Amount0Formatted = formatAmount(swap.Amount0, pool.Token0.Decimals)
+		swap.Amount1Formatted = formatAmount(swap.Amount1, pool.Token1.Decimals)
+		swaps = append(swaps, swap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPageResult(swaps, total, page, limit), nil
+}
+
+// ListLiquidityEvents 分页查询池子的 MINT/BURN 记录，按区块倒序
+func (q *Quote) ListLiquidityEvents(poolAddress string, filter LiquidityEventFilter, page, limit int) (*PageResult, error) {
+	pool, err := q.GetPool(poolAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	var where sqlFilter
+	where.add("pool_address = %s", pool.Address)
+	if filter.Type != "" {
+		where.add("type = %s", strings.ToUpper(filter.Type))
+	}
+	if filter.Owner != "" {
+		where.add("LOWER(owner) = LOWER(%s)", filter.Owner)
+	}
+	where.addBlockRange(filter.FromBlock, filter.ToBlock)
+
+	total, err := q.count("FROM liquidity_events"+where.clause(), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询流动性事件数量失败: %w", err)
+	}
+
+	rows, err := q.db.Query(`
+		SELECT transaction_hash, log_index, type, owner, CAST(position_id AS TEXT), amount, amount0, amount1,
+			block_number, block_timestamp
+		FROM liquidity_events`+where.clause()+where.page("ORDER BY block_number DESC, log_index DESC", page, limit), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询流动性事件失败: %w", err)
+	}
+	defer rows.Close()
+
+	events := []LiquidityEventInfo{}
+	for rows.Next() {
+		var event LiquidityEventInfo
+		var positionID sql.NullString
+		if err := rows.Scan(&event.TransactionHash, &event.LogIndex, &event.Type, &event.Owner, &positionID,
+			&event.Amount, &event.Amount0, &event.Amount1, &event.BlockNumber, &event.BlockTimestamp); err != nil {
+			return nil, err
+		}
+		event.PositionID = positionID.String
+		event.Amount0Formatted = formatAmount(event.Amount0, pool.Token0.Decimals)
+		event.Amount1Formatted = formatAmount(event.Amount1, pool.Token1.Decimals)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPageResult(events, total, page, limit), nil
+}
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPoolDetail 按 poolSelect 的字段顺序读取池子信息
+func scanPoolDetail(row rowScanner) (*PoolDetail, error) {
+	var pool PoolDetail
+	var symbol0, name0, symbol1, name1 sql.NullString
+	var decimals0, decimals1, poolIndex, tick, createdBlock sql.NullInt64
+	var liquidity, sqrtPriceX96, reserve0, reserve1 sql.NullString
+
+	if err := row.Scan(&pool.Address, &pool.Token0.Address, &symbol0, &name0, &decimals0,
+		&pool.Token1.Address, &symbol1, &name1, &decimals1,
+		&poolIndex, &pool.Fee, &pool.TickLower, &pool.TickUpper, &liquidity, &sqrtPriceX96, &tick,
+		&reserve0, &reserve1, &createdBlock); err != nil {
+		return nil, err
+	}
+
+	pool.Token0 = newTokenInfo(pool.Token0.Address, symbol0, name0, decimals0)
+	pool.Token1 = newTokenInfo(pool.Token1.Address, symbol1, name1, decimals1)
+	if poolIndex.Valid {
+		pool.PoolIndex = &poolIndex.Int64
+	}
+	if tick.Valid {
+		pool.Tick = tick.Int64
+	}
+	if createdBlock.Valid {
+		pool.CreatedBlock = &createdBlock.Int64
+	}
+	pool.Liquidity = parseBigOrZero(liquidity).String()
+	pool.SqrtPriceX96 = parseBigOrZero(sqrtPriceX96).String()
+	pool.Reserve0 = parseBigOrZero(reserve0).String()
+	pool.Reserve1 = parseBigOrZero(reserve1).String()
+	pool.Reserve0Formatted = formatAmount(pool.Reserve0, pool.Token0.Decimals)
+	pool.Reserve1Formatted = formatAmount(pool.Reserve1, pool.Token1.Decimals)
+	pool.Price = formatPoolPrice(parseBigOrZero(sqrtPriceX96), pool.Token0.Decimals, pool.Token1.Decimals)
+
+	return &pool, nil
+}
+
+// newTokenInfo 根据 tokens 表的可空字段构造代币信息
+func newTokenInfo(address string, symbol, name sql.NullString, decimals sql.NullInt64) TokenInfo {
+	token := TokenInfo{Address: address, Symbol: symbol.String, Name: name.String}
+	if decimals.Valid {
+		token.Decimals = &decimals.Int64
+	}
+	return token
+}
+
+// formatAmount 按代币精度把最小单位的整数金额格式化为小数字符串（如 1500000 / 10^6 = "1.5"）
+// 精度未知或金额无法解析时返回空字符串
+func formatAmount(raw string, decimals *int64) string {
+	if decimals == nil {
+		return ""
+	}
+	value, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return ""
+	}
+	return formatUnits(value, *decimals)
+}
+
+// formatUnits 把整数金额除以 10^decimals，去掉小数部分末尾的0
+func formatUnits(value *big.Int, decimals int64) string {
+	negative := value.Sign() < 0
+	digits := new(big.Int).Abs(value).String()
+	if decimals > 0 {
+		if int64(len(digits)) <= decimals {
+			digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+		}
+		split := int64(len(digits)) - decimals
+		intPart, fracPart := digits[:split], strings.TrimRight(digits[split:], "0")
+		digits = intPart
+		if fracPart != "" {
+			digits += "." + fracPart
+		}
+	}
+	if negative {
+		return "-" + digits
+	}
+	return digits
+}
+
+// formatPoolPrice 把 sqrtPriceX96 换算为按精度调整后的价格：1 token0 可兑换的 token1 数量
+// price = (sqrtPriceX96 / 2^96)^2 * 10^(decimals0 - decimals1)
+func formatPoolPrice(sqrtPriceX96 *big.Int, decimals0, decimals1 *int64) string {
+	if sqrtPriceX96.Sign() == 0 || decimals0 == nil || decimals1 == nil {
+		return ""
+	}
+
+	return formatPrice(scaleDecimals(sqrtPriceRatio(sqrtPriceX96), *decimals0-*decimals1))
+}
+
+// formatPrice 保留18位小数并去掉末尾的0
+func formatPrice(price *big.Float) string {
+	text := price.Text('f', 18)
+	text = strings.TrimSuffix(strings.TrimRight(text, "0"), ".")
+	if text == "" || text == "-" {
+		// 小于 1e-18 的价格
+		return "0"
+	}
+	return text
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// count 执行 SELECT COUNT(*) + fromWhere
+func (q *Quote) count(fromWhere string, args ...interface{}) (int64, error) {
+	var total int64
+	err := q.db.QueryRow("SELECT COUNT(*) "+fromWhere, args...).Scan(&total)
+	return total, err
+}
+
+// newPageResult 构造分页结果
+func newPageResult(items interface{}, total int64, page, limit int) *PageResult {
+	return &PageResult{
+		Items: items,
+		Pagination: Pagination{
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: (total + int64(limit) - 1) / int64(limit),
+		},
+	}
+}
+
+// sqlFilter 拼接 WHERE 条件，条件中的 %s（或 %[1]s）会替换为对应参数的占位符 $n
+type sqlFilter struct {
+	conds []string
+	args  []interface{}
+}
+
+// add 添加一个条件及其参数
+func (f *sqlFilter) add(cond string, arg interface{}) {
+	f.args = append(f.args, arg)
+	f.conds = append(f.conds, fmt.Sprintf(cond, fmt.Sprintf("$%d", len(f.args))))
+}
+
+// addBlockRange 添加 block_number 的闭区间条件
+func (f *sqlFilter) addBlockRange(fromBlock, toBlock *int64) {
+	if fromBlock != nil {
+		f.add("block_number >= %s", *fromBlock)
+	}
+	if toBlock != nil {
+		f.add("block_number <= %s", *toBlock)
+	}
+}
+
+// clause 返回 WHERE 子句，没有条件时返回空字符串
+func (f *sqlFilter) clause() string {
+	if len(f.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conds, " AND ")
+}
+
+// page 返回排序和分页子句；LIMIT/OFFSET 是校验过的整数，直接拼接
+func (f *sqlFilter) page(orderBy string, page, limit int) string {
+	return fmt.Sprintf(" %s LIMIT %d OFFSET %d", orderBy, limit, (page-1)*limit)
+}
@@ -1,8 +1,15 @@
 package api
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,42 +23,120 @@ type Response struct {
 
 // Handler API 处理器
 type Handler struct {
-	quote *Quote
+	quote      *Quote
+	swapRouter string         // SwapRouter 合约地址，用于构建兑换交易
+	notifier   *PoolNotifier  // 池子变化通知，为 nil 时不支持 WebSocket 推送
+	quoter     *OnchainQuoter // 链上报价，为 nil 时不支持 verify
 }
 
 // NewHandler 创建新的处理器
-func NewHandler(quote *Quote) *Handler {
+func NewHandler(quote *Quote, swapRouter string) *Handler {
 	return &Handler{
-		quote: quote,
+		quote:      quote,
+		swapRouter: swapRouter,
 	}
 }
 
+// SetPoolNotifier 启用 WebSocket 推送：池子变化时重新计算订阅的报价和池子价格
+func (h *Handler) SetPoolNotifier(notifier *PoolNotifier) {
+	h.notifier = notifier
+}
+
+// SetOnchainQuoter 启用报价校验：verify 为 true 时同时通过 eth_call 调用 SwapRouter.quoteExactInput
+func (h *Handler) SetOnchainQuoter(quoter *OnchainQuoter) {
+	h.quoter = quoter
+}
+
 // QuoteRequest quote 请求结构
 type QuoteRequest struct {
-	TokenIn     string `json:"tokenIn" binding:"required"`
-	TokenOut    string `json:"tokenOut" binding:"required"`
-	AmountIn    string `json:"amountIn" binding:"required"`
-	PoolAddress string `json:"poolAddress,omitempty"` // 可选：指定池子地址
+	TokenIn      string `json:"tokenIn" binding:"required"`  // 输入代币地址或 symbol
+	TokenOut     string `json:"tokenOut" binding:"required"` // 输出代币地址或 symbol
+	AmountIn     string `json:"amountIn,omitempty"`          // 精确输入时必填：输入金额
+	AmountOut    string `json:"amountOut,omitempty"`         // 精确输出时必填：期望得到的输出金额
+	AmountFormat string `json:"amountFormat,omitempty"`      // 可选：raw（默认，最小单位的整数）或 decimal（按代币精度的小数，如 "1.5"）
+	TradeType    string `json:"tradeType,omitempty"`         // 可选：EXACT_INPUT（默认）或 EXACT_OUTPUT
+	PoolAddress  string `json:"poolAddress,omitempty"`       // 可选：指定池子地址
+	Verify       bool   `json:"verify,omitempty"`            // 可选：同时调用链上 SwapRouter.quoteExactInput 对比结果（仅 EXACT_INPUT）
+
+	IndexPath         []uint32 `json:"indexPath,omitempty"`         // 可选：交易对中按顺序成交的池子序号（与 SwapRouter 的 indexPath 相同），与 poolAddress 互斥
+	SqrtPriceLimitX96 string   `json:"sqrtPriceLimitX96,omitempty"` // 可选：价格限制（与 Pool.swap 相同），需要同时指定 poolAddress 或 indexPath
+
+	// resolveQuoteRequest 解析出的代币信息，用于返回格式化金额
+	tokenIn, tokenOut *TokenInfo
+	// validate 解析出的价格限制，未指定时为 nil
+	sqrtPriceLimit *big.Int
 }
 
 // QuoteResponse quote 响应结构
 type QuoteResponse struct {
-	AmountOut       string  `json:"amountOut"`       // 输出金额
-	AmountIn        string  `json:"amountIn"`        // 输入金额
-	PoolAddress     string  `json:"poolAddress"`     // 使用的池子地址
-	PriceImpact     float64 `json:"priceImpact"`     // 价格影响百分比
-	NewSqrtPriceX96 string  `json:"newSqrtPriceX96"` // 交易后的价格
-	NewTick         int64   `json:"newTick"`         // 交易后的tick
-	InitialPrice    string  `json:"initialPrice"`    // 初始价格
-	FinalPrice      string  `json:"finalPrice"`      // 最终价格
-	CrossedTicks    int     `json:"crossedTicks"`    // 跨越的tick数量
-	Success         bool    `json:"success"`
-	Simulated       bool    `json:"simulated"`
+	TradeType          string             `json:"tradeType"`                    // 报价类型：EXACT_INPUT 或 EXACT_OUTPUT
+	AmountOut          string             `json:"amountOut"`                    // 输出金额（最小单位）
+	AmountIn           string             `json:"amountIn"`                     // 输入金额（最小单位）
+	AmountOutFormatted string             `json:"amountOutFormatted,omitempty"` // 按 tokenOut 精度格式化的输出金额，精度未知时不返回
+	AmountInFormatted  string             `json:"amountInFormatted,omitempty"`  // 按 tokenIn 精度格式化的输入金额，精度未知时不返回
+	TokenIn            *TokenInfo         `json:"tokenIn,omitempty"`            // 输入代币
+	TokenOut           *TokenInfo         `json:"tokenOut,omitempty"`           // 输出代币
+	PoolAddress        string             `json:"poolAddress"`                  // 使用的池子地址
+	PriceImpact        float64            `json:"priceImpact"`                  // 成交均价相对交易前中间价变差的百分比（包含手续费）
+	MidPriceImpact     float64            `json:"midPriceImpact"`               // 交易后中间价相对交易前变差的百分比
+	NewSqrtPriceX96    string             `json:"newSqrtPriceX96"`              // 交易后的价格
+	NewTick            int64              `json:"newTick"`                      // 交易后的tick
+	InitialPrice       string             `json:"initialPrice"`                 // 交易前的中间价（tokenOut/tokenIn，按精度调整；多跳或拆单时为空）
+	FinalPrice         string             `json:"finalPrice"`                   // 交易后的中间价（tokenOut/tokenIn，按精度调整；多跳或拆单时为空）
+	ExecutionPrice     string             `json:"executionPrice"`               // 成交均价 amountOut/amountIn（按精度调整）
+	CrossedTicks       int                `json:"crossedTicks"`                 // 跨越的tick数量
+	Path               []string           `json:"path,omitempty"`               // 代币路径（未指定池子时返回）
+	Route              []RouteHop         `json:"route,omitempty"`              // 路由每一跳的详情（未指定池子时返回）
+	Success            bool               `json:"success"`
+	Simulated          bool               `json:"simulated"`
+	Verification       *QuoteVerification `json:"verification,omitempty"` // verify 为 true 时返回链上报价的对比结果
+
+	AmountInUsed      string         `json:"amountInUsed"`             // 实际消耗的输入金额（含手续费），部分成交时小于 amountIn
+	AmountInRemaining string         `json:"amountInRemaining"`        // 池子价格区间耗尽后未成交的输入金额
+	PartialFill       bool           `json:"partialFill"`              // 只成交了部分输入
+	BoundaryHit       *RangeBoundary `json:"boundaryHit,omitempty"`    // 单个池子部分成交时到达的价格区间边界
+	RemainderRoute    *RouteHop      `json:"remainderRoute,omitempty"` // 指定池子部分成交时，未成交的输入在交易对其他池子中的拆单报价
+	TotalAmountOut    string         `json:"totalAmountOut,omitempty"` // amountOut 加上 remainderRoute 的输出
+}
+
+// validate 补全默认的报价类型，检查报价类型对应的金额参数，以及 indexPath 和价格限制
+func (req *QuoteRequest) validate() error {
+	if req.TradeType == "" {
+		req.TradeType = TradeTypeExactInput
+	}
+	switch req.TradeType {
+	case TradeTypeExactInput:
+		if req.AmountIn == "" {
+			return errors.New("EXACT_INPUT 需要提供 amountIn")
+		}
+	case TradeTypeExactOutput:
+		if req.AmountOut == "" {
+			return errors.New("EXACT_OUTPUT 需要提供 amountOut")
+		}
+	default:
+		return errors.New("不支持的 tradeType " + req.TradeType)
+	}
+
+	if len(req.IndexPath) > 0 && req.PoolAddress != "" {
+		return errors.New("indexPath 和 poolAddress 不能同时指定")
+	}
+	if req.SqrtPriceLimitX96 != "" {
+		// SwapRouter 的价格限制作用于一个交易对的每个池子，无法对应到多跳路由
+		if req.PoolAddress == "" && len(req.IndexPath) == 0 {
+			return errors.New("sqrtPriceLimitX96 需要同时指定 poolAddress 或 indexPath")
+		}
+		limit, ok := new(big.Int).SetString(req.SqrtPriceLimitX96, 10)
+		if !ok || limit.Sign() <= 0 {
+			return errors.New("无效的 sqrtPriceLimitX96 " + req.SqrtPriceLimitX96)
+		}
+		req.sqrtPriceLimit = limit
+	}
+	return nil
 }
 
 // GetQuote godoc
 // @Summary 获取交易报价（Uniswap V3模型）
-// @Description 根据输入代币、输出代币和输入金额计算输出金额，支持跨多个tick区间的精确计算
+// @Description 根据输入代币、输出代币和输入金额计算输出金额（EXACT_INPUT），或根据期望的输出金额计算所需的输入金额（EXACT_OUTPUT，含手续费），支持跨多个tick区间的精确计算；未指定池子时自动搜索直连及2跳、3跳路由；指定 indexPath 时按 SwapRouter 的执行方式在交易对的这些池子中依次成交，sqrtPriceLimitX96 与 Pool.swap 的价格限制相同
 // @Tags Quote
 // @Accept json
 // @Produce json
@@ -71,38 +156,788 @@ func (h *Handler) GetQuote(c *gin.Context) {
 		return
 	}
 
-	var poolAddress string
+	resp, status, err := h.RequestQuote(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(status, Response{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
 
-	// 如果指定了池子地址，直接使用；否则查找最佳池子
-	if req.PoolAddress != "" {
-		poolAddress = req.PoolAddress
-	} else {
-		pool, err := h.quote.FindBestPool(req.TokenIn, req.TokenOut)
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    resp,
+	})
+}
+
+// RequestQuote 校验请求并计算报价，verify 为 true 时在报价所用池子快照的区块上调用 quoteExactInput 对比响应的 amountOut
+// （GetQuote 和 cmd/verify_quotes 共用）。出错时返回的状态码与 computeQuote 相同；
+// verify 只支持 EXACT_INPUT 和单一顺序 indexPath 的报价（ErrUnverifiableQuote），否则返回 400，未配置链上报价时返回 503
+func (h *Handler) RequestQuote(ctx context.Context, req *QuoteRequest) (*QuoteResponse, int, error) {
+	// 校验报价类型和对应的金额参数，解析代币 symbol 和小数金额
+	if err := req.validate(); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("参数错误: %w", err)
+	}
+	if err := h.quote.resolveQuoteRequest(req); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("参数错误: %w", err)
+	}
+	if req.Verify {
+		if req.TradeType != TradeTypeExactInput {
+			return nil, http.StatusBadRequest, fmt.Errorf("参数错误: verify 只支持 EXACT_INPUT")
+		}
+		if h.quoter == nil {
+			return nil, http.StatusServiceUnavailable, fmt.Errorf("未配置 RPC 和 SwapRouter，无法校验链上报价")
+		}
+	}
+
+	// 报价和链上校验读取同一个池子视图，eth_call 固定在该视图的区块上
+	quote := h.quote.Snapshot()
+	resp, status, err := h.computeQuote(quote, req)
+	if err != nil || !req.Verify {
+		return resp, status, err
+	}
+
+	indexPath, pools, err := quote.verifyIndexPath(req, resp)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("参数错误: %w", err)
+	}
+	block, err := quote.IndexedBlock()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	amountIn, _ := new(big.Int).SetString(req.AmountIn, 10)
+	amountOut, ok := new(big.Int).SetString(resp.AmountOut, 10)
+	if !ok {
+		return nil, http.StatusInternalServerError, fmt.Errorf("报价的 amountOut 无效: %s", resp.AmountOut)
+	}
+	resp.Verification = h.quoter.VerifyQuoteExactInput(ctx, block, req.TokenIn, req.TokenOut, indexPath, pools, amountIn, req.sqrtPriceLimit, amountOut)
+	return resp, http.StatusOK, nil
+}
+
+// computeQuote 用 quote（通常为 Snapshot 固定的视图）按已校验的请求计算报价；出错时返回的状态码区分参数错误（400）、未找到路由（404）和计算失败（500）
+func (h *Handler) computeQuote(quote *Quote, req *QuoteRequest) (*QuoteResponse, int, error) {
+	// 指定 indexPath 时按 SwapRouter 的执行方式在交易对的这些池子中依次成交
+	if len(req.IndexPath) > 0 {
+		amount := req.AmountIn
+		if req.TradeType == TradeTypeExactOutput {
+			amount = req.AmountOut
+		}
+		amountSpecified, ok := new(big.Int).SetString(amount, 10)
+		if !ok || amountSpecified.Sign() <= 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("参数错误: 无效的金额 %s", amount)
+		}
+		if req.TradeType == TradeTypeExactOutput {
+			amountSpecified.Neg(amountSpecified)
+		}
+		route, err := quote.QuoteIndexPath(req.TokenIn, req.TokenOut, req.IndexPath, amountSpecified, req.sqrtPriceLimit)
 		if err != nil {
-			c.JSON(http.StatusNotFound, Response{
-				Code:    404,
-				Message: "未找到交易对池子: " + err.Error(),
+			return nil, quoteErrorStatus(err), fmt.Errorf("计算报价失败: %w", err)
+		}
+		resp := newRouteQuoteResponse(req.TradeType, route)
+		resp.setTokens(req.tokenIn, req.tokenOut)
+		return &resp, http.StatusOK, nil
+	}
+
+	// 未指定池子地址时，在代币图上搜索最佳路由（直连或多跳）
+	if req.PoolAddress == "" {
+		var route *RouteResult
+		var err error
+		if req.TradeType == TradeTypeExactOutput {
+			route, err = quote.FindBestRouteExactOutput(req.TokenIn, req.TokenOut, req.AmountOut)
+		} else {
+			route, err = quote.FindBestRoute(req.TokenIn, req.TokenOut, req.AmountIn)
+		}
+		if err != nil {
+			return nil, http.StatusNotFound, fmt.Errorf("未找到交易路径: %w", err)
+		}
+		resp := newRouteQuoteResponse(req.TradeType, route)
+		resp.setTokens(req.tokenIn, req.tokenOut)
+		return &resp, http.StatusOK, nil
+	}
+
+	// 指定了池子地址，使用V3模型在该池子上计算报价（支持跨多个tick区间）
+	poolAddress := req.PoolAddress
+	var result *QuoteResult
+	var err error
+	if req.TradeType == TradeTypeExactOutput {
+		result, err = quote.CalculateQuoteExactOutput(poolAddress, req.TokenIn, req.AmountOut, req.sqrtPriceLimit)
+	} else {
+		result, err = quote.CalculateQuoteV3(poolAddress, req.TokenIn, req.AmountIn, req.sqrtPriceLimit)
+	}
+	if err != nil {
+		return nil, quoteErrorStatus(err), fmt.Errorf("计算报价失败: %w", err)
+	}
+
+	resp := &QuoteResponse{
+		TradeType:       req.TradeType,
+		AmountOut:       result.AmountOut,
+		AmountIn:        result.AmountIn,
+		PoolAddress:     poolAddress,
+		PriceImpact:     result.PriceImpact,
+		MidPriceImpact:  result.MidPriceImpact,
+		NewSqrtPriceX96: result.NewSqrtPriceX96,
+		NewTick:         result.NewTick,
+		InitialPrice:    result.InitialPrice,
+		FinalPrice:      result.FinalPrice,
+		ExecutionPrice:  result.ExecutionPrice,
+		CrossedTicks:    result.CrossedTicks,
+		Success:         true,
+		Simulated:       true,
+
+		AmountInUsed:      result.AmountInUsed,
+		AmountInRemaining: result.AmountInRemaining,
+		PartialFill:       result.PartialFill,
+		BoundaryHit:       result.BoundaryHit,
+	}
+
+	// 指定池子的价格区间被耗尽时，给出未成交部分在交易对其他池子中的报价
+	// （指定价格限制时未成交的部分是调用方主动放弃的，不再给出）
+	if result.PartialFill && req.sqrtPriceLimit == nil {
+		remaining, _ := new(big.Int).SetString(result.AmountInRemaining, 10)
+		if hop := quote.remainderHop(poolAddress, req.TokenIn, req.TokenOut, remaining); hop != nil {
+			out, _ := new(big.Int).SetString(result.AmountOut, 10)
+			remainderOut, _ := new(big.Int).SetString(hop.AmountOut, 10)
+			resp.RemainderRoute = hop
+			resp.TotalAmountOut = out.Add(out, remainderOut).String()
+		}
+	}
+
+	resp.setTokens(req.tokenIn, req.tokenOut)
+	return resp, http.StatusOK, nil
+}
+
+// quoteErrorStatus 价格限制不满足 Pool.swap 的检查或 indexPath 无效时为参数错误，其余为计算失败
+func quoteErrorStatus(err error) int {
+	if errors.Is(err, errSqrtPriceLimit) || errors.Is(err, errInvalidIndexPath) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// SwapTxRequest 构建兑换交易的请求：报价参数加上滑点、接收地址和截止时间
+type SwapTxRequest struct {
+	QuoteRequest
+	SlippageBps *int64 `json:"slippageBps,omitempty"`        // 可选：滑点容忍度（基点），默认 50（0.5%），最大 5000
+	Recipient   string `json:"recipient" binding:"required"` // 接收输出代币的地址
+	Deadline    int64  `json:"deadline,omitempty"`           // 可选：交易截止时间（unix 秒），默认当前时间 + 1200 秒
+}
+
+// BuildSwapTx godoc
+// @Summary 构建带滑点保护的兑换交易
+// @Description 按当前池子状态重新计算报价，返回 SwapRouter.exactInput（EXACT_INPUT，带 amountOutMinimum）或 exactOutput（EXACT_OUTPUT，带 amountInMaximum）的 calldata。SwapRouter 一笔交易只兑换一个交易对，indexPath 中的池子按顺序成交；请求中指定的 indexPath 和 sqrtPriceLimitX96 原样写入交易
+// @Tags Quote
+// @Accept json
+// @Produce json
+// @Param request body SwapTxRequest true "交易构建请求"
+// @Success 200 {object} Response{data=SwapTx}
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/swap/tx [post]
+func (h *Handler) BuildSwapTx(c *gin.Context) {
+	// 解析代币、选择池子和模拟兑换读取同一个池子视图
+	quote := h.quote.Snapshot()
+
+	var req SwapTxRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if err := quote.resolveQuoteRequest(&req.QuoteRequest); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if h.swapRouter == "" {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: "未配置 SwapRouter 地址（Contracts.SwapRouter）",
+		})
+		return
+	}
+
+	slippageBps := int64(DefaultSlippageBps)
+	if req.SlippageBps != nil {
+		slippageBps = *req.SlippageBps
+	}
+	if slippageBps < 0 || slippageBps > MaxSlippageBps {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: fmt.Sprintf("参数错误: slippageBps 必须在 0 到 %d 之间", MaxSlippageBps),
+		})
+		return
+	}
+
+	now := time.Now().Unix()
+	deadline := req.Deadline
+	if deadline == 0 {
+		deadline = now + DefaultDeadlineSeconds
+	}
+	if deadline <= now {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: deadline 已过期",
+		})
+		return
+	}
+
+	amount := req.AmountIn
+	if req.TradeType == TradeTypeExactOutput {
+		amount = req.AmountOut
+	}
+
+	tx, err := quote.BuildSwapTx(h.swapRouter, SwapTxParams{
+		TradeType:         req.TradeType,
+		TokenIn:           req.TokenIn,
+		TokenOut:          req.TokenOut,
+		Amount:            amount,
+		PoolAddress:       req.PoolAddress,
+		IndexPath:         req.IndexPath,
+		SqrtPriceLimitX96: req.sqrtPriceLimit,
+		SlippageBps:       slippageBps,
+		Recipient:         req.Recipient,
+		Deadline:          deadline,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "构建交易失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    tx,
+	})
+}
+
+// newRouteQuoteResponse 将路由结果转换为报价响应
+// 单跳单池时保留原有的池子级字段；多跳或拆单时池子级字段见 route 中的每一跳
+func newRouteQuoteResponse(tradeType string, route *RouteResult) QuoteResponse {
+	resp := QuoteResponse{
+		TradeType:      tradeType,
+		AmountIn:       route.AmountIn,
+		AmountOut:      route.AmountOut,
+		PriceImpact:    route.PriceImpact,
+		MidPriceImpact: route.MidPriceImpact,
+		ExecutionPrice: route.ExecutionPrice,
+		Path:           route.Path,
+		Route:          route.Hops,
+		Success:        true,
+		Simulated:      true,
+
+		AmountInUsed:      route.AmountInUsed,
+		AmountInRemaining: route.AmountInRemaining,
+		PartialFill:       route.PartialFill,
+	}
+
+	// 单跳且未拆单时，池子级字段与指定池子报价保持一致
+	if len(route.Hops) == 1 && len(route.Hops[0].Splits) == 1 {
+		split := route.Hops[0].Splits[0]
+		resp.PoolAddress = split.PoolAddress
+		resp.NewSqrtPriceX96 = split.result.NewSqrtPriceX96
+		resp.NewTick = split.result.NewTick
+		resp.InitialPrice = split.result.InitialPrice
+		resp.FinalPrice = split.result.FinalPrice
+		resp.BoundaryHit = split.BoundaryHit
+	}
+
+	for _, hop := range route.Hops {
+		resp.CrossedTicks += hop.CrossedTicks
+	}
+
+	return resp
+}
+
+// GetPositionFees godoc
+// @Summary 获取 position 的手续费统计
+// @Description 返回 position 已领取的手续费和已赚取但尚未领取的手续费。tokensOwed 同时包含手续费和 Burn 退出的本金，按「先领本金、后领手续费」拆分
+// @Tags Position
+// @Produce json
+// @Param id path string true "Position ID（NFT tokenId 或虚拟 position ID）"
+// @Success 200 {object} Response{data=PositionFees}
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/positions/{id}/fees [get]
+func (h *Handler) GetPositionFees(c *gin.Context) {
+	id := c.Param("id")
+	if _, ok := new(big.Int).SetString(id, 10); !ok {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: 无效的 position ID " + id,
+		})
+		return
+	}
+
+	fees, err := h.quote.GetPositionFees(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "position 不存在: " + id,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: "查询手续费失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    fees,
+	})
+}
+
+// ListPools godoc
+// @Summary 分页查询池子列表
+// @Description 按流动性降序返回池子列表，储备金额和价格按 tokens.decimals 调整
+// @Tags Pool
+// @Produce json
+// @Param token query string false "交易对中包含该代币（token0 或 token1）"
+// @Param fee query int false "手续费等级"
+// @Param page query int false "页码，从1开始" default(1)
+// @Param limit query int false "每页数量，最大100" default(20)
+// @Success 200 {object} Response{data=PageResult{items=[]PoolDetail}}
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/pools [get]
+func (h *Handler) ListPools(c *gin.Context) {
+	page, limit, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	fee, ok := parseOptionalInt64(c, "fee")
+	if !ok {
+		return
+	}
+
+	result, err := h.quote.ListPools(PoolFilter{Token: c.Query("token"), Fee: fee}, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: "查询池子失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    result,
+	})
+}
+
+// GetPool godoc
+// @Summary 查询池子详情
+// @Description 返回池子状态、代币信息，以及按 tokens.decimals 调整后的储备金额和价格
+// @Tags Pool
+// @Produce json
+// @Param address path string true "池子地址"
+// @Success 200 {object} Response{data=PoolDetail}
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/pools/{address} [get]
+func (h *Handler) GetPool(c *gin.Context) {
+	pool, err := h.quote.GetPool(c.Param("address"))
+	if err != nil {
+		respondLookupError(c, "池子", c.Param("address"), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    pool,
+	})
+}
+
+// ListTokens godoc
+// @Summary 分页查询代币列表
+// @Tags Token
+// @Produce json
+// @Param symbol query string false "按 symbol 过滤（大小写不敏感）"
+// @Param page query int false "页码，从1开始" default(1)
+// @Param limit query int false "每页数量，最大100" default(20)
+// @Success 200 {object} Response{data=PageResult{items=[]TokenInfo}}
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/tokens [get]
+func (h *Handler) ListTokens(c *gin.Context) {
+	page, limit, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.quote.ListTokens(c.Query("symbol"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: "查询代币失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    result,
+	})
+}
+
+// ListPositions godoc
+// @Summary 分页查询 position 列表
+// @Description 按创建区块倒序返回 position，tokensOwed 按 tokens.decimals 调整
+// @Tags Position
+// @Produce json
+// @Param owner query string false "position 所有者地址"
+// @Param pool query string false "池子地址"
+// @Param active query bool false "只返回流动性大于0的 position"
+// @Param page query int false "页码，从1开始" default(1)
+// @Param limit query int false "每页数量，最大100" default(20)
+// @Success 200 {object} Response{data=PageResult{items=[]PositionInfo}}
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/positions [get]
+func (h *Handler) ListPositions(c *gin.Context) {
+	page, limit, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.quote.ListPositions(PositionFilter{
+		Owner:       c.Query("owner"),
+		PoolAddress: c.Query("pool"),
+		ActiveOnly:  c.Query("active") == "true",
+	}, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: "查询 position 失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    result,
+	})
+}
+
+// ListPoolSwaps godoc
+// @Summary 分页查询池子的交易记录
+// @Description 按区块倒序返回 Swap 记录，金额按 tokens.decimals 调整（池子视角：正数流入、负数流出）
+// @Tags Pool
+// @Produce json
+// @Param address path string true "池子地址"
+// @Param account query string false "sender 或 recipient 地址"
+// @Param fromBlock query int false "起始区块（含）"
+// @Param toBlock query int false "结束区块（含）"
+// @Param page query int false "页码，从1开始" default(1)
+// @Param limit query int false "每页数量，最大100" default(20)
+// @Success 200 {object} Response{data=PageResult{items=[]SwapInfo}}
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/pools/{address}/swaps [get]
+func (h *Handler) ListPoolSwaps(c *gin.Context) {
+	page, limit, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	fromBlock, toBlock, ok := parseBlockRange(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.quote.ListSwaps(c.Param("address"), SwapFilter{
+		Account:   c.Query("account"),
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+	}, page, limit)
+	if err != nil {
+		respondLookupError(c, "池子", c.Param("address"), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    result,
+	})
+}
+
+// ListPoolLiquidityEvents godoc
+// @Summary 分页查询池子的流动性事件
+// @Description 按区块倒序返回 MINT/BURN 记录，金额按 tokens.decimals 调整
+// @Tags Pool
+// @Produce json
+// @Param address path string true "池子地址"
+// @Param type query string false "事件类型：MINT 或 BURN"
+// @Param owner query string false "流动性 owner 地址"
+// @Param fromBlock query int false "起始区块（含）"
+// @Param toBlock query int false "结束区块（含）"
+// @Param page query int false "页码，从1开始" default(1)
+// @Param limit query int false "每页数量，最大100" default(20)
+// @Success 200 {object} Response{data=PageResult{items=[]LiquidityEventInfo}}
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/pools/{address}/liquidity-events [get]
+func (h *Handler) ListPoolLiquidityEvents(c *gin.Context) {
+	page, limit, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	fromBlock, toBlock, ok := parseBlockRange(c)
+	if !ok {
+		return
+	}
+	eventType := strings.ToUpper(c.Query("type"))
+	if eventType != "" && eventType != "MINT" && eventType != "BURN" {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: type 只能是 MINT 或 BURN",
+		})
+		return
+	}
+
+	result, err := h.quote.ListLiquidityEvents(c.Param("address"), LiquidityEventFilter{
+		Type:      eventType,
+		Owner:     c.Query("owner"),
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+	}, page, limit)
+	if err != nil {
+		respondLookupError(c, "池子", c.Param("address"), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    result,
+	})
+}
+
+// GetPoolCandles godoc
+// @Summary 查询池子的 K 线
+// @Description 返回由 Swap 聚合的 OHLCV K 线，按时间升序。价格按 tokens.decimals 调整，默认为 1 token0 可兑换的 token1 数量，invert=true 时以 token1 为 base；成交量为两个代币各自的绝对值之和
+// @Tags Pool
+// @Produce json
+// @Param address path string true "池子地址"
+// @Param interval query string true "K 线周期：1m、5m、1h、1d"
+// @Param from query int false "开始时间（unix 秒，含）"
+// @Param to query int false "结束时间（unix 秒，含）"
+// @Param limit query int false "返回时间范围内最近的 K 线数量，最大1000" default(200)
+// @Param invert query bool false "是否以 token1 为 base 计价" default(false)
+// @Success 200 {object} Response{data=CandleSeries}
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/pools/{address}/candles [get]
+func (h *Handler) GetPoolCandles(c *gin.Context) {
+	query := CandleQuery{Interval: c.Query("interval"), Limit: DefaultCandleLimit}
+	if !IsCandleInterval(query.Interval) {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: interval 只能是 " + strings.Join(CandleIntervals, "、"),
+		})
+		return
+	}
+
+	from, ok := parseOptionalInt64(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseOptionalInt64(c, "to")
+	if !ok {
+		return
+	}
+	if from != nil {
+		t := time.Unix(*from, 0).UTC()
+		query.From = &t
+	}
+	if to != nil {
+		t := time.Unix(*to, 0).UTC()
+		query.To = &t
+	}
+
+	limit, ok := parseOptionalInt64(c, "limit")
+	if !ok {
+		return
+	}
+	if limit != nil {
+		if *limit < 1 || *limit > MaxCandleLimit {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: fmt.Sprintf("参数错误: limit 必须在 1 到 %d 之间", MaxCandleLimit),
 			})
 			return
 		}
-		poolAddress = pool.Address
+		query.Limit = int(*limit)
+	}
 
-		// 检查找到的池子是否有流动性
-		if pool.Liquidity == "0" || pool.Liquidity == "" {
+	if v := c.Query("invert"); v != "" {
+		invert, err := strconv.ParseBool(v)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, Response{
 				Code:    400,
-				Message: fmt.Sprintf("找到的池子 %s 流动性为0，无法进行交易", poolAddress),
+				Message: "参数错误: invert 必须是 true 或 false",
 			})
 			return
 		}
+		query.Invert = invert
+	}
+
+	series, err := h.quote.ListCandles(c.Param("address"), query)
+	if err != nil {
+		respondLookupError(c, "池子", c.Param("address"), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    series,
+	})
+}
+
+// GetPoolDepth godoc
+// @Summary 查询池子的流动性分布和滑点曲线
+// @Description 遍历池子已初始化的 tick，返回各价格区间的活跃流动性和锁定的代币数量；并按一组输入金额计算报价，返回输出金额和价格影响。未指定 sizes 时按池子最多可成交输入（capacity）的 1%、2%、5%、10%、25%、50%、75%、100% 计算
+// @Tags Pool
+// @Produce json
+// @Param address path string true "池子地址"
+// @Param tokenIn query string false "滑点曲线的输入代币地址或 symbol，默认为 token0"
+// @Param sizes query string false "逗号分隔的输入金额，如 1000000,5000000"
+// @Param amountFormat query string false "sizes 的格式：raw（最小单位，默认）或 decimal（按代币精度的小数）"
+// @Success 200 {object} Response{data=DepthResult}
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/pools/{address}/depth [get]
+func (h *Handler) GetPoolDepth(c *gin.Context) {
+	address := c.Param("address")
+	pool, err := h.quote.GetPoolState(address)
+	if err != nil {
+		respondLookupError(c, "池子", address, err)
+		return
+	}
+
+	tokenRef := c.Query("tokenIn")
+	if tokenRef == "" {
+		tokenRef = pool.Token0
+	}
+	tokenIn, err := h.quote.ResolveToken(tokenRef)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: tokenIn: " + err.Error(),
+		})
+		return
+	}
+	sizes, ok := parseDepthSizes(c, tokenIn)
+	if !ok {
+		return
+	}
+
+	result, err := h.quote.PoolDepth(address, tokenIn.Address, sizes)
+	if errors.Is(err, errTokenNotInPool) {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		respondLookupError(c, "池子", address, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    result,
+	})
+}
+
+// GetPairDepth godoc
+// @Summary 查询交易对的流动性分布和滑点曲线
+// @Description 汇总交易对所有有流动性的池子：价格区间的流动性为各池子之和，滑点曲线按最优拆分（与报价接口相同）计算。tokenA 为滑点曲线的输入代币，价格区间的价格以 token1/token0 表示
+// @Tags Pool
+// @Produce json
+// @Param tokenA path string true "输入代币地址或 symbol"
+// @Param tokenB path string true "输出代币地址或 symbol"
+// @Param sizes query string false "逗号分隔的输入金额，如 1000000,5000000"
+// @Param amountFormat query string false "sizes 的格式：raw（最小单位，默认）或 decimal（按代币精度的小数）"
+// @Success 200 {object} Response{data=DepthResult}
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/pairs/{tokenA}/{tokenB}/depth [get]
+func (h *Handler) GetPairDepth(c *gin.Context) {
+	tokenIn, err := h.quote.ResolveToken(c.Param("tokenA"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: tokenA: " + err.Error(),
+		})
+		return
+	}
+	tokenOut, err := h.quote.ResolveToken(c.Param("tokenB"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: tokenB: " + err.Error(),
+		})
+		return
+	}
+	if strings.EqualFold(tokenIn.Address, tokenOut.Address) {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: tokenA 和 tokenB 不能相同",
+		})
+		return
+	}
+	sizes, ok := parseDepthSizes(c, tokenIn)
+	if !ok {
+		return
 	}
 
-	// 使用V3模型计算报价（支持跨多个tick区间）
-	result, err := h.quote.CalculateQuoteV3(poolAddress, req.TokenIn, req.AmountIn)
+	result, err := h.quote.PairDepth(tokenIn.Address, tokenOut.Address, sizes)
+	if errors.Is(err, errNoPairPools) {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
-			Message: "计算报价失败: " + err.Error(),
+			Message: "计算深度失败: " + err.Error(),
 		})
 		return
 	}
@@ -110,18 +945,127 @@ func (h *Handler) GetQuote(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    200,
 		Message: "success",
-		Data: QuoteResponse{
-			AmountOut:       result.AmountOut,
-			AmountIn:        result.AmountIn,
-			PoolAddress:     poolAddress,
-			PriceImpact:     result.PriceImpact,
-			NewSqrtPriceX96: result.NewSqrtPriceX96,
-			NewTick:         result.NewTick,
-			InitialPrice:    result.InitialPrice,
-			FinalPrice:      result.FinalPrice,
-			CrossedTicks:    result.CrossedTicks,
-			Success:         true,
-			Simulated:       true,
-		},
+		Data:    result,
 	})
 }
+
+// parseDepthSizes 解析逗号分隔的 sizes 和 amountFormat 查询参数，未传 sizes 时返回 nil（使用默认档位）
+func parseDepthSizes(c *gin.Context, tokenIn *TokenInfo) ([]*big.Int, bool) {
+	badRequest := func(message string) ([]*big.Int, bool) {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: " + message,
+		})
+		return nil, false
+	}
+
+	format := c.DefaultQuery("amountFormat", AmountFormatRaw)
+	if format != AmountFormatRaw && format != AmountFormatDecimal {
+		return badRequest("不支持的 amountFormat " + format)
+	}
+	if format == AmountFormatDecimal && tokenIn.Decimals == nil {
+		return badRequest(fmt.Sprintf("代币 %s 的精度未知，请使用最小单位的金额", tokenIn.Address))
+	}
+
+	v := c.Query("sizes")
+	if v == "" {
+		return nil, true
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) > MaxDepthSizes {
+		return badRequest(fmt.Sprintf("sizes 最多 %d 个", MaxDepthSizes))
+	}
+
+	sizes := make([]*big.Int, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		var size *big.Int
+		if format == AmountFormatDecimal {
+			var err error
+			if size, err = parseUnits(part, *tokenIn.Decimals); err != nil {
+				return badRequest(err.Error())
+			}
+		} else {
+			var ok bool
+			if size, ok = new(big.Int).SetString(part, 10); !ok {
+				return badRequest("无效的金额: " + part)
+			}
+		}
+		if size.Sign() <= 0 {
+			return badRequest("sizes 必须大于0")
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, true
+}
+
+// respondLookupError 记录不存在时返回 404，其他错误返回 500
+func respondLookupError(c *gin.Context, resource, key string, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: resource + "不存在: " + key,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, Response{
+		Code:    500,
+		Message: "查询" + resource + "失败: " + err.Error(),
+	})
+}
+
+// parsePagination 解析 page 和 limit 查询参数，参数错误时写入 400 响应并返回 false
+func parsePagination(c *gin.Context) (page, limit int, ok bool) {
+	page, limit = 1, DefaultPageLimit
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "参数错误: page 必须是大于0的整数",
+			})
+			return 0, 0, false
+		}
+		page = n
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxPageLimit {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: fmt.Sprintf("参数错误: limit 必须在 1 到 %d 之间", MaxPageLimit),
+			})
+			return 0, 0, false
+		}
+		limit = n
+	}
+	return page, limit, true
+}
+
+// parseBlockRange 解析 fromBlock 和 toBlock 查询参数
+func parseBlockRange(c *gin.Context) (fromBlock, toBlock *int64, ok bool) {
+	if fromBlock, ok = parseOptionalInt64(c, "fromBlock"); !ok {
+		return nil, nil, false
+	}
+	if toBlock, ok = parseOptionalInt64(c, "toBlock"); !ok {
+		return nil, nil, false
+	}
+	return fromBlock, toBlock, true
+}
+
+// parseOptionalInt64 解析可选的整数查询参数，未传时返回 nil，格式错误时写入 400 响应并返回 false
+func parseOptionalInt64(c *gin.Context, name string) (*int64, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "参数错误: " + name + " 必须是整数",
+		})
+		return nil, false
+	}
+	return &n, true
+}
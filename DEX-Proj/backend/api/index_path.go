@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// errInvalidIndexPath indexPath 中的池子序号不属于交易对或重复
+var errInvalidIndexPath = errors.New("无效的 indexPath")
+
+// QuoteIndexPath 按调用方指定的 indexPath 和价格限制报价，与 SwapRouter.exactInput / exactOutput 的执行方式相同：
+// 交易对的池子按 indexPath 的顺序依次处理剩余的全部数量，剩余为 0 时停止，每个池子停在价格区间边界和价格限制中更近的一个上。
+// amountSpecified 为正数表示精确输入，为负数表示精确输出；sqrtPriceLimitX96 为 nil 时只受池子价格区间限制。
+//
+// 精确输入时池子全部到达边界后未成交的输入记录在 amountInRemaining 中（链上同样只成交部分输入）；
+// 精确输出时无法输出全部数量则返回错误。经过的池子不满足价格限制时链上会 revert，返回 errSqrtPriceLimit。
+func (q *Quote) QuoteIndexPath(tokenIn, tokenOut string, indexPath []uint32, amountSpecified, sqrtPriceLimitX96 *big.Int) (*RouteResult, error) {
+	swap, err := q.planRouterSwap(tokenIn, tokenOut, "", indexPath, amountSpecified, sqrtPriceLimitX96)
+	if err != nil {
+		return nil, err
+	}
+
+	exactInput := amountSpecified.Sign() > 0
+	if !exactInput && swap.remaining.Sign() > 0 {
+		return nil, fmt.Errorf("流动性不足: indexPath 的池子到达价格区间边界（或价格限制）后仍有 %s 未输出", swap.remaining.String())
+	}
+
+	zeroForOne := isZeroForOne(tokenIn, tokenOut)
+	requested := swap.amountIn
+	if exactInput {
+		requested = amountSpecified
+	}
+	totalUsed, _ := new(big.Float).SetInt(swap.amountIn).Float64()
+
+	hop := RouteHop{
+		TokenIn:   tokenIn,
+		TokenOut:  tokenOut,
+		AmountIn:  requested.String(),
+		AmountOut: swap.amountOut.String(),
+	}
+	// 精确输入时每个池子收到的是前面的池子成交后剩余的全部输入
+	offered := new(big.Int).Set(requested)
+	var largest int
+	for i, pool := range swap.pools {
+		step := swap.steps[i]
+		poolIn := step.AmountIn
+		if exactInput {
+			poolIn = new(big.Int).Set(offered)
+		}
+
+		result := &QuoteResult{
+			AmountOut:       step.AmountOut.String(),
+			AmountIn:        poolIn.String(),
+			NewSqrtPriceX96: step.NewSqrtPriceX96.String(),
+			NewTick:         step.NewTick,
+			CrossedTicks:    step.CrossedTicks,
+		}
+		result.setFill(pool, zeroForOne, poolIn, step.AmountIn, step.NewSqrtPriceX96)
+		result.setPrices(pool, zeroForOne, step.AmountIn, step.AmountOut, step.NewSqrtPriceX96)
+
+		stepIn, _ := new(big.Float).SetInt(step.AmountIn).Float64()
+		weight := stepIn / totalUsed
+		hop.Splits = append(hop.Splits, PoolSplit{
+			PoolAddress:    pool.Address,
+			PoolIndex:      pool.PoolIndex,
+			Fee:            pool.Fee,
+			Percent:        weight * 100,
+			AmountIn:       result.AmountIn,
+			AmountOut:      result.AmountOut,
+			PriceImpact:    result.PriceImpact,
+			MidPriceImpact: result.MidPriceImpact,
+			AmountInUsed:   result.AmountInUsed,
+			PartialFill:    result.PartialFill,
+			BoundaryHit:    result.BoundaryHit,
+			result:         result,
+		})
+		hop.PriceImpact += result.PriceImpact * weight
+		hop.MidPriceImpact += result.MidPriceImpact * weight
+		hop.CrossedTicks += result.CrossedTicks
+
+		if step.AmountIn.Cmp(swap.steps[largest].AmountIn) > 0 {
+			largest = i
+		}
+		offered.Sub(offered, step.AmountIn)
+	}
+
+	primary := swap.pools[largest]
+	hop.PoolAddress = primary.Address
+	hop.Fee = primary.Fee
+	hop.setFill(requested, swap.amountIn)
+	hop.ExecutionPrice = formatPrice(executionPrice(swap.amountIn, swap.amountOut,
+		primary.tokenDecimals(tokenIn), primary.tokenDecimals(tokenOut)))
+
+	return &RouteResult{
+		Path:              []string{tokenIn, tokenOut},
+		Hops:              []RouteHop{hop},
+		AmountIn:          hop.AmountIn,
+		AmountOut:         hop.AmountOut,
+		PriceImpact:       hop.PriceImpact,
+		MidPriceImpact:    hop.MidPriceImpact,
+		ExecutionPrice:    hop.ExecutionPrice,
+		AmountInUsed:      hop.AmountInUsed,
+		AmountInRemaining: hop.AmountInRemaining,
+		PartialFill:       hop.PartialFill,
+	}, nil
+}
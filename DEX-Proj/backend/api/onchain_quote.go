@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// onchainQuoteTimeout 单次 eth_call 报价的超时时间
+const onchainQuoteTimeout = 10 * time.Second
+
+// quoterABI SwapRouter 的 quoteExactInput（ISwapRouter.sol）
+// 合约在 swapCallback 中 revert 携带 (amount0, amount1)，由 SwapRouter.parseRevertReason 解析后正常返回 amountOut
+const quoterABI = `[
+	{"type":"function","name":"quoteExactInput","stateMutability":"nonpayable",
+	 "inputs":[{"name":"params","type":"tuple","components":[
+		{"name":"tokenIn","type":"address"},
+		{"name":"tokenOut","type":"address"},
+		{"name":"indexPath","type":"uint32[]"},
+		{"name":"amountIn","type":"uint256"},
+		{"name":"sqrtPriceLimitX96","type":"uint160"}]}],
+	 "outputs":[{"name":"amountOut","type":"uint256"}]}
+]`
+
+var parsedQuoterABI = mustParseABI(quoterABI)
+
+// quoteExactInputParams 对应 ISwapRouter.QuoteExactInputParams
+type quoteExactInputParams struct {
+	TokenIn           common.Address
+	TokenOut          common.Address
+	IndexPath         []uint32
+	AmountIn          *big.Int
+	SqrtPriceLimitX96 *big.Int
+}
+
+// OnchainQuoter 通过 eth_call 调用 SwapRouter.quoteExactInput 获取链上报价
+type OnchainQuoter struct {
+	caller ethereum.ContractCaller
+	router common.Address
+}
+
+// NewOnchainQuoter 创建链上报价器，caller 通常为 *ethclient.Client
+func NewOnchainQuoter(caller ethereum.ContractCaller, router string) (*OnchainQuoter, error) {
+	if !common.IsHexAddress(router) {
+		return nil, fmt.Errorf("未配置有效的 SwapRouter 地址")
+	}
+	return &OnchainQuoter{caller: caller, router: common.HexToAddress(router)}, nil
+}
+
+// QuoteExactInput 在 block 区块（为 nil 时为最新区块）上按 indexPath 调用 quoteExactInput，返回 amountOut
+func (o *OnchainQuoter) QuoteExactInput(ctx context.Context, tokenIn, tokenOut string, indexPath []uint32, amountIn, sqrtPriceLimitX96, block *big.Int) (*big.Int, error) {
+	calldata, err := parsedQuoterABI.Pack("quoteExactInput", quoteExactInputParams{
+		TokenIn:           common.HexToAddress(tokenIn),
+		TokenOut:          common.HexToAddress(tokenOut),
+		IndexPath:         indexPath,
+		AmountIn:          amountIn,
+		SqrtPriceLimitX96: sqrtPriceLimitX96,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("编码 quoteExactInput calldata 失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, onchainQuoteTimeout)
+	defer cancel()
+	output, err := o.caller.CallContract(ctx, ethereum.CallMsg{To: &o.router, Data: calldata}, block)
+	if err != nil {
+		return nil, fmt.Errorf("调用 quoteExactInput 失败: %w", decodeCallError(err))
+	}
+
+	values, err := parsedQuoterABI.Unpack("quoteExactInput", output)
+	if err != nil {
+		return nil, fmt.Errorf("解析 quoteExactInput 返回值失败: %w", err)
+	}
+	amountOut, ok := values[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("quoteExactInput 返回值类型错误: %T", values[0])
+	}
+	return amountOut, nil
+}
+
+// decodeCallError 从 eth_call 的错误中解析 revert 原因（如 "Pool not found"），无法解析时返回原错误
+func decodeCallError(err error) error {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return err
+	}
+	data, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	raw, decodeErr := hexutil.Decode(data)
+	if decodeErr != nil {
+		return err
+	}
+	reason, unpackErr := abi.UnpackRevert(raw)
+	if unpackErr != nil {
+		return err
+	}
+	return fmt.Errorf("execution reverted: %s", reason)
+}
+
+// ErrUnverifiableQuote 报价不是按一个 indexPath 顺序成交（多跳或拆单到多个池子），quoteExactInput 无法复现
+var ErrUnverifiableQuote = errors.New("报价不是单一顺序的 indexPath（多跳或拆单），无法用 quoteExactInput 校验")
+
+// QuoteVerification 链下报价与 SwapRouter.quoteExactInput 的对比结果
+type QuoteVerification struct {
+	Block             int64    `json:"block"`                      // eth_call 使用的区块：报价所用池子快照的区块高度，为 0 时为最新区块
+	IndexPath         []uint32 `json:"indexPath"`                  // 报价按顺序成交的池子序号（请求指定的 indexPath，或报价使用的单个池子）
+	Pools             []string `json:"pools"`                      // indexPath 对应的池子地址
+	OffchainAmountOut string   `json:"offchainAmountOut"`          // 报价响应的 amountOut
+	OnchainAmountOut  string   `json:"onchainAmountOut,omitempty"` // quoteExactInput 的输出
+	Difference        string   `json:"difference,omitempty"`       // onchainAmountOut - offchainAmountOut
+	DifferenceBps     float64  `json:"differenceBps"`              // 差值相对链上输出的万分比
+	Match             bool     `json:"match"`                      // 两者完全一致
+	Error             string   `json:"error,omitempty"`            // 无法完成对比时的原因
+}
+
+// verifyIndexPath 返回报价按顺序成交的 SwapRouter indexPath 及池子地址：请求指定 indexPath 时为该顺序，
+// 报价只使用一个池子（指定 poolAddress，或自动路由只有一跳且没有拆单）时为该池子；
+// 多跳或拆单的报价无法写成一个 indexPath，返回 ErrUnverifiableQuote
+func (q *Quote) verifyIndexPath(req *QuoteRequest, resp *QuoteResponse) ([]uint32, []string, error) {
+	if len(req.IndexPath) > 0 {
+		order, err := q.indexPathPools(req.TokenIn, req.TokenOut, req.IndexPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		pools := make([]string, len(order))
+		for i, pool := range order {
+			pools[i] = pool.Address
+		}
+		return req.IndexPath, pools, nil
+	}
+
+	if resp.PoolAddress == "" {
+		return nil, nil, ErrUnverifiableQuote
+	}
+	pool, err := q.GetPoolState(resp.PoolAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pool.PoolIndex == nil {
+		return nil, nil, fmt.Errorf("池子 %s 的 pool_index 未同步，无法构造 indexPath", pool.Address)
+	}
+	return []uint32{uint32(*pool.PoolIndex)}, []string{pool.Address}, nil
+}
+
+// VerifyQuoteExactInput 在 block 区块（报价所用池子快照的高度，为 0 时为最新区块）上按 indexPath 以相同的价格限制调用
+// quoteExactInput，与链下报价的 offchainAmountOut 对比；sqrtPriceLimitX96 为 nil 时使用 MIN_SQRT_PRICE + 1 / MAX_SQRT_PRICE - 1。
+// eth_call 失败时只在结果的 Error 中返回原因
+func (o *OnchainQuoter) VerifyQuoteExactInput(ctx context.Context, block int64, tokenIn, tokenOut string, indexPath []uint32, pools []string, amountIn, sqrtPriceLimitX96, offchainAmountOut *big.Int) *QuoteVerification {
+	verification := &QuoteVerification{
+		Block:             block,
+		IndexPath:         indexPath,
+		Pools:             pools,
+		OffchainAmountOut: offchainAmountOut.String(),
+	}
+
+	if sqrtPriceLimitX96 == nil {
+		sqrtPriceLimitX96 = defaultSqrtPriceLimit(isZeroForOne(tokenIn, tokenOut))
+	}
+	var blockNumber *big.Int
+	if block > 0 {
+		blockNumber = big.NewInt(block)
+	}
+
+	onchain, err := o.QuoteExactInput(ctx, tokenIn, tokenOut, indexPath, amountIn, sqrtPriceLimitX96, blockNumber)
+	if err != nil {
+		verification.Error = err.Error()
+		return verification
+	}
+
+	difference := new(big.Int).Sub(onchain, offchainAmountOut)
+	verification.OnchainAmountOut = onchain.String()
+	verification.Difference = difference.String()
+	verification.Match = difference.Sign() == 0
+	if onchain.Sign() > 0 {
+		bps, _ := new(big.Float).Quo(new(big.Float).SetInt(difference), new(big.Float).SetInt(onchain)).Float64()
+		verification.DifferenceBps = bps * 10000
+	}
+	return verification
+}
+
+// QuoteSample 报价校验的一个样本
+type QuoteSample struct {
+	TokenIn     string
+	TokenOut    string
+	PoolAddress string // 为空时使用交易对的所有池子
+	AmountIn    *big.Int
+}
+
+// SampleQuotes 从已同步 pool_index 的有流动性池子中随机生成 n 个报价样本：随机方向，
+// 一半只用单个池子、一半使用交易对的所有池子；输入金额在池子最多可成交输入的 10^-6 到 120% 之间按对数均匀分布，
+// 覆盖小额成交和到达价格区间边界后部分成交的情况
+func (q *Quote) SampleQuotes(rnd *rand.Rand, n int) ([]QuoteSample, error) {
+	all, err := q.loadRoutablePools()
+	if err != nil {
+		return nil, fmt.Errorf("加载池子失败: %w", err)
+	}
+	var pools []*PoolState
+	for _, pool := range all {
+		if pool.PoolIndex != nil {
+			pools = append(pools, pool)
+		}
+	}
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("没有可用的池子")
+	}
+
+	samples := make([]QuoteSample, 0, n)
+	for attempts := 0; len(samples) < n && attempts < n*10; attempts++ {
+		pool := pools[rnd.Intn(len(pools))]
+		zeroForOne := rnd.Intn(2) == 0
+		capacity := q.poolInputCapacity(pool, zeroForOne)
+		if capacity == nil || capacity.Sign() == 0 {
+			continue
+		}
+
+		fraction := 1.2 * math.Pow(10, -6*rnd.Float64())
+		amountIn, _ := new(big.Float).Mul(new(big.Float).SetInt(capacity), big.NewFloat(fraction)).Int(nil)
+		if amountIn.Sign() == 0 {
+			amountIn.SetInt64(1)
+		}
+
+		sample := QuoteSample{TokenIn: pool.Token1, TokenOut: pool.Token0, AmountIn: amountIn}
+		if zeroForOne {
+			sample.TokenIn, sample.TokenOut = pool.Token0, pool.Token1
+		}
+		if rnd.Intn(2) == 0 {
+			sample.PoolAddress = pool.Address
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
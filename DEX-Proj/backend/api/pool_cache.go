@@ -0,0 +1,437 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PoolSnapshot 池子的内存快照：池子状态和按 tick_index 升序排列的 ticks
+// 快照创建后不再修改，刷新时整体替换，报价可以在不加锁的情况下读取
+type PoolSnapshot struct {
+	State *PoolState
+	Ticks []TickInfo
+	Block int64 // 快照对应的 pools.updated_block（最后一次写入池子状态的区块），迁移前写入的池子为 0
+}
+
+// TicksInRange 返回 [tickLower, tickUpper] 内的 ticks（二分查找）
+func (s *PoolSnapshot) TicksInRange(tickLower, tickUpper int64) []TickInfo {
+	from := sort.Search(len(s.Ticks), func(i int) bool { return s.Ticks[i].TickIndex >= tickLower })
+	to := sort.Search(len(s.Ticks), func(i int) bool { return s.Ticks[i].TickIndex > tickUpper })
+	if from >= to {
+		return nil
+	}
+	return s.Ticks[from:to]
+}
+
+// NextInitializedTick 返回 tick 在 lte 方向上最近的已初始化 tick：lte 为 true 时查找 <= tick 的最大值，否则查找 > tick 的最小值
+func (s *PoolSnapshot) NextInitializedTick(tick int64, lte bool) (TickInfo, bool) {
+	// i 为第一个 tick_index > tick 的位置
+	i := sort.Search(len(s.Ticks), func(i int) bool { return s.Ticks[i].TickIndex > tick })
+	if lte {
+		if i == 0 {
+			return TickInfo{}, false
+		}
+		return s.Ticks[i-1], true
+	}
+	if i == len(s.Ticks) {
+		return TickInfo{}, false
+	}
+	return s.Ticks[i], true
+}
+
+// PoolView 某一时刻所有池子快照的只读视图，创建后不再修改：
+// 一次报价（以及它的链上校验）在同一个视图上计算，读取的池子状态和区块高度一致
+type PoolView struct {
+	pools map[string]*PoolSnapshot // 小写池子地址 -> 快照
+	block int64                    // 视图对应的区块高度，见 PoolCache.Block
+}
+
+// Pool 返回池子的快照，地址大小写不敏感
+func (v *PoolView) Pool(address string) (*PoolSnapshot, bool) {
+	snapshot, ok := v.pools[strings.ToLower(address)]
+	return snapshot, ok
+}
+
+// Block 返回视图对应的区块高度
+func (v *PoolView) Block() int64 {
+	return v.block
+}
+
+// RoutablePools 返回有流动性且已初始化价格的池子，按 pool_index 升序（与 loadRoutablePools 的查询一致）
+func (v *PoolView) RoutablePools() []*PoolState {
+	pools := make([]*PoolState, 0, len(v.pools))
+	for _, snapshot := range v.pools {
+		if snapshot.State.Liquidity.Sign() > 0 && snapshot.State.SqrtPriceX96.Sign() > 0 {
+			pools = append(pools, snapshot.State)
+		}
+	}
+
+	sort.Slice(pools, func(i, j int) bool {
+		a, b := pools[i].PoolIndex, pools[j].PoolIndex
+		switch {
+		case a != nil && b != nil && *a != *b:
+			return *a < *b
+		case (a == nil) != (b == nil):
+			// 未同步 pool_index 的池子排在最后（与 ORDER BY pool_index ASC 一致）
+			return a != nil
+		default:
+			return pools[i].Address < pools[j].Address
+		}
+	})
+	return pools
+}
+
+// PoolCache 所有池子的内存快照
+//
+// Load 全量加载 pools 和 ticks；之后 Refresh 按 pools.updated_block 增量刷新：
+// sync 服务每次写入池子状态（事件或从链上刷新）都会更新 updated_block，
+// 重新加载 updated_block 不低于上次刷新时的扫描高度的池子（与快照的 Block 相同也重新加载，同一区块可能写入多次）。
+// 上次刷新时的区块哈希与 blocks 表不一致说明 sync 服务回滚过 reorg，此时全量重新加载。
+type PoolCache struct {
+	db *sql.DB
+
+	refreshMu sync.Mutex // 串行执行 Load、Refresh 和 RefreshPool，避免较旧的查询结果覆盖较新的快照
+
+	mu        sync.RWMutex
+	view      *PoolView // 当前视图，刷新时复制后整体替换
+	block     int64     // 上次全量或增量刷新时的扫描高度
+	blockHash string    // 该区块的哈希，blocks 表中没有记录时为空
+	latest    int64     // 快照中最大的 Block
+}
+
+// NewPoolCache 创建池子缓存，使用前需要调用 Load
+func NewPoolCache(db *sql.DB) *PoolCache {
+	return &PoolCache{
+		db:   db,
+		view: &PoolView{pools: make(map[string]*PoolSnapshot)},
+	}
+}
+
+// View 返回当前视图，之后的刷新不会修改它
+func (c *PoolCache) View() *PoolView {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.view
+}
+
+// Pool 返回当前视图中池子的快照，地址大小写不敏感
+func (c *PoolCache) Pool(address string) (*PoolSnapshot, bool) {
+	return c.View().Pool(address)
+}
+
+// Block 返回快照对应的区块高度：扫描高度与快照中最新的 updated_block 取较大值
+// （RefreshPool 收到通知或 sync 服务按最新区块刷新池子时，快照可能比 indexed_status 的扫描高度更新）
+func (c *PoolCache) Block() int64 {
+	return c.View().Block()
+}
+
+// RoutablePools 返回当前视图中有流动性且已初始化价格的池子，按 pool_index 升序
+func (c *PoolCache) RoutablePools() []*PoolState {
+	return c.View().RoutablePools()
+}
+
+// updatePools 复制当前视图并替换 changed 中的池子（值为 nil 时移除），生成新的视图（调用方持有 c.mu 写锁）
+func (c *PoolCache) updatePools(changed map[string]*PoolSnapshot) {
+	pools := c.view.pools
+	if len(changed) > 0 {
+		pools = make(map[string]*PoolSnapshot, len(c.view.pools)+len(changed))
+		for addr, snapshot := range c.view.pools {
+			pools[addr] = snapshot
+		}
+		for addr, snapshot := range changed {
+			if snapshot == nil {
+				delete(pools, addr)
+				continue
+			}
+			pools[addr] = snapshot
+			c.latest = max(c.latest, snapshot.Block)
+		}
+	}
+	c.view = &PoolView{pools: pools, block: max(c.block, c.latest)}
+}
+
+// Load 全量加载所有池子和 ticks
+func (c *PoolCache) Load() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	_, err := c.load()
+	return err
+}
+
+// load 全量加载所有池子和 ticks，返回新旧视图中所有池子的小写地址（包括被移除的池子）
+func (c *PoolCache) load() ([]string, error) {
+	block, hash, err := c.indexedHead()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.Query(`SELECT ` + snapshotColumns + ` FROM pools WHERE sqrt_price_x96 IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("加载池子失败: %w", err)
+	}
+	pools := make(map[string]*PoolSnapshot)
+	var latest int64
+	for rows.Next() {
+		snapshot, err := scanSnapshot(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("读取池子失败: %w", err)
+		}
+		pools[strings.ToLower(snapshot.State.Address)] = snapshot
+		latest = max(latest, snapshot.Block)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ticks, err := c.loadTicks("")
+	if err != nil {
+		return nil, err
+	}
+	for addr, poolTicks := range ticks {
+		if snapshot, ok := pools[addr]; ok {
+			snapshot.Ticks = poolTicks
+		}
+	}
+
+	c.mu.Lock()
+	reloaded := make([]string, 0, len(pools))
+	for addr := range pools {
+		reloaded = append(reloaded, addr)
+	}
+	for addr := range c.view.pools {
+		if _, ok := pools[addr]; !ok {
+			reloaded = append(reloaded, addr)
+		}
+	}
+	c.block, c.blockHash, c.latest = block, hash, latest
+	c.view = &PoolView{pools: pools, block: max(block, latest)}
+	c.mu.Unlock()
+
+	log.Printf("[PoolCache] Loaded %d pools at block %d", len(pools), block)
+	return reloaded, nil
+}
+
+// Refresh 增量刷新上次刷新之后有变化的池子，返回重新加载的池子的小写地址（全量重新加载时为新旧视图中的所有池子）
+// 扫描高度没有变化时也会检查 updated_block：sync 服务可能在扫描范围之外按最新区块刷新过池子状态
+func (c *PoolCache) Refresh() ([]string, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	block, hash, err := c.indexedHead()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	lastBlock, lastHash := c.block, c.blockHash
+	c.mu.RUnlock()
+
+	// 扫描高度回退，或上次刷新时的区块哈希已经改变：sync 服务处理过 reorg，全量重新加载
+	if block < lastBlock {
+		return c.load()
+	}
+	if lastHash != "" && (block != lastBlock || hash != lastHash) {
+		currentHash, err := c.blockHashAt(lastBlock)
+		if err != nil {
+			return nil, err
+		}
+		if currentHash != lastHash {
+			return c.load()
+		}
+	}
+
+	updated, err := c.changedPools(lastBlock)
+	if err != nil {
+		return nil, err
+	}
+	// updated_block 与快照的 Block 相同时也要重新加载：按最新区块刷新的状态之后，同一区块的事件还会再写入池子
+	changed := make(map[string]*PoolSnapshot)
+	for _, addr := range updated {
+		snapshot, err := c.loadSnapshot(addr)
+		if err != nil {
+			return nil, err
+		}
+		changed[addr] = snapshot
+	}
+
+	// 变化的池子和新的扫描高度在同一个视图中生效
+	c.mu.Lock()
+	c.block, c.blockHash = block, hash
+	c.updatePools(changed)
+	c.mu.Unlock()
+
+	if len(changed) > 0 {
+		log.Printf("[PoolCache] Refreshed %d pools at block %d", len(changed), block)
+	}
+	return updated, nil
+}
+
+// RefreshPool 重新加载单个池子的状态、ticks 和 updated_block；池子不存在或价格未初始化时从缓存中移除
+func (c *PoolCache) RefreshPool(address string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	snapshot, err := c.loadSnapshot(address)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.updatePools(map[string]*PoolSnapshot{strings.ToLower(address): snapshot})
+	c.mu.Unlock()
+	return nil
+}
+
+// loadSnapshot 从数据库加载单个池子的快照（包括 ticks），池子不存在或价格未初始化时返回 nil
+func (c *PoolCache) loadSnapshot(address string) (*PoolSnapshot, error) {
+	snapshot, err := scanSnapshot(c.db.QueryRow(
+		`SELECT `+snapshotColumns+` FROM pools WHERE LOWER(address) = LOWER($1)`, address))
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errPoolPriceUninitialized) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("刷新池子 %s 失败: %w", address, err)
+	}
+
+	ticks, err := c.loadTicks(snapshot.State.Address)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Ticks = ticks[strings.ToLower(snapshot.State.Address)]
+	return snapshot, nil
+}
+
+// snapshotColumns 在 poolStateColumns 之后加上 updated_block，与 scanSnapshot 的字段顺序一致
+const snapshotColumns = poolStateColumns + `, updated_block`
+
+// scanSnapshot 按 snapshotColumns 的字段顺序读取池子状态和 updated_block，不包含 ticks
+func scanSnapshot(row rowScanner) (*PoolSnapshot, error) {
+	var updatedBlock sql.NullInt64
+	state, err := scanPoolState(extraColumns{row: row, dest: []interface{}{&updatedBlock}})
+	if err != nil {
+		return nil, err
+	}
+	return &PoolSnapshot{State: state, Block: updatedBlock.Int64}, nil
+}
+
+// extraColumns 读取 scanPoolState 的字段之后，继续把剩余的字段读取到 dest
+type extraColumns struct {
+	row  rowScanner
+	dest []interface{}
+}
+
+func (r extraColumns) Scan(dest ...interface{}) error {
+	return r.row.Scan(append(dest, r.dest...)...)
+}
+
+// Run 每隔 interval 调用一次 Refresh，直到 stop 被关闭
+func (c *PoolCache) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := c.Refresh(); err != nil {
+				log.Printf("[PoolCache] Refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+// loadTicks 按池子分组加载 ticks（按 tick_index 升序），poolAddress 为空时加载所有池子
+func (c *PoolCache) loadTicks(poolAddress string) (map[string][]TickInfo, error) {
+	query := `SELECT pool_address, tick_index, liquidity_gross, liquidity_net FROM ticks`
+	var args []interface{}
+	if poolAddress != "" {
+		query += ` WHERE pool_address = $1`
+		args = append(args, poolAddress)
+	}
+	query += ` ORDER BY pool_address, tick_index`
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("加载 ticks 失败: %w", err)
+	}
+	defer rows.Close()
+
+	ticks := make(map[string][]TickInfo)
+	for rows.Next() {
+		var addr string
+		var tick TickInfo
+		var liquidityGross, liquidityNet sql.NullString
+		if err := rows.Scan(&addr, &tick.TickIndex, &liquidityGross, &liquidityNet); err != nil {
+			return nil, err
+		}
+		tick.LiquidityGross = parseBigOrZero(liquidityGross)
+		tick.LiquidityNet = parseBigOrZero(liquidityNet)
+		addr = strings.ToLower(addr)
+		ticks[addr] = append(ticks[addr], tick)
+	}
+	return ticks, rows.Err()
+}
+
+// indexedHead 返回 sync 服务已提交的扫描高度及该区块的哈希
+func (c *PoolCache) indexedHead() (int64, string, error) {
+	block, err := queryIndexedBlock(c.db)
+	if err != nil {
+		return 0, "", err
+	}
+	hash, err := c.blockHashAt(block)
+	if err != nil {
+		return 0, "", err
+	}
+	return block, hash, nil
+}
+
+// queryIndexedBlock 查询 indexed_status 中 sync 服务已提交的扫描高度，没有记录时返回 0
+func queryIndexedBlock(db *sql.DB) (int64, error) {
+	var block sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(last_block) FROM indexed_status`).Scan(&block); err != nil {
+		return 0, fmt.Errorf("查询扫描高度失败: %w", err)
+	}
+	return block.Int64, nil
+}
+
+// blockHashAt 查询 blocks 表中记录的区块哈希，没有记录时返回空字符串
+func (c *PoolCache) blockHashAt(number int64) (string, error) {
+	var hash string
+	err := c.db.QueryRow(`SELECT hash FROM blocks WHERE number = $1 LIMIT 1`, number).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询区块 %d 的哈希失败: %w", number, err)
+	}
+	return hash, nil
+}
+
+// changedPools 查询 updated_block 不低于 sinceBlock 的池子，返回小写池子地址
+// sinceBlock 为上次刷新时的扫描高度：之后扫描的事件都在更高的区块，从链上刷新的状态不低于当时的扫描高度；
+// 与 sinceBlock 相等的区块也要返回（确认数为 0 时按最新区块刷新的状态可能恰好等于扫描高度）
+func (c *PoolCache) changedPools(sinceBlock int64) ([]string, error) {
+	rows, err := c.db.Query(`SELECT address FROM pools WHERE updated_block >= $1`, sinceBlock)
+	if err != nil {
+		return nil, fmt.Errorf("查询有变化的池子失败: %w", err)
+	}
+	defer rows.Close()
+
+	var pools []string
+	for rows.Next() {
+		var addr string
+		if err := rows.Scan(&addr); err != nil {
+			return nil, err
+		}
+		pools = append(pools, strings.ToLower(addr))
+	}
+	return pools, rows.Err()
+}
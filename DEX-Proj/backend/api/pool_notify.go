@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PoolChangesChannel sync 服务发送池子变化通知的 Postgres 频道（与 sync 的 scanner.PoolChangesChannel 一致）
+const PoolChangesChannel = "pool_changes"
+
+const (
+	// subscriberBuffer 每个订阅者的通知缓冲区大小，缓冲区满时丢弃新的通知
+	subscriberBuffer = 64
+	// listenerPingInterval 长时间没有通知时检查 LISTEN 连接是否存活的间隔
+	listenerPingInterval = 90 * time.Second
+)
+
+// PoolChange 池子状态变化通知
+// Block 为池子状态对应的区块高度，为 0 表示 sync 服务按链上最新区块查询的状态
+type PoolChange struct {
+	Pool  string `json:"pool"`
+	Block int64  `json:"block"`
+}
+
+// PoolNotifier LISTEN sync 服务的池子变化通知，刷新池子缓存后推送给订阅者
+//
+// sync 服务在区块范围的事务中发送 NOTIFY，通知在事务提交后才会送达，收到通知时数据库中已是新的状态。
+// 连接断开期间的通知会丢失，重新连接后通过 PoolCache.Refresh 按扫描高度补齐，并为重新加载的池子补发通知。
+type PoolNotifier struct {
+	connStr string
+	cache   *PoolCache // 为 nil 时只推送通知
+
+	mu          sync.Mutex
+	subscribers map[chan PoolChange]struct{}
+}
+
+// NewPoolNotifier 创建池子变化通知的监听器，connStr 为 lib/pq 的连接字符串
+func NewPoolNotifier(connStr string, cache *PoolCache) *PoolNotifier {
+	return &PoolNotifier{
+		connStr:     connStr,
+		cache:       cache,
+		subscribers: make(map[chan PoolChange]struct{}),
+	}
+}
+
+// Subscribe 订阅池子变化通知，返回通知 channel 和取消订阅的函数
+// 订阅者处理太慢、缓冲区已满时新的通知会被丢弃，不会阻塞其他订阅者
+func (n *PoolNotifier) Subscribe() (<-chan PoolChange, func()) {
+	ch := make(chan PoolChange, subscriberBuffer)
+
+	n.mu.Lock()
+	n.subscribers[ch] = struct{}{}
+	n.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			n.mu.Lock()
+			delete(n.subscribers, ch)
+			n.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Run LISTEN 池子变化通知并处理，直到 stop 被关闭；连接断开时 lib/pq 会自动重连
+func (n *PoolNotifier) Run(stop <-chan struct{}) error {
+	listener := pq.NewListener(n.connStr, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("[PoolNotifier] Listener event %d: %v", ev, err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(PoolChangesChannel); err != nil {
+		return err
+	}
+	log.Printf("[PoolNotifier] Listening on channel %s", PoolChangesChannel)
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case notification := <-listener.Notify:
+			if notification == nil {
+				// 重新连接后 lib/pq 发送 nil，断开期间的通知已经丢失
+				n.resync()
+				continue
+			}
+			n.handle(notification.Extra)
+		case <-time.After(listenerPingInterval):
+			go func() {
+				if err := listener.Ping(); err != nil {
+					log.Printf("[PoolNotifier] Ping failed: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// handle 刷新通知中的池子并推送给订阅者
+func (n *PoolNotifier) handle(payload string) {
+	var change PoolChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil || change.Pool == "" {
+		log.Printf("[PoolNotifier] Invalid notification payload %q: %v", payload, err)
+		return
+	}
+	change.Pool = strings.ToLower(change.Pool)
+
+	if n.cache != nil {
+		if err := n.cache.RefreshPool(change.Pool); err != nil {
+			log.Printf("[PoolNotifier] %v", err)
+		}
+	}
+	n.publish(change)
+}
+
+// resync 连接恢复后按扫描高度增量刷新缓存，并为重新加载的池子推送通知（代替断开期间丢失的通知）
+func (n *PoolNotifier) resync() {
+	if n.cache == nil {
+		return
+	}
+	reloaded, err := n.cache.Refresh()
+	if err != nil {
+		log.Printf("[PoolNotifier] Resync failed: %v", err)
+		return
+	}
+	view := n.cache.View()
+	for _, addr := range reloaded {
+		change := PoolChange{Pool: addr, Block: view.Block()}
+		if snapshot, ok := view.Pool(addr); ok {
+			change.Block = snapshot.Block
+		}
+		n.publish(change)
+	}
+}
+
+// publish 把通知推送给所有订阅者
+func (n *PoolNotifier) publish(change PoolChange) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subscribers {
+		select {
+		case ch <- change:
+		default:
+			log.Printf("[PoolNotifier] Subscriber buffer full, dropping notification for pool %s", change.Pool)
+		}
+	}
+}
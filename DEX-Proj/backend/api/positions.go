@@ -0,0 +1,245 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+
+	"dex-bot/pkg/swapmath"
+)
+
+// maxUint128 用于模拟 Solidity 中 uint128(...) 的截断
+var maxUint128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// PositionFees position 的手续费统计
+// Pool 的 tokensOwed 同时包含手续费和 Burn 退出的本金，collect 时一并领取，
+// 因此按「先领本金、后领手续费」的口径拆分：
+//
+//	已领取手续费 = max(0, 领取总额 - Burn 退出的本金)
+//	未领取手续费 = tokensOwed - 尚未领取的本金 + 尚未结算的手续费
+//
+// 尚未结算的手续费是上次 Mint/Burn 之后产生、还没有计入 tokensOwed 的部分，计算方式与 Pool._modifyPosition 相同
+type PositionFees struct {
+	PositionID      string `json:"positionId"`
+	Owner           string `json:"owner"`
+	PoolAddress     string `json:"poolAddress"`
+	Token0          string `json:"token0"`
+	Token1          string `json:"token1"`
+	Collected0      string `json:"collected0"`      // 累计领取的 token0（含本金）
+	Collected1      string `json:"collected1"`      // 累计领取的 token1（含本金）
+	Principal0      string `json:"principal0"`      // Burn 退出的 token0 本金
+	Principal1      string `json:"principal1"`      // Burn 退出的 token1 本金
+	TokensOwed0     string `json:"tokensOwed0"`     // 当前可领取的 token0（含本金）
+	TokensOwed1     string `json:"tokensOwed1"`     // 当前可领取的 token1（含本金）
+	Liquidity       string `json:"liquidity"`       // position 当前的流动性
+	UnsettledFee0   string `json:"unsettledFee0"`   // 上次结算后新产生、尚未计入 tokensOwed 的 token0 手续费
+	UnsettledFee1   string `json:"unsettledFee1"`   // 上次结算后新产生、尚未计入 tokensOwed 的 token1 手续费
+	CollectedFee0   string `json:"collectedFee0"`   // 已领取的 token0 手续费
+	CollectedFee1   string `json:"collectedFee1"`   // 已领取的 token1 手续费
+	UncollectedFee0 string `json:"uncollectedFee0"` // 已赚取但尚未领取的 token0 手续费
+	UncollectedFee1 string `json:"uncollectedFee1"` // 已赚取但尚未领取的 token1 手续费
+	UpdatedBlock    int64  `json:"updatedBlock"`    // tokensOwed 最后同步的区块
+}
+
+// PositionInfo position 信息
+type PositionInfo struct {
+	ID                   string    `json:"id"` // NFT tokenId 或虚拟 position ID
+	Owner                string    `json:"owner"`
+	PoolAddress          string    `json:"poolAddress"`
+	Token0               TokenInfo `json:"token0"`
+	Token1               TokenInfo `json:"token1"`
+	TickLower            int64     `json:"tickLower"`
+	TickUpper            int64     `json:"tickUpper"`
+	Liquidity            string    `json:"liquidity"`
+	TokensOwed0          string    `json:"tokensOwed0"`
+	TokensOwed1          string    `json:"tokensOwed1"`
+	TokensOwed0Formatted string    `json:"tokensOwed0Formatted,omitempty"`
+	TokensOwed1Formatted string    `json:"tokensOwed1Formatted,omitempty"`
+	CreatedBlock         *int64    `json:"createdBlock,omitempty"`
+	UpdatedBlock         *int64    `json:"updatedBlock,omitempty"`
+}
+
+// PositionFilter position 列表过滤条件
+type PositionFilter struct {
+	Owner       string
+	PoolAddress string
+	ActiveOnly  bool // 只返回流动性大于0的 position
+}
+
+// ListPositions 分页查询 position 列表，按创建区块倒序
+func (q *Quote) ListPositions(filter PositionFilter, page, limit int) (*PageResult, error) {
+	var where sqlFilter
+	if filter.Owner != "" {
+		where.add("LOWER(pos.owner) = LOWER(%s)", filter.Owner)
+	}
+	if filter.PoolAddress != "" {
+		where.add("LOWER(pos.pool_address) = LOWER(%s)", filter.PoolAddress)
+	}
+	if filter.ActiveOnly {
+		where.conds = append(where.conds, "pos.liquidity > 0")
+	}
+
+	total, err := q.count("FROM positions pos"+where.clause(), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询 position 数量失败: %w", err)
+	}
+
+	rows, err := q.db.Query(`
+		SELECT CAST(pos.id AS TEXT), pos.owner, pos.pool_address,
+			pos.token0, t0.symbol, t0.name, t0.decimals, pos.token1, t1.symbol, t1.name, t1.decimals,
+			pos.tick_lower, pos.tick_upper, pos.liquidity, pos.tokens_owed0, pos.tokens_owed1,
+			pos.created_block, pos.updated_block
+		FROM positions pos
+		LEFT JOIN tokens t0 ON t0.address = pos.token0
+		LEFT JOIN tokens t1 ON t1.address = pos.token1`+
+		where.clause()+where.page("ORDER BY pos.created_block DESC, pos.id", page, limit), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询 position 列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	positions := []PositionInfo{}
+	for rows.Next() {
+		var position PositionInfo
+		var token0, token1, symbol0, name0, symbol1, name1 sql.NullString
+		var decimals0, decimals1, createdBlock, updatedBlock sql.NullInt64
+		var liquidity, tokensOwed0, tokensOwed1 sql.NullString
+		if err := rows.Scan(&position.ID, &position.Owner, &position.PoolAddress,
+			&token0, &symbol0, &name0, &decimals0, &token1, &symbol1, &name1, &decimals1,
+			&position.TickLower, &position.TickUpper, &liquidity, &tokensOwed0, &tokensOwed1,
+			&createdBlock, &updatedBlock); err != nil {
+			return nil, err
+		}
+
+		position.Token0 = newTokenInfo(token0.String, symbol0, name0, decimals0)
+		position.Token1 = newTokenInfo(token1.String, symbol1, name1, decimals1)
+		position.Liquidity = parseBigOrZero(liquidity).String()
+		position.TokensOwed0 = parseBigOrZero(tokensOwed0).String()
+		position.TokensOwed1 = parseBigOrZero(tokensOwed1).String()
+		position.TokensOwed0Formatted = formatAmount(position.TokensOwed0, position.Token0.Decimals)
+		position.TokensOwed1Formatted = formatAmount(position.TokensOwed1, position.Token1.Decimals)
+		if createdBlock.Valid {
+			position.CreatedBlock = &createdBlock.Int64
+		}
+		if updatedBlock.Valid {
+			position.UpdatedBlock = &updatedBlock.Int64
+		}
+		positions = append(positions, position)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPageResult(positions, total, page, limit), nil
+}
+
+// GetPositionFees 统计 position 已领取和尚未领取的手续费
+// 数据来自 sync 服务写入的 positions、pools、collects 和 liquidity_events（BURN）
+func (q *Quote) GetPositionFees(positionID string) (*PositionFees, error) {
+	id, ok := new(big.Int).SetString(positionID, 10)
+	if !ok || id.Sign() < 0 {
+		return nil, fmt.Errorf("无效的 position ID: %s", positionID)
+	}
+
+	fees := PositionFees{PositionID: id.String()}
+	var tokensOwed0, tokensOwed1, liquidity, feeGrowthInside0Last, feeGrowthInside1Last sql.NullString
+	var feeGrowthGlobal0, feeGrowthGlobal1 sql.NullString
+	var updatedBlock sql.NullInt64
+	err := q.db.QueryRow(`
+		SELECT pos.owner, pos.pool_address, pos.token0, pos.token1, pos.tokens_owed0, pos.tokens_owed1, pos.updated_block,
+			pos.liquidity, pos.fee_growth_inside0_last_x128, pos.fee_growth_inside1_last_x128,
+			p.fee_growth_global0_x128, p.fee_growth_global1_x128
+		FROM positions pos
+		JOIN pools p ON p.address = pos.pool_address
+		WHERE pos.id = $1
+	`, id.String()).Scan(&fees.Owner, &fees.PoolAddress, &fees.Token0, &fees.Token1,
+		&tokensOwed0, &tokensOwed1, &updatedBlock,
+		&liquidity, &feeGrowthInside0Last, &feeGrowthInside1Last,
+		&feeGrowthGlobal0, &feeGrowthGlobal1)
+	if err != nil {
+		return nil, err
+	}
+	if updatedBlock.Valid {
+		fees.UpdatedBlock = updatedBlock.Int64
+	}
+
+	var collected0, collected1 sql.NullString
+	err = q.db.QueryRow(`
+		SELECT CAST(SUM(amount0) AS TEXT), CAST(SUM(amount1) AS TEXT)
+		FROM collects
+		WHERE position_id = $1
+	`, id.String()).Scan(&collected0, &collected1)
+	if err != nil {
+		return nil, fmt.Errorf("查询领取记录失败: %w", err)
+	}
+
+	var principal0, principal1 sql.NullString
+	err = q.db.QueryRow(`
+		SELECT CAST(SUM(amount0) AS TEXT), CAST(SUM(amount1) AS TEXT)
+		FROM liquidity_events
+		WHERE position_id = $1 AND type = 'BURN'
+	`, id.String()).Scan(&principal0, &principal1)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Burn 记录失败: %w", err)
+	}
+
+	fees.Collected0 = parseBigOrZero(collected0).String()
+	fees.Collected1 = parseBigOrZero(collected1).String()
+	fees.Principal0 = parseBigOrZero(principal0).String()
+	fees.Principal1 = parseBigOrZero(principal1).String()
+	fees.TokensOwed0 = parseBigOrZero(tokensOwed0).String()
+	fees.TokensOwed1 = parseBigOrZero(tokensOwed1).String()
+
+	fees.Liquidity = parseBigOrZero(liquidity).String()
+
+	unsettledFee0 := feesOwedSince(parseBigOrZero(feeGrowthGlobal0), parseBigOrZero(feeGrowthInside0Last), parseBigOrZero(liquidity))
+	unsettledFee1 := feesOwedSince(parseBigOrZero(feeGrowthGlobal1), parseBigOrZero(feeGrowthInside1Last), parseBigOrZero(liquidity))
+	fees.UnsettledFee0, fees.UnsettledFee1 = unsettledFee0.String(), unsettledFee1.String()
+
+	collectedFee0, uncollectedFee0 := splitFees(parseBigOrZero(collected0), parseBigOrZero(principal0), parseBigOrZero(tokensOwed0))
+	collectedFee1, uncollectedFee1 := splitFees(parseBigOrZero(collected1), parseBigOrZero(principal1), parseBigOrZero(tokensOwed1))
+	uncollectedFee0.Add(uncollectedFee0, unsettledFee0)
+	uncollectedFee1.Add(uncollectedFee1, unsettledFee1)
+	fees.CollectedFee0, fees.UncollectedFee0 = collectedFee0.String(), uncollectedFee0.String()
+	fees.CollectedFee1, fees.UncollectedFee1 = collectedFee1.String(), uncollectedFee1.String()
+
+	return &fees, nil
+}
+
+// splitFees 按「先领本金、后领手续费」把领取总额和 tokensOwed 拆分为已领取和未领取的手续费
+func splitFees(collected, principal, owed *big.Int) (collectedFee, uncollectedFee *big.Int) {
+	// 已领取手续费 = max(0, 领取总额 - 本金)
+	collectedFee = new(big.Int).Sub(collected, principal)
+	if collectedFee.Sign() < 0 {
+		collectedFee.SetInt64(0)
+	}
+
+	// 尚未领取的本金 = max(0, 本金 - 领取总额)，tokensOwed 中其余部分为手续费
+	pendingPrincipal := new(big.Int).Sub(principal, collected)
+	if pendingPrincipal.Sign() < 0 {
+		pendingPrincipal.SetInt64(0)
+	}
+	uncollectedFee = new(big.Int).Sub(owed, pendingPrincipal)
+	if uncollectedFee.Sign() < 0 {
+		uncollectedFee.SetInt64(0)
+	}
+
+	return collectedFee, uncollectedFee
+}
+
+// feesOwedSince 计算 position 上次结算之后新产生的手续费，对应 Pool._modifyPosition：
+// uint128(mulDiv(feeGrowthGlobalX128 - feeGrowthInsideLastX128, liquidity, Q128))
+// MetaNodeSwap 的池子只有一个固定价格区间，区间内的 feeGrowthInside 就是 feeGrowthGlobal
+func feesOwedSince(feeGrowthGlobalX128, feeGrowthInsideLastX128, liquidity *big.Int) *big.Int {
+	// 合约中的减法会在下溢时 revert，这里说明 pools 的 feeGrowthGlobal 还没有同步到 position 所在的区块
+	delta := new(big.Int).Sub(feeGrowthGlobalX128, feeGrowthInsideLastX128)
+	if delta.Sign() <= 0 || liquidity.Sign() == 0 {
+		return big.NewInt(0)
+	}
+	owed, err := swapmath.MulDiv(delta, liquidity, swapmath.Q128)
+	if err != nil {
+		return big.NewInt(0)
+	}
+	// uint128 强制转换会截断高位
+	return owed.And(owed, maxUint128)
+}
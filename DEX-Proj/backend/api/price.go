@@ -0,0 +1,71 @@
+package api
+
+import (
+	"math/big"
+	"strings"
+)
+
+// DefaultTokenDecimals tokens 表中没有记录的代币按 18 位精度计算价格（与 sync 服务 ensureToken 的默认值一致）
+const DefaultTokenDecimals = 18
+
+// priceFloatPrec 价格计算使用的 big.Float 精度
+const priceFloatPrec = 256
+
+// tokenDecimals 返回池子中代币的精度，tokens 表中没有记录时返回 DefaultTokenDecimals
+func (p *PoolState) tokenDecimals(token string) int64 {
+	decimals := p.Decimals1
+	if strings.EqualFold(token, p.Token0) {
+		decimals = p.Decimals0
+	}
+	if decimals == nil {
+		return DefaultTokenDecimals
+	}
+	return *decimals
+}
+
+// sqrtPriceRatio 返回 (sqrtPriceX96 / 2^96)^2，即最小单位下 1 token0 可兑换的 token1 数量
+func sqrtPriceRatio(sqrtPriceX96 *big.Int) *big.Float {
+	sqrtPrice := new(big.Float).SetPrec(priceFloatPrec).SetInt(sqrtPriceX96)
+	sqrtPrice.Quo(sqrtPrice, new(big.Float).SetPrec(priceFloatPrec).SetInt(new(big.Int).Lsh(big.NewInt(1), 96)))
+	return sqrtPrice.Mul(sqrtPrice, sqrtPrice)
+}
+
+// scaleDecimals 把 x 乘以 10^exp（exp 可以为负），用于把最小单位的比值换算为按精度调整后的价格
+func scaleDecimals(x *big.Float, exp int64) *big.Float {
+	scale := new(big.Float).SetPrec(priceFloatPrec).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(abs64(exp)), nil))
+	if exp >= 0 {
+		return x.Mul(x, scale)
+	}
+	return x.Quo(x, scale)
+}
+
+// midPrice 返回池子在 sqrtPriceX96 时的中间价：1 个 tokenIn 可兑换的 tokenOut 数量（按代币精度调整）
+// token0 -> token1 时为 (sqrtPriceX96 / 2^96)^2 * 10^(decimals0 - decimals1)，token1 -> token0 时取倒数
+func midPrice(poolState *PoolState, sqrtPriceX96 *big.Int, zeroForOne bool) *big.Float {
+	price := scaleDecimals(sqrtPriceRatio(sqrtPriceX96),
+		poolState.tokenDecimals(poolState.Token0)-poolState.tokenDecimals(poolState.Token1))
+	if zeroForOne || price.Sign() == 0 {
+		return price
+	}
+	return new(big.Float).SetPrec(priceFloatPrec).Quo(big.NewFloat(1).SetPrec(priceFloatPrec), price)
+}
+
+// executionPrice 返回成交均价：1 个 tokenIn 实际兑换到的 tokenOut 数量（按代币精度调整，包含手续费）
+func executionPrice(amountIn, amountOut *big.Int, decimalsIn, decimalsOut int64) *big.Float {
+	price := new(big.Float).SetPrec(priceFloatPrec)
+	if amountIn.Sign() == 0 {
+		return price
+	}
+	price.Quo(new(big.Float).SetPrec(priceFloatPrec).SetInt(amountOut), new(big.Float).SetPrec(priceFloatPrec).SetInt(amountIn))
+	return scaleDecimals(price, decimalsIn-decimalsOut)
+}
+
+// priceImpact 返回 price 相对 reference 变差的百分比：(1 - price / reference) * 100
+// 价格均以 tokenOut/tokenIn 表示，成交后价格下降，结果为正
+func priceImpact(reference, price *big.Float) float64 {
+	if reference.Sign() <= 0 {
+		return 0
+	}
+	ratio, _ := new(big.Float).SetPrec(priceFloatPrec).Quo(price, reference).Float64()
+	return (1 - ratio) * 100
+}
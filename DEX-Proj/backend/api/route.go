@@ -0,0 +1,432 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+)
+
+// maxRouteHops 路由搜索的最大跳数（1 跳直连 + 2 跳、3 跳中转）
+const maxRouteHops = 3
+
+// RouteHop 路由中的单跳信息
+type RouteHop struct {
+	PoolAddress    string      `json:"poolAddress"`    // 该跳使用的池子地址（拆单时为分配金额最多的池子）
+	TokenIn        string      `json:"tokenIn"`        // 该跳输入代币
+	TokenOut       string      `json:"tokenOut"`       // 该跳输出代币
+	AmountIn       string      `json:"amountIn"`       // 该跳输入金额
+	AmountOut      string      `json:"amountOut"`      // 该跳输出金额
+	Fee            int64       `json:"fee"`            // 该跳池子手续费（拆单时为分配金额最多的池子）
+	PriceImpact    float64     `json:"priceImpact"`    // 该跳成交均价相对中间价变差的百分比（拆单时按输入金额加权）
+	MidPriceImpact float64     `json:"midPriceImpact"` // 该跳中间价变差的百分比（拆单时按输入金额加权）
+	ExecutionPrice string      `json:"executionPrice"` // 该跳成交均价 amountOut/amountIn（按精度调整）
+	CrossedTicks   int         `json:"crossedTicks"`   // 该跳跨越的tick数量
+	Splits         []PoolSplit `json:"splits"`         // 该跳在同一交易对多个池子间的拆单明细
+
+	AmountInUsed      string `json:"amountInUsed"`      // 该跳实际消耗的输入金额
+	AmountInRemaining string `json:"amountInRemaining"` // 该跳所有池子的价格区间都被耗尽后未成交的输入金额
+	PartialFill       bool   `json:"partialFill"`       // 该跳只成交了部分输入
+}
+
+// setFill 按实际消耗的输入记录该跳的成交情况
+func (h *RouteHop) setFill(amountIn, used *big.Int) {
+	remaining := new(big.Int).Sub(amountIn, used)
+	if remaining.Sign() < 0 {
+		remaining.SetInt64(0)
+	}
+	h.AmountInUsed = used.String()
+	h.AmountInRemaining = remaining.String()
+	h.PartialFill = remaining.Sign() > 0
+}
+
+// RouteResult 路由搜索结果
+type RouteResult struct {
+	Path           []string   `json:"path"`           // 代币路径 tokenIn -> ... -> tokenOut
+	Hops           []RouteHop `json:"hops"`           // 每一跳的详情
+	AmountIn       string     `json:"amountIn"`       // 总输入金额
+	AmountOut      string     `json:"amountOut"`      // 最终输出金额
+	PriceImpact    float64    `json:"priceImpact"`    // 整条路径的复合价格影响百分比：1 - ∏(1 - 每跳价格影响)
+	MidPriceImpact float64    `json:"midPriceImpact"` // 整条路径的复合中间价变化百分比
+	ExecutionPrice string     `json:"executionPrice"` // 整条路径的成交均价 amountOut/amountIn（按精度调整）
+
+	AmountInUsed      string `json:"amountInUsed"`      // 第一跳实际消耗的输入金额
+	AmountInRemaining string `json:"amountInRemaining"` // 第一跳未成交的输入金额（tokenIn）
+	PartialFill       bool   `json:"partialFill"`       // 任意一跳只成交了部分输入；中间跳未成交的数量见 hops
+}
+
+// tokenDecimals 返回 token 的精度，从 token 与 neighbor 交易对的池子中读取
+func (g *tokenGraph) tokenDecimals(token, neighbor string) int64 {
+	pools := g.pairPools[pairKey(token, neighbor)]
+	if len(pools) == 0 {
+		return DefaultTokenDecimals
+	}
+	return pools[0].tokenDecimals(token)
+}
+
+// setPathPrices 按每一跳的价格影响计算路径的复合价格影响，并按首尾代币的精度计算成交均价
+func (r *RouteResult) setPathPrices(graph *tokenGraph, amountIn, amountOut *big.Int) {
+	impactFactor, midFactor := 1.0, 1.0
+	for _, hop := range r.Hops {
+		impactFactor *= 1 - hop.PriceImpact/100
+		midFactor *= 1 - hop.MidPriceImpact/100
+	}
+	r.PriceImpact = (1 - impactFactor) * 100
+	r.MidPriceImpact = (1 - midFactor) * 100
+
+	last := len(r.Path) - 1
+	r.ExecutionPrice = formatPrice(executionPrice(amountIn, amountOut,
+		graph.tokenDecimals(r.Path[0], r.Path[1]), graph.tokenDecimals(r.Path[last], r.Path[last-1])))
+}
+
+// tokenGraph 由 pools 表构建的代币图
+// 节点是代币（小写地址），边是连接两个代币的池子
+type tokenGraph struct {
+	neighbors map[string]map[string]bool // token -> 相邻 token 集合
+	pairPools map[string][]*PoolState    // pairKey(tokenA, tokenB) -> 该交易对的所有池子
+}
+
+// pairKey 生成与方向无关的交易对键
+func pairKey(tokenA, tokenB string) string {
+	a, b := strings.ToLower(tokenA), strings.ToLower(tokenB)
+	if a > b {
+		a, b = b, a
+	}
+	return a + "/" + b
+}
+
+// loadRoutablePools 加载所有可用于路由的池子（有流动性且已初始化价格），启用池子缓存时从内存快照读取
+func (q *Quote) loadRoutablePools() ([]*PoolState, error) {
+	if view := q.pools(); view != nil {
+		return view.RoutablePools(), nil
+	}
+
+	rows, err := q.db.Query(`
+		SELECT ` + poolStateColumns + `
+		FROM pools
+		WHERE liquidity > 0 AND sqrt_price_x96 > 0
+		ORDER BY pool_index ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pools []*PoolState
+	for rows.Next() {
+		state, err := scanPoolState(rows)
+		if err != nil {
+			continue
+		}
+		pools = append(pools, state)
+	}
+
+	return pools, rows.Err()
+}
+
+// parseBigOrZero 将数据库中的 NUMERIC 字符串解析为 big.Int，空值或非法值返回 0
+func parseBigOrZero(value sql.NullString) *big.Int {
+	if value.Valid && value.String != "" {
+		if v, ok := new(big.Int).SetString(value.String, 10); ok {
+			return v
+		}
+	}
+	return big.NewInt(0)
+}
+
+// buildTokenGraph 根据池子列表构建代币图
+func buildTokenGraph(pools []*PoolState) *tokenGraph {
+	graph := &tokenGraph{
+		neighbors: make(map[string]map[string]bool),
+		pairPools: make(map[string][]*PoolState),
+	}
+
+	for _, pool := range pools {
+		token0 := strings.ToLower(pool.Token0)
+		token1 := strings.ToLower(pool.Token1)
+
+		if graph.neighbors[token0] == nil {
+			graph.neighbors[token0] = make(map[string]bool)
+		}
+		if graph.neighbors[token1] == nil {
+			graph.neighbors[token1] = make(map[string]bool)
+		}
+		graph.neighbors[token0][token1] = true
+		graph.neighbors[token1][token0] = true
+
+		key := pairKey(token0, token1)
+		graph.pairPools[key] = append(graph.pairPools[key], pool)
+	}
+
+	return graph
+}
+
+// findPaths 深度优先搜索 tokenIn 到 tokenOut 的所有路径（不重复经过同一代币）
+func (g *tokenGraph) findPaths(tokenIn, tokenOut string, maxHops int) [][]string {
+	var paths [][]string
+	visited := map[string]bool{tokenIn: true}
+
+	var dfs func(current string, path []string)
+	dfs = func(current string, path []string) {
+		if current == tokenOut {
+			paths = append(paths, append([]string(nil), path...))
+			return
+		}
+		if len(path)-1 >= maxHops {
+			return
+		}
+		for next := range g.neighbors[current] {
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			dfs(next, append(path, next))
+			visited[next] = false
+		}
+	}
+
+	dfs(tokenIn, []string{tokenIn})
+	return paths
+}
+
+// FindBestRoute 在 pools 表构建的代币图上搜索最佳路由（直连、2 跳、3 跳）
+//
+// 每条候选路径逐跳执行 swapExactInput，上一跳的输出作为下一跳的输入；
+// 同一交易对存在多个池子时，该跳的输入金额在这些池子间拆分（见 splitHop）。最终返回输出最多的路径。
+func (q *Quote) FindBestRoute(tokenIn, tokenOut, amountIn string) (*RouteResult, error) {
+	amountInBig, ok := new(big.Int).SetString(amountIn, 10)
+	if !ok {
+		return nil, fmt.Errorf("无效的输入金额: %s", amountIn)
+	}
+	if amountInBig.Cmp(big.NewInt(0)) <= 0 {
+		return nil, fmt.Errorf("输入金额必须大于0")
+	}
+
+	graph, paths, err := q.candidatePaths(tokenIn, tokenOut)
+	if err != nil {
+		return nil, err
+	}
+
+	// 缓存单跳计算结果，不同路径共享相同的第一跳时无需重复计算
+	hopCache := make(map[string]*RouteHop)
+
+	var best *RouteResult
+	var bestOut *big.Int
+	for _, path := range paths {
+		route, out := q.evaluatePath(graph, path, amountInBig, hopCache)
+		if route == nil {
+			continue
+		}
+
+		log.Printf("[Route] Path %s: amountOut=%s", strings.Join(path, " -> "), route.AmountOut)
+
+		// 输出更多的路径更优；输出相同时跳数更少的路径更优
+		if best == nil || out.Cmp(bestOut) > 0 ||
+			(out.Cmp(bestOut) == 0 && len(route.Hops) < len(best.Hops)) {
+			best = route
+			bestOut = out
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("所有路径的输出均为0，流动性不足")
+	}
+
+	return best, nil
+}
+
+// candidatePaths 构建代币图并搜索 tokenIn 到 tokenOut 的所有候选路径
+func (q *Quote) candidatePaths(tokenIn, tokenOut string) (*tokenGraph, [][]string, error) {
+	tokenInLower := strings.ToLower(tokenIn)
+	tokenOutLower := strings.ToLower(tokenOut)
+	if tokenInLower == tokenOutLower {
+		return nil, nil, fmt.Errorf("输入代币和输出代币不能相同")
+	}
+
+	pools, err := q.loadRoutablePools()
+	if err != nil {
+		return nil, nil, fmt.Errorf("加载池子失败: %w", err)
+	}
+
+	graph := buildTokenGraph(pools)
+	paths := graph.findPaths(tokenInLower, tokenOutLower, maxRouteHops)
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("未找到 %s -> %s 的交易路径", tokenIn, tokenOut)
+	}
+
+	log.Printf("[Route] Found %d candidate paths for %s -> %s", len(paths), tokenIn, tokenOut)
+
+	return graph, paths, nil
+}
+
+// FindBestRouteExactOutput 搜索得到指定 amountOut 所需输入最少的路由（直连、2 跳、3 跳）
+//
+// 每条候选路径从最后一跳开始反向执行 swapExactOutput，后一跳所需的输入作为前一跳的输出；
+// 同一交易对存在多个池子时，该跳选择所需输入最少的池子（精确输出不拆单）。最终返回总输入最少的路径。
+func (q *Quote) FindBestRouteExactOutput(tokenIn, tokenOut, amountOut string) (*RouteResult, error) {
+	amountOutBig, ok := new(big.Int).SetString(amountOut, 10)
+	if !ok {
+		return nil, fmt.Errorf("无效的输出金额: %s", amountOut)
+	}
+	if amountOutBig.Cmp(big.NewInt(0)) <= 0 {
+		return nil, fmt.Errorf("输出金额必须大于0")
+	}
+
+	graph, paths, err := q.candidatePaths(tokenIn, tokenOut)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *RouteResult
+	var bestIn *big.Int
+	var lastErr error
+	for _, path := range paths {
+		route, in, err := q.evaluatePathExactOutput(graph, path, amountOutBig)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		log.Printf("[Route] Path %s: amountIn=%s", strings.Join(path, " -> "), route.AmountIn)
+
+		// 所需输入更少的路径更优；输入相同时跳数更少的路径更优
+		if best == nil || in.Cmp(bestIn) < 0 ||
+			(in.Cmp(bestIn) == 0 && len(route.Hops) < len(best.Hops)) {
+			best = route
+			bestIn = in
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("没有路径能够输出 %s: %v", amountOut, lastErr)
+	}
+
+	return best, nil
+}
+
+// evaluatePathExactOutput 沿给定的代币路径从最后一跳反向计算精确输出报价
+func (q *Quote) evaluatePathExactOutput(graph *tokenGraph, path []string, amountOut *big.Int) (*RouteResult, *big.Int, error) {
+	hops := make([]RouteHop, len(path)-1)
+	currentAmount := new(big.Int).Set(amountOut)
+
+	for i := len(path) - 2; i >= 0; i-- {
+		hopIn, hopOut := path[i], path[i+1]
+
+		hop, err := q.bestHopExactOutput(graph.pairPools[pairKey(hopIn, hopOut)], hopIn, hopOut, currentAmount)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hops[i] = *hop
+		currentAmount, _ = new(big.Int).SetString(hop.AmountIn, 10)
+	}
+
+	route := &RouteResult{
+		Path:              path,
+		Hops:              hops,
+		AmountIn:          currentAmount.String(),
+		AmountOut:         amountOut.String(),
+		AmountInUsed:      currentAmount.String(),
+		AmountInRemaining: "0",
+	}
+	route.setPathPrices(graph, currentAmount, amountOut)
+
+	return route, currentAmount, nil
+}
+
+// bestHopExactOutput 在同一交易对的多个池子中选择输出 amountOut 所需输入最少的池子
+func (q *Quote) bestHopExactOutput(pools []*PoolState, tokenIn, tokenOut string, amountOut *big.Int) (*RouteHop, error) {
+	var best *RouteHop
+	var bestIn *big.Int
+	var lastErr error
+
+	for _, pool := range pools {
+		result, err := q.quoteExactOutputInPool(pool, tokenIn, amountOut, nil)
+		if err != nil {
+			log.Printf("[Route] Skip pool %s for hop %s -> %s: %v", pool.Address, tokenIn, tokenOut, err)
+			lastErr = err
+			continue
+		}
+
+		in, ok := new(big.Int).SetString(result.AmountIn, 10)
+		if !ok {
+			continue
+		}
+
+		if best == nil || in.Cmp(bestIn) < 0 {
+			best = &RouteHop{
+				PoolAddress:    pool.Address,
+				TokenIn:        tokenIn,
+				TokenOut:       tokenOut,
+				AmountIn:       result.AmountIn,
+				AmountOut:      result.AmountOut,
+				Fee:            pool.Fee,
+				PriceImpact:    result.PriceImpact,
+				MidPriceImpact: result.MidPriceImpact,
+				ExecutionPrice: result.ExecutionPrice,
+				CrossedTicks:   result.CrossedTicks,
+				Splits: []PoolSplit{{
+					PoolAddress:    pool.Address,
+					PoolIndex:      pool.PoolIndex,
+					Fee:            pool.Fee,
+					Percent:        100,
+					AmountIn:       result.AmountIn,
+					AmountOut:      result.AmountOut,
+					PriceImpact:    result.PriceImpact,
+					MidPriceImpact: result.MidPriceImpact,
+					AmountInUsed:   result.AmountInUsed,
+					result:         result,
+				}},
+			}
+			best.setFill(in, in)
+			bestIn = in
+		}
+	}
+
+	if best == nil {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("交易对 %s/%s 没有可用的池子", tokenIn, tokenOut)
+		}
+		return nil, lastErr
+	}
+
+	return best, nil
+}
+
+// evaluatePath 沿给定的代币路径逐跳计算报价，任意一跳输出为0时返回 nil
+func (q *Quote) evaluatePath(graph *tokenGraph, path []string, amountIn *big.Int, hopCache map[string]*RouteHop) (*RouteResult, *big.Int) {
+	route := &RouteResult{
+		Path:     path,
+		AmountIn: amountIn.String(),
+	}
+
+	currentAmount := new(big.Int).Set(amountIn)
+
+	for i := 0; i < len(path)-1; i++ {
+		hopIn, hopOut := path[i], path[i+1]
+
+		cacheKey := hopIn + "->" + hopOut + ":" + currentAmount.String()
+		hop, cached := hopCache[cacheKey]
+		if !cached {
+			hop = q.splitHop(graph.pairPools[pairKey(hopIn, hopOut)], hopIn, hopOut, currentAmount)
+			hopCache[cacheKey] = hop
+		}
+		if hop == nil {
+			return nil, nil
+		}
+
+		route.Hops = append(route.Hops, *hop)
+		route.PartialFill = route.PartialFill || hop.PartialFill
+		currentAmount, _ = new(big.Int).SetString(hop.AmountOut, 10)
+	}
+
+	// 第一跳未成交的输入不会被消耗，成交均价按实际消耗的输入计算
+	used, _ := new(big.Int).SetString(route.Hops[0].AmountInUsed, 10)
+	route.AmountInUsed = route.Hops[0].AmountInUsed
+	route.AmountInRemaining = route.Hops[0].AmountInRemaining
+	route.AmountOut = currentAmount.String()
+	route.setPathPrices(graph, used, currentAmount)
+
+	return route, currentAmount
+}
@@ -16,5 +16,21 @@ func SetupRoutes(r *gin.Engine, handler *Handler) {
 	{
 		// 报价相关
 		v1.POST("/quote", handler.GetQuote)
+		v1.POST("/swap/tx", handler.BuildSwapTx)
+		v1.GET("/stream", handler.StreamQuotes)
+
+		// 池子和代币
+		v1.GET("/pools", handler.ListPools)
+		v1.GET("/pools/:address", handler.GetPool)
+		v1.GET("/pools/:address/swaps", handler.ListPoolSwaps)
+		v1.GET("/pools/:address/liquidity-events", handler.ListPoolLiquidityEvents)
+		v1.GET("/pools/:address/candles", handler.GetPoolCandles)
+		v1.GET("/pools/:address/depth", handler.GetPoolDepth)
+		v1.GET("/pairs/:tokenA/:tokenB/depth", handler.GetPairDepth)
+		v1.GET("/tokens", handler.ListTokens)
+
+		// Position 相关
+		v1.GET("/positions", handler.ListPositions)
+		v1.GET("/positions/:id/fees", handler.GetPositionFees)
 	}
 }
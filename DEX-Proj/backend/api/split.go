@@ -0,0 +1,215 @@
+package api
+
+import (
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// splitChunks 拆单时将输入金额划分的份数（每份 5%）
+const splitChunks = 20
+
+// PoolSplit 同一交易对多个池子之间的拆单明细
+type PoolSplit struct {
+	PoolAddress    string  `json:"poolAddress"`    // 池子地址
+	PoolIndex      *int64  `json:"poolIndex"`      // 池子在交易对下的序号（对应 SwapRouter 的 indexPath），未同步时为 null
+	Fee            int64   `json:"fee"`            // 池子手续费
+	Percent        float64 `json:"percent"`        // 分配到该池子的输入金额百分比
+	AmountIn       string  `json:"amountIn"`       // 分配到该池子的输入金额
+	AmountOut      string  `json:"amountOut"`      // 该池子的输出金额
+	PriceImpact    float64 `json:"priceImpact"`    // 该池子成交均价相对中间价变差的百分比
+	MidPriceImpact float64 `json:"midPriceImpact"` // 该池子中间价变差的百分比
+
+	AmountInUsed string         `json:"amountInUsed"`          // 该池子实际消耗的输入金额，价格到达区间边界时小于 amountIn
+	PartialFill  bool           `json:"partialFill"`           // 该池子的价格区间被耗尽
+	BoundaryHit  *RangeBoundary `json:"boundaryHit,omitempty"` // 该池子到达的价格区间边界
+
+	result *QuoteResult // 该池子完整的报价结果
+}
+
+// poolAllocation 拆单过程中单个池子的分配状态
+type poolAllocation struct {
+	pool     *PoolState
+	amountIn *big.Int
+	result   *QuoteResult
+	out      *big.Int
+
+	// 再分配一份输入金额后的报价，只在该池子被选中后重新计算
+	nextResult *QuoteResult
+	nextOut    *big.Int
+}
+
+// splitHop 将一跳的输入金额在同一交易对的多个池子之间拆分，使总输出最大
+//
+// MetaNodeSwap 中同一交易对可以有多个池子（不同的 index），每个池子有固定的价格区间和手续费。
+// 采用贪心算法：把 amountIn 分成 splitChunks 份，每一份都分配给"再多一份输入时输出增量最大"的池子。
+// 由于单个池子的边际输出随输入增加而递减，贪心分配可以得到接近最优的拆分。
+func (q *Quote) splitHop(pools []*PoolState, tokenIn, tokenOut string, amountIn *big.Int) *RouteHop {
+	if len(pools) == 0 {
+		return nil
+	}
+
+	chunks := int64(splitChunks)
+	if len(pools) == 1 || amountIn.Cmp(big.NewInt(chunks)) < 0 {
+		chunks = 1
+	}
+	chunk := new(big.Int).Div(amountIn, big.NewInt(chunks))
+	// 最后一份包含整除的余数，保证各池子的分配之和等于 amountIn
+	lastChunk := new(big.Int).Sub(amountIn, new(big.Int).Mul(chunk, big.NewInt(chunks-1)))
+
+	allocs := make([]*poolAllocation, 0, len(pools))
+	for _, pool := range pools {
+		allocs = append(allocs, &poolAllocation{
+			pool:     pool,
+			amountIn: big.NewInt(0),
+			out:      big.NewInt(0),
+		})
+	}
+
+	for i := int64(0); i < chunks; i++ {
+		size := chunk
+		if i == chunks-1 {
+			size = lastChunk
+		}
+
+		var best *poolAllocation
+		var bestGain *big.Int
+		for _, alloc := range allocs {
+			if alloc.nextResult == nil || alloc.nextOut == nil {
+				q.quoteNextChunk(alloc, tokenIn, size)
+			}
+			if alloc.nextResult == nil {
+				continue
+			}
+
+			gain := new(big.Int).Sub(alloc.nextOut, alloc.out)
+			if best == nil || gain.Cmp(bestGain) > 0 {
+				best = alloc
+				bestGain = gain
+			}
+		}
+
+		if best == nil {
+			log.Printf("[Split] No pool can quote hop %s -> %s", tokenIn, tokenOut)
+			return nil
+		}
+
+		best.amountIn.Add(best.amountIn, size)
+		best.result = best.nextResult
+		best.out = best.nextOut
+		best.nextResult, best.nextOut = nil, nil
+
+		// 最后一份的大小可能与之前不同，已缓存的下一份报价需要重新计算
+		if i == chunks-2 && lastChunk.Cmp(chunk) != 0 {
+			for _, alloc := range allocs {
+				alloc.nextResult, alloc.nextOut = nil, nil
+			}
+		}
+	}
+
+	return buildSplitHop(allocs, tokenIn, tokenOut, amountIn)
+}
+
+// remainderHop 把指定池子未成交的输入在交易对的其他池子之间拆单报价，没有其他池子能成交时返回 nil
+func (q *Quote) remainderHop(poolAddress, tokenIn, tokenOut string, remaining *big.Int) *RouteHop {
+	all, err := q.loadRoutablePools()
+	if err != nil {
+		log.Printf("[Split] Failed to load pools for remainder of %s: %v", poolAddress, err)
+		return nil
+	}
+
+	key := pairKey(tokenIn, tokenOut)
+	var others []*PoolState
+	for _, pool := range all {
+		if pairKey(pool.Token0, pool.Token1) == key && !strings.EqualFold(pool.Address, poolAddress) {
+			others = append(others, pool)
+		}
+	}
+	return q.splitHop(others, tokenIn, tokenOut, remaining)
+}
+
+// quoteNextChunk 计算池子在当前分配基础上再增加 size 输入后的报价
+func (q *Quote) quoteNextChunk(alloc *poolAllocation, tokenIn string, size *big.Int) {
+	candidate := new(big.Int).Add(alloc.amountIn, size)
+	result, err := q.quoteExactInputInPool(alloc.pool, tokenIn, candidate, nil)
+	if err != nil {
+		log.Printf("[Split] Skip pool %s: %v", alloc.pool.Address, err)
+		return
+	}
+
+	out, ok := new(big.Int).SetString(result.AmountOut, 10)
+	if !ok {
+		return
+	}
+
+	alloc.nextResult = result
+	alloc.nextOut = out
+}
+
+// buildSplitHop 汇总各池子的分配结果，总输出为0时返回 nil
+func buildSplitHop(allocs []*poolAllocation, tokenIn, tokenOut string, amountIn *big.Int) *RouteHop {
+	hop := &RouteHop{
+		TokenIn:  tokenIn,
+		TokenOut: tokenOut,
+		AmountIn: amountIn.String(),
+	}
+
+	totalOut := big.NewInt(0)
+	totalUsed := big.NewInt(0)
+	totalIn, _ := new(big.Float).SetInt(amountIn).Float64()
+	var largest *poolAllocation
+
+	for _, alloc := range allocs {
+		if alloc.amountIn.Sign() == 0 || alloc.result == nil {
+			continue
+		}
+
+		allocIn, _ := new(big.Float).SetInt(alloc.amountIn).Float64()
+		weight := allocIn / totalIn
+
+		hop.Splits = append(hop.Splits, PoolSplit{
+			PoolAddress:    alloc.pool.Address,
+			PoolIndex:      alloc.pool.PoolIndex,
+			Fee:            alloc.pool.Fee,
+			Percent:        weight * 100,
+			AmountIn:       alloc.amountIn.String(),
+			AmountOut:      alloc.out.String(),
+			PriceImpact:    alloc.result.PriceImpact,
+			MidPriceImpact: alloc.result.MidPriceImpact,
+			AmountInUsed:   alloc.result.AmountInUsed,
+			PartialFill:    alloc.result.PartialFill,
+			BoundaryHit:    alloc.result.BoundaryHit,
+			result:         alloc.result,
+		})
+
+		used, _ := new(big.Int).SetString(alloc.result.AmountInUsed, 10)
+		totalOut.Add(totalOut, alloc.out)
+		totalUsed.Add(totalUsed, used)
+		hop.PriceImpact += alloc.result.PriceImpact * weight
+		hop.MidPriceImpact += alloc.result.MidPriceImpact * weight
+		hop.CrossedTicks += alloc.result.CrossedTicks
+
+		if largest == nil || alloc.amountIn.Cmp(largest.amountIn) > 0 {
+			largest = alloc
+		}
+	}
+
+	if largest == nil || totalOut.Sign() <= 0 {
+		return nil
+	}
+
+	// 按分配比例从大到小排列
+	sort.SliceStable(hop.Splits, func(i, j int) bool {
+		return hop.Splits[i].Percent > hop.Splits[j].Percent
+	})
+
+	hop.PoolAddress = largest.pool.Address
+	hop.Fee = largest.pool.Fee
+	hop.AmountOut = totalOut.String()
+	hop.setFill(amountIn, totalUsed)
+	hop.ExecutionPrice = formatPrice(executionPrice(totalUsed, totalOut,
+		largest.pool.tokenDecimals(tokenIn), largest.pool.tokenDecimals(tokenOut)))
+
+	return hop
+}
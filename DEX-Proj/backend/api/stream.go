@@ -0,0 +1,371 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// 订阅频道
+const (
+	StreamChannelQuote = "quote" // 报价：池子状态变化时重新计算
+	StreamChannelPool  = "pool"  // 池子的价格、tick 和流动性
+)
+
+const (
+	// MaxStreamSubscriptions 每个连接最多的订阅数量
+	MaxStreamSubscriptions = 20
+
+	streamWriteTimeout = 10 * time.Second
+	streamPongTimeout  = 60 * time.Second
+	streamPingInterval = streamPongTimeout * 9 / 10
+	// streamCoalesceDelay 收到池子变化通知后等待的时间，期间的通知合并为一批处理。
+	// sync 服务在一个区块范围的事务中为每个池子各发送一条通知，提交后同时送达，合并后每个订阅每批只重新计算一次
+	streamCoalesceDelay = 100 * time.Millisecond
+)
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// 与 CORSMiddleware 一致，允许任意来源
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// StreamRequest 客户端发送的订阅消息
+type StreamRequest struct {
+	Type    string        `json:"type"`            // subscribe 或 unsubscribe
+	ID      string        `json:"id"`              // 客户端指定的订阅 ID，推送消息中原样返回
+	Channel string        `json:"channel"`         // quote 或 pool（subscribe 时必填）
+	Quote   *QuoteRequest `json:"quote,omitempty"` // channel 为 quote 时的报价参数，与 POST /api/v1/quote 相同
+	Pool    string        `json:"pool,omitempty"`  // channel 为 pool 时的池子地址
+}
+
+// StreamMessage 服务端推送的消息
+type StreamMessage struct {
+	Type    string      `json:"type"`              // quote、pool、unsubscribed 或 error
+	ID      string      `json:"id,omitempty"`      // 对应的订阅 ID
+	Block   int64       `json:"block,omitempty"`   // 计算所依据的池子状态的区块高度
+	Data    interface{} `json:"data,omitempty"`    // quote 为 QuoteResponse，pool 为 PoolDetail
+	Message string      `json:"message,omitempty"` // 错误信息
+}
+
+// streamSubscription 单个订阅及其上次推送的内容
+type streamSubscription struct {
+	id      string
+	channel string
+	quote   *QuoteRequest
+	pool    string          // 小写池子地址
+	pools   map[string]bool // 上次推送的报价使用的池子（小写地址）
+	last    []byte          // 上次推送的 data，内容不变时不重复推送
+	lastErr string          // 上次推送的错误，相同的错误不重复推送
+}
+
+// streamConn 一个 WebSocket 连接上的订阅
+type streamConn struct {
+	h    *Handler
+	conn *websocket.Conn
+
+	writeMu sync.Mutex // gorilla/websocket 不支持并发写
+
+	mu   sync.Mutex // 保护 subs 和订阅的推送状态，同时保证同一订阅的推送顺序；计算结果时不持有
+	subs map[string]*streamSubscription
+}
+
+// streamResult 订阅的一次计算结果
+type streamResult struct {
+	data  interface{}
+	pools map[string]bool // 报价使用的池子
+	err   error
+}
+
+// StreamQuotes godoc
+// @Summary 通过 WebSocket 订阅实时报价和池子价格
+// @Description 升级为 WebSocket 连接。客户端发送 {"type":"subscribe","id":"q1","channel":"quote","quote":{...}} 订阅报价，或 {"type":"subscribe","id":"p1","channel":"pool","pool":"0x..."} 订阅池子；{"type":"unsubscribe","id":"q1"} 取消订阅。
+// @Description 订阅后立即推送一次当前结果，之后每当相关池子的状态变化时推送新的 QuoteResponse 或 PoolDetail，消息中的 block 为计算所依据的区块高度。需要 PostgreSQL 的池子变化通知。
+// @Tags Quote
+// @Success 101 {object} StreamMessage
+// @Failure 503 {object} Response
+// @Router /api/v1/stream [get]
+func (h *Handler) StreamQuotes(c *gin.Context) {
+	if h.notifier == nil {
+		c.JSON(http.StatusServiceUnavailable, Response{
+			Code:    503,
+			Message: "池子变化通知未启用，无法推送实时报价",
+		})
+		return
+	}
+
+	conn, err := streamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 失败时已经向客户端返回了错误
+		log.Printf("[Stream] Upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	changes, unsubscribe := h.notifier.Subscribe()
+	defer unsubscribe()
+
+	sc := &streamConn{
+		h:    h,
+		conn: conn,
+		subs: make(map[string]*streamSubscription),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sc.readLoop()
+	}()
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	// 等待合并的池子变化：小写池子地址 -> 通知中最大的区块高度
+	pending := make(map[string]int64)
+	var flush <-chan time.Time
+
+	for {
+		select {
+		case <-done:
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			if block, exists := pending[change.Pool]; !exists || change.Block > block {
+				pending[change.Pool] = change.Block
+			}
+			if flush == nil {
+				flush = time.After(streamCoalesceDelay)
+			}
+		case <-flush:
+			sc.handleChanges(pending)
+			pending, flush = make(map[string]int64), nil
+		case <-ticker.C:
+			sc.writeMu.Lock()
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+			sc.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readLoop 读取客户端的订阅消息，直到连接关闭
+func (sc *streamConn) readLoop() {
+	sc.conn.SetReadDeadline(time.Now().Add(streamPongTimeout))
+	sc.conn.SetPongHandler(func(string) error {
+		return sc.conn.SetReadDeadline(time.Now().Add(streamPongTimeout))
+	})
+
+	for {
+		_, message, err := sc.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		sc.conn.SetReadDeadline(time.Now().Add(streamPongTimeout))
+
+		var req StreamRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			sc.send(StreamMessage{Type: "error", Message: "消息格式错误: " + err.Error()})
+			continue
+		}
+
+		switch req.Type {
+		case "subscribe":
+			sc.subscribe(&req)
+		case "unsubscribe":
+			sc.mu.Lock()
+			delete(sc.subs, req.ID)
+			sc.mu.Unlock()
+			sc.send(StreamMessage{Type: "unsubscribed", ID: req.ID})
+		default:
+			sc.send(StreamMessage{Type: "error", ID: req.ID, Message: "不支持的消息类型 " + req.Type})
+		}
+	}
+}
+
+// subscribe 校验订阅参数，注册订阅并立即推送一次当前结果
+func (sc *streamConn) subscribe(req *StreamRequest) {
+	if req.ID == "" {
+		sc.send(StreamMessage{Type: "error", Message: "订阅需要提供 id"})
+		return
+	}
+
+	sub := &streamSubscription{id: req.ID, channel: req.Channel}
+	switch req.Channel {
+	case StreamChannelQuote:
+		if req.Quote == nil || req.Quote.TokenIn == "" || req.Quote.TokenOut == "" {
+			sc.send(StreamMessage{Type: "error", ID: req.ID, Message: "quote 订阅需要提供 tokenIn 和 tokenOut"})
+			return
+		}
+		if err := req.Quote.validate(); err != nil {
+			sc.send(StreamMessage{Type: "error", ID: req.ID, Message: "参数错误: " + err.Error()})
+			return
+		}
+		if err := sc.h.quote.resolveQuoteRequest(req.Quote); err != nil {
+			sc.send(StreamMessage{Type: "error", ID: req.ID, Message: "参数错误: " + err.Error()})
+			return
+		}
+		sub.quote = req.Quote
+	case StreamChannelPool:
+		if req.Pool == "" {
+			sc.send(StreamMessage{Type: "error", ID: req.ID, Message: "pool 订阅需要提供池子地址"})
+			return
+		}
+		sub.pool = strings.ToLower(req.Pool)
+	default:
+		sc.send(StreamMessage{Type: "error", ID: req.ID, Message: "不支持的订阅频道 " + req.Channel})
+		return
+	}
+
+	quote := sc.h.quote.Snapshot()
+	block, err := quote.IndexedBlock()
+	if err != nil {
+		log.Printf("[Stream] %v", err)
+	}
+	result := sc.compute(quote, sub)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if _, exists := sc.subs[req.ID]; !exists && len(sc.subs) >= MaxStreamSubscriptions {
+		sc.send(StreamMessage{Type: "error", ID: req.ID, Message: "订阅数量超过上限"})
+		return
+	}
+	sc.subs[req.ID] = sub
+	sc.deliver(sub, block, result, true)
+}
+
+// handleChanges 一批池子状态变化后，重新计算受影响的订阅；changed 为小写池子地址 -> 通知中的区块高度
+//
+// 池子订阅只在该池子变化时推送；指定池子的报价同理。
+// 自动路由的报价在任意池子变化后都重新计算（最佳路由可能换到其他池子），
+// 上次使用的池子发生变化或结果不同时推送。
+// 这一批订阅在同一个池子视图上计算，推送的 block 为该视图的区块高度（不低于通知中的区块）；
+// 计算不持有 sc.mu，订阅和取消订阅不会等待；计算期间被取消或替换的订阅不再推送
+func (sc *streamConn) handleChanges(changed map[string]int64) {
+	quote := sc.h.quote.Snapshot()
+	block, err := quote.IndexedBlock()
+	if err != nil {
+		log.Printf("[Stream] %v", err)
+	}
+	for _, b := range changed {
+		block = max(block, b)
+	}
+
+	type job struct {
+		sub   *streamSubscription
+		force bool
+	}
+	var jobs []job
+	sc.mu.Lock()
+	for _, sub := range sc.subs {
+		switch {
+		case sub.pool != "":
+			if _, ok := changed[sub.pool]; ok {
+				jobs = append(jobs, job{sub, true})
+			}
+		case sub.quote.PoolAddress != "":
+			if _, ok := changed[strings.ToLower(sub.quote.PoolAddress)]; ok {
+				jobs = append(jobs, job{sub, true})
+			}
+		default:
+			force := false
+			for pool := range changed {
+				if sub.pools[pool] {
+					force = true
+					break
+				}
+			}
+			jobs = append(jobs, job{sub, force})
+		}
+	}
+	sc.mu.Unlock()
+
+	for _, j := range jobs {
+		result := sc.compute(quote, j.sub)
+
+		sc.mu.Lock()
+		if sc.subs[j.sub.id] == j.sub {
+			sc.deliver(j.sub, block, result, j.force)
+		}
+		sc.mu.Unlock()
+	}
+}
+
+// compute 用 quote 计算订阅的当前结果，只读取订阅创建后不再修改的参数，不需要持有 sc.mu
+func (sc *streamConn) compute(quote *Quote, sub *streamSubscription) streamResult {
+	switch sub.channel {
+	case StreamChannelQuote:
+		resp, _, err := sc.h.computeQuote(quote, sub.quote)
+		if err != nil {
+			return streamResult{err: err}
+		}
+		return streamResult{data: resp, pools: quotePools(resp)}
+	default:
+		pool, err := quote.GetPool(sub.pool)
+		if err != nil {
+			return streamResult{err: fmt.Errorf("查询池子失败: %w", err)}
+		}
+		return streamResult{data: pool}
+	}
+}
+
+// deliver 推送订阅的计算结果，force 为 false 时只在结果与上次不同时推送（调用方持有 sc.mu）
+func (sc *streamConn) deliver(sub *streamSubscription, block int64, result streamResult, force bool) {
+	if result.err != nil {
+		if force || result.err.Error() != sub.lastErr {
+			sub.last, sub.lastErr = nil, result.err.Error()
+			sc.send(StreamMessage{Type: "error", ID: sub.id, Block: block, Message: result.err.Error()})
+		}
+		return
+	}
+
+	encoded, err := json.Marshal(result.data)
+	if err != nil {
+		log.Printf("[Stream] Encode %s failed: %v", sub.channel, err)
+		return
+	}
+	if !force && bytes.Equal(encoded, sub.last) {
+		return
+	}
+	sub.last, sub.lastErr, sub.pools = encoded, "", result.pools
+
+	sc.send(StreamMessage{Type: sub.channel, ID: sub.id, Block: block, Data: json.RawMessage(encoded)})
+}
+
+// send 向客户端写入一条消息，写入失败时关闭连接（读循环随之退出）
+func (sc *streamConn) send(msg StreamMessage) {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+
+	sc.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if err := sc.conn.WriteJSON(msg); err != nil {
+		sc.conn.Close()
+	}
+}
+
+// quotePools 返回报价使用的所有池子（小写地址）
+func quotePools(resp *QuoteResponse) map[string]bool {
+	pools := make(map[string]bool)
+	if resp.PoolAddress != "" {
+		pools[strings.ToLower(resp.PoolAddress)] = true
+	}
+	for _, hop := range resp.Route {
+		for _, split := range hop.Splits {
+			pools[strings.ToLower(split.PoolAddress)] = true
+		}
+	}
+	return pools
+}
@@ -0,0 +1,461 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+
+	"dex-bot/pkg/swapmath"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// 交易构建参数的默认值和上限
+const (
+	DefaultSlippageBps     = 50   // 默认滑点容忍度 0.5%
+	MaxSlippageBps         = 5000 // 滑点容忍度上限 50%
+	DefaultDeadlineSeconds = 1200 // 未指定 deadline 时为当前时间 + 20 分钟
+
+	// maxIndexPathPermutationPools 池子数量不超过该值时枚举所有 indexPath 顺序，超过时按价格排序
+	maxIndexPathPermutationPools = 6
+)
+
+// swapRouterABI SwapRouter 的 exactInput / exactOutput（ISwapRouter.sol）
+const swapRouterABI = `[
+	{"type":"function","name":"exactInput","stateMutability":"payable",
+	 "inputs":[{"name":"params","type":"tuple","components":[
+		{"name":"tokenIn","type":"address"},
+		{"name":"tokenOut","type":"address"},
+		{"name":"indexPath","type":"uint32[]"},
+		{"name":"recipient","type":"address"},
+		{"name":"deadline","type":"uint256"},
+		{"name":"amountIn","type":"uint256"},
+		{"name":"amountOutMinimum","type":"uint256"},
+		{"name":"sqrtPriceLimitX96","type":"uint160"}]}],
+	 "outputs":[{"name":"amountOut","type":"uint256"}]},
+	{"type":"function","name":"exactOutput","stateMutability":"payable",
+	 "inputs":[{"name":"params","type":"tuple","components":[
+		{"name":"tokenIn","type":"address"},
+		{"name":"tokenOut","type":"address"},
+		{"name":"indexPath","type":"uint32[]"},
+		{"name":"recipient","type":"address"},
+		{"name":"deadline","type":"uint256"},
+		{"name":"amountOut","type":"uint256"},
+		{"name":"amountInMaximum","type":"uint256"},
+		{"name":"sqrtPriceLimitX96","type":"uint160"}]}],
+	 "outputs":[{"name":"amountIn","type":"uint256"}]}
+]`
+
+var parsedSwapRouterABI = mustParseABI(swapRouterABI)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("解析 ABI 失败: %v", err))
+	}
+	return parsed
+}
+
+// exactInputParams 对应 ISwapRouter.ExactInputParams，字段名与 ABI 组件名一一对应
+type exactInputParams struct {
+	TokenIn           common.Address
+	TokenOut          common.Address
+	IndexPath         []uint32
+	Recipient         common.Address
+	Deadline          *big.Int
+	AmountIn          *big.Int
+	AmountOutMinimum  *big.Int
+	SqrtPriceLimitX96 *big.Int
+}
+
+// exactOutputParams 对应 ISwapRouter.ExactOutputParams
+type exactOutputParams struct {
+	TokenIn           common.Address
+	TokenOut          common.Address
+	IndexPath         []uint32
+	Recipient         common.Address
+	Deadline          *big.Int
+	AmountOut         *big.Int
+	AmountInMaximum   *big.Int
+	SqrtPriceLimitX96 *big.Int
+}
+
+// SwapTxParams 构建 SwapRouter 交易的参数
+type SwapTxParams struct {
+	TradeType         string
+	TokenIn           string
+	TokenOut          string
+	Amount            string   // EXACT_INPUT 为 amountIn，EXACT_OUTPUT 为 amountOut
+	PoolAddress       string   // 可选：只使用该池子
+	IndexPath         []uint32 // 可选：按该顺序成交，不再枚举 indexPath 顺序（与 PoolAddress 互斥）
+	SqrtPriceLimitX96 *big.Int // 可选：价格限制，为 nil 时为 MIN_SQRT_PRICE + 1 / MAX_SQRT_PRICE - 1
+	SlippageBps       int64
+	Recipient         string
+	Deadline          int64 // unix 秒
+}
+
+// SwapTx 可直接签名发送的 SwapRouter 交易
+type SwapTx struct {
+	To                string   `json:"to"`     // SwapRouter 地址
+	Data              string   `json:"data"`   // ABI 编码的 calldata
+	Value             string   `json:"value"`  // SwapRouter 不接收原生币，固定为 0
+	Method            string   `json:"method"` // exactInput 或 exactOutput
+	TradeType         string   `json:"tradeType"`
+	TokenIn           string   `json:"tokenIn"`
+	TokenOut          string   `json:"tokenOut"`
+	IndexPath         []uint32 `json:"indexPath"`                  // 按顺序成交的池子序号
+	Pools             []string `json:"pools"`                      // indexPath 对应的池子地址
+	AmountIn          string   `json:"amountIn"`                   // 按当前池子状态模拟的输入金额
+	AmountOut         string   `json:"amountOut"`                  // 按当前池子状态模拟的输出金额
+	AmountOutMinimum  string   `json:"amountOutMinimum,omitempty"` // EXACT_INPUT：amountOut 扣除滑点
+	AmountInMaximum   string   `json:"amountInMaximum,omitempty"`  // EXACT_OUTPUT：amountIn 加上滑点
+	SqrtPriceLimitX96 string   `json:"sqrtPriceLimitX96"`
+	SlippageBps       int64    `json:"slippageBps"`
+	Recipient         string   `json:"recipient"`
+	Deadline          int64    `json:"deadline"`
+}
+
+// routerSwap 按 SwapRouter 的执行方式模拟一个 indexPath 的结果
+type routerSwap struct {
+	path      []*PoolState  // 写入 indexPath 的池子：指定 indexPath 时为指定的全部池子，否则同 pools
+	pools     []*PoolState  // 实际参与成交的池子（按 indexPath 顺序）
+	steps     []*SwapResult // 与 pools 一一对应的成交结果
+	amountIn  *big.Int
+	amountOut *big.Int
+	remaining *big.Int // 所有池子都到达价格区间边界（或价格限制）后仍未成交的数量
+	err       error    // 链上会 revert 的原因：经过的池子不满足价格限制（SPL）
+}
+
+// BuildSwapTx 重新计算报价，并生成带滑点保护的 SwapRouter.exactInput / exactOutput 交易
+//
+// SwapRouter 的一笔交易只能兑换一个交易对：按 indexPath 依次把剩余数量交给每个池子，
+// 每个池子最多成交到自己价格区间的边界。因此这里不复用拆单报价的分配比例，
+// 而是对该交易对的池子枚举 indexPath 顺序，按合约的执行方式模拟，选择结果最好的顺序；
+// 指定 indexPath 时按该顺序模拟并原样写入交易，指定价格限制时同样写入交易。
+func (q *Quote) BuildSwapTx(router string, params SwapTxParams) (*SwapTx, error) {
+	if !common.IsHexAddress(router) {
+		return nil, fmt.Errorf("未配置有效的 SwapRouter 地址")
+	}
+	for name, addr := range map[string]string{"tokenIn": params.TokenIn, "tokenOut": params.TokenOut, "recipient": params.Recipient} {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("无效的 %s 地址: %s", name, addr)
+		}
+	}
+	if common.HexToAddress(params.Recipient) == (common.Address{}) {
+		// recipient 为零地址时 SwapRouter 会进入报价模式并 revert
+		return nil, fmt.Errorf("recipient 不能是零地址")
+	}
+
+	amount, ok := new(big.Int).SetString(params.Amount, 10)
+	if !ok || amount.Sign() <= 0 {
+		return nil, fmt.Errorf("无效的金额: %s", params.Amount)
+	}
+
+	zeroForOne := isZeroForOne(params.TokenIn, params.TokenOut)
+	amountSpecified := amount
+	if params.TradeType == TradeTypeExactOutput {
+		amountSpecified = new(big.Int).Neg(amount)
+	}
+
+	best, err := q.planRouterSwap(params.TokenIn, params.TokenOut, params.PoolAddress, params.IndexPath, amountSpecified, params.SqrtPriceLimitX96)
+	if err != nil {
+		return nil, err
+	}
+	if best.remaining.Sign() > 0 {
+		return nil, fmt.Errorf("流动性不足: 所有池子到达价格区间边界（或价格限制）后仍有 %s 未成交", best.remaining.String())
+	}
+
+	sqrtPriceLimitX96 := params.SqrtPriceLimitX96
+	if sqrtPriceLimitX96 == nil {
+		sqrtPriceLimitX96 = defaultSqrtPriceLimit(zeroForOne)
+	}
+
+	tx := &SwapTx{
+		To:                common.HexToAddress(router).Hex(),
+		Value:             "0",
+		TradeType:         params.TradeType,
+		TokenIn:           common.HexToAddress(params.TokenIn).Hex(),
+		TokenOut:          common.HexToAddress(params.TokenOut).Hex(),
+		AmountIn:          best.amountIn.String(),
+		AmountOut:         best.amountOut.String(),
+		SqrtPriceLimitX96: sqrtPriceLimitX96.String(),
+		SlippageBps:       params.SlippageBps,
+		Recipient:         common.HexToAddress(params.Recipient).Hex(),
+		Deadline:          params.Deadline,
+	}
+	for _, pool := range best.path {
+		tx.IndexPath = append(tx.IndexPath, uint32(*pool.PoolIndex))
+		tx.Pools = append(tx.Pools, pool.Address)
+	}
+
+	var calldata []byte
+	deadline := big.NewInt(params.Deadline)
+	if params.TradeType == TradeTypeExactOutput {
+		maxIn := applySlippage(best.amountIn, params.SlippageBps, true)
+		tx.Method = "exactOutput"
+		tx.AmountInMaximum = maxIn.String()
+		calldata, err = parsedSwapRouterABI.Pack(tx.Method, exactOutputParams{
+			TokenIn:           common.HexToAddress(params.TokenIn),
+			TokenOut:          common.HexToAddress(params.TokenOut),
+			IndexPath:         tx.IndexPath,
+			Recipient:         common.HexToAddress(params.Recipient),
+			Deadline:          deadline,
+			AmountOut:         amount,
+			AmountInMaximum:   maxIn,
+			SqrtPriceLimitX96: sqrtPriceLimitX96,
+		})
+	} else {
+		minOut := applySlippage(best.amountOut, params.SlippageBps, false)
+		tx.Method = "exactInput"
+		tx.AmountOutMinimum = minOut.String()
+		calldata, err = parsedSwapRouterABI.Pack(tx.Method, exactInputParams{
+			TokenIn:           common.HexToAddress(params.TokenIn),
+			TokenOut:          common.HexToAddress(params.TokenOut),
+			IndexPath:         tx.IndexPath,
+			Recipient:         common.HexToAddress(params.Recipient),
+			Deadline:          deadline,
+			AmountIn:          amount,
+			AmountOutMinimum:  minOut,
+			SqrtPriceLimitX96: sqrtPriceLimitX96,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码 %s calldata 失败: %w", tx.Method, err)
+	}
+	tx.Data = hexutil.Encode(calldata)
+
+	return tx, nil
+}
+
+// pairPoolsForRouter 返回交易对中可以写入 indexPath 的池子（已同步 pool_index），指定池子时只返回该池子
+func (q *Quote) pairPoolsForRouter(tokenIn, tokenOut, poolAddress string) ([]*PoolState, error) {
+	if strings.EqualFold(tokenIn, tokenOut) {
+		return nil, fmt.Errorf("输入代币和输出代币不能相同")
+	}
+
+	all, err := q.loadRoutablePools()
+	if err != nil {
+		return nil, fmt.Errorf("加载池子失败: %w", err)
+	}
+
+	key := pairKey(tokenIn, tokenOut)
+	var pools []*PoolState
+	for _, pool := range all {
+		if pairKey(pool.Token0, pool.Token1) != key {
+			continue
+		}
+		if poolAddress != "" && !strings.EqualFold(pool.Address, poolAddress) {
+			continue
+		}
+		if pool.PoolIndex == nil {
+			log.Printf("[SwapTx] Skip pool %s: pool_index not synced", pool.Address)
+			continue
+		}
+		pools = append(pools, pool)
+	}
+
+	if len(pools) == 0 {
+		if poolAddress != "" {
+			return nil, fmt.Errorf("池子 %s 不属于交易对 %s/%s，或没有流动性", poolAddress, tokenIn, tokenOut)
+		}
+		return nil, fmt.Errorf("交易对 %s/%s 没有可用的池子", tokenIn, tokenOut)
+	}
+	return pools, nil
+}
+
+// isZeroForOne 判断 tokenIn -> tokenOut 是否为 token0 -> token1 方向：与合约相同按地址的 20 字节数值比较，
+// 地址可以不带 0x 前缀、大小写任意
+func isZeroForOne(tokenIn, tokenOut string) bool {
+	return bytes.Compare(common.HexToAddress(tokenIn).Bytes(), common.HexToAddress(tokenOut).Bytes()) < 0
+}
+
+// defaultSqrtPriceLimit 未指定价格限制时写入交易的 sqrtPriceLimitX96：不额外限制价格，只受池子价格区间限制
+func defaultSqrtPriceLimit(zeroForOne bool) *big.Int {
+	if zeroForOne {
+		return new(big.Int).Add(swapmath.MinSqrtPrice, big.NewInt(1))
+	}
+	return new(big.Int).Sub(swapmath.MaxSqrtPrice, big.NewInt(1))
+}
+
+// planRouterSwap 确定 SwapRouter 交易的 indexPath 并按合约的执行方式模拟：
+// 指定 indexPath 时严格按该顺序，否则对交易对（指定 poolAddress 时只用该池子）的池子选择结果最好的顺序
+func (q *Quote) planRouterSwap(tokenIn, tokenOut, poolAddress string, indexPath []uint32, amountSpecified, sqrtPriceLimitX96 *big.Int) (*routerSwap, error) {
+	zeroForOne := isZeroForOne(tokenIn, tokenOut)
+
+	var result *routerSwap
+	if len(indexPath) > 0 {
+		order, err := q.indexPathPools(tokenIn, tokenOut, indexPath)
+		if err != nil {
+			return nil, err
+		}
+		result = q.simulateRouterSwap(order, zeroForOne, amountSpecified, sqrtPriceLimitX96)
+		result.path = order
+	} else {
+		pools, err := q.pairPoolsForRouter(tokenIn, tokenOut, poolAddress)
+		if err != nil {
+			return nil, err
+		}
+		result = q.bestRouterSwap(pools, zeroForOne, amountSpecified, sqrtPriceLimitX96)
+		if result != nil {
+			result.path = result.pools
+		}
+	}
+
+	if result != nil && result.err != nil {
+		return nil, result.err
+	}
+	if result == nil || result.amountOut.Sign() == 0 {
+		return nil, fmt.Errorf("交易对 %s/%s 的池子无法成交", tokenIn, tokenOut)
+	}
+	return result, nil
+}
+
+// indexPathPools 按 indexPath 的顺序返回交易对中对应序号的池子
+func (q *Quote) indexPathPools(tokenIn, tokenOut string, indexPath []uint32) ([]*PoolState, error) {
+	pools, err := q.pairPoolsForRouter(tokenIn, tokenOut, "")
+	if err != nil {
+		return nil, err
+	}
+	byIndex := make(map[uint32]*PoolState, len(pools))
+	for _, pool := range pools {
+		byIndex[uint32(*pool.PoolIndex)] = pool
+	}
+
+	order := make([]*PoolState, 0, len(indexPath))
+	seen := make(map[uint32]bool, len(indexPath))
+	for _, index := range indexPath {
+		pool, ok := byIndex[index]
+		if !ok {
+			return nil, fmt.Errorf("%w: 交易对 %s/%s 没有序号为 %d 的可用池子", errInvalidIndexPath, tokenIn, tokenOut, index)
+		}
+		// 链上同一个池子会按上一次成交后的状态再次成交，这里的模拟只使用当前状态，因此不允许重复
+		if seen[index] {
+			return nil, fmt.Errorf("%w: 池子序号 %d 重复", errInvalidIndexPath, index)
+		}
+		seen[index] = true
+		order = append(order, pool)
+	}
+	return order, nil
+}
+
+// bestRouterSwap 选择模拟结果最好的 indexPath 顺序
+// 精确输入：未成交数量最少、输出最多；精确输出：未成交数量最少、输入最少。结果相同时使用的池子更少的更优，会 revert 的顺序最差
+func (q *Quote) bestRouterSwap(pools []*PoolState, zeroForOne bool, amountSpecified, sqrtPriceLimitX96 *big.Int) *routerSwap {
+	exactInput := amountSpecified.Sign() > 0
+	better := func(a, b *routerSwap) bool {
+		if (a.err == nil) != (b.err == nil) {
+			return a.err == nil
+		}
+		if c := a.remaining.Cmp(b.remaining); c != 0 {
+			return c < 0
+		}
+		if exactInput {
+			if c := a.amountOut.Cmp(b.amountOut); c != 0 {
+				return c > 0
+			}
+		} else if c := a.amountIn.Cmp(b.amountIn); c != 0 {
+			return c < 0
+		}
+		return len(a.pools) < len(b.pools)
+	}
+
+	var best *routerSwap
+	consider := func(order []*PoolState) {
+		result := q.simulateRouterSwap(order, zeroForOne, amountSpecified, sqrtPriceLimitX96)
+		if best == nil || better(result, best) {
+			best = result
+		}
+	}
+
+	if len(pools) <= maxIndexPathPermutationPools {
+		permutePools(pools, 0, consider)
+	} else {
+		// 池子太多时按当前价格从优到劣排序：zeroForOne 时价格越高越好，反之越低越好
+		ordered := append([]*PoolState(nil), pools...)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			c := ordered[i].SqrtPriceX96.Cmp(ordered[j].SqrtPriceX96)
+			if zeroForOne {
+				return c > 0
+			}
+			return c < 0
+		})
+		consider(ordered)
+	}
+	return best
+}
+
+// simulateRouterSwap 按 SwapRouter.exactInput / exactOutput 的循环模拟 indexPath：
+// 每个池子处理剩余的全部数量，剩余为 0 时停止。池子价格已在区间边界时链上成交为 0，这里同样跳过；
+// 经过的池子不满足价格限制时整笔交易 revert，记录在 err 中
+func (q *Quote) simulateRouterSwap(order []*PoolState, zeroForOne bool, amountSpecified, sqrtPriceLimitX96 *big.Int) *routerSwap {
+	exactInput := amountSpecified.Sign() > 0
+	result := &routerSwap{
+		amountIn:  big.NewInt(0),
+		amountOut: big.NewInt(0),
+		remaining: new(big.Int).Abs(amountSpecified),
+	}
+
+	for _, pool := range order {
+		specified := new(big.Int).Set(result.remaining)
+		if !exactInput {
+			specified.Neg(specified)
+		}
+
+		step, err := q.swapInPool(pool, specified, zeroForOne, sqrtPriceLimitX96)
+		if errors.Is(err, errSqrtPriceLimit) {
+			result.err = err
+			return result
+		}
+		if err != nil {
+			log.Printf("[SwapTx] Pool %s fills nothing: %v", pool.Address, err)
+			continue
+		}
+
+		result.pools = append(result.pools, pool)
+		result.steps = append(result.steps, step)
+		result.amountIn.Add(result.amountIn, step.AmountIn)
+		result.amountOut.Add(result.amountOut, step.AmountOut)
+		if exactInput {
+			result.remaining.Sub(result.remaining, step.AmountIn)
+		} else {
+			result.remaining.Sub(result.remaining, step.AmountOut)
+		}
+		if result.remaining.Sign() <= 0 {
+			result.remaining.SetInt64(0)
+			break
+		}
+	}
+	return result
+}
+
+// permutePools 依次把 pools 的每一种排列（副本）交给 visit
+func permutePools(pools []*PoolState, k int, visit func([]*PoolState)) {
+	if k == len(pools) {
+		visit(append([]*PoolState(nil), pools...))
+		return
+	}
+	for i := k; i < len(pools); i++ {
+		pools[k], pools[i] = pools[i], pools[k]
+		permutePools(pools, k+1, visit)
+		pools[k], pools[i] = pools[i], pools[k]
+	}
+}
+
+// applySlippage 按滑点容忍度调整金额：roundUp 为 false 时返回 amount * (10000 - bps) / 10000（向下取整），
+// 为 true 时返回 amount * (10000 + bps) / 10000（向上取整）
+func applySlippage(amount *big.Int, bps int64, roundUp bool) *big.Int {
+	denominator := big.NewInt(10000)
+	if !roundUp {
+		result := new(big.Int).Mul(amount, big.NewInt(10000-bps))
+		return result.Div(result, denominator)
+	}
+	result := new(big.Int).Mul(amount, big.NewInt(10000+bps))
+	result.Add(result, big.NewInt(9999))
+	return result.Div(result, denominator)
+}
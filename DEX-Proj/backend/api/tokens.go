@@ -0,0 +1,146 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 报价请求中金额的格式
+const (
+	AmountFormatRaw     = "raw"     // 代币最小单位的整数（默认）
+	AmountFormatDecimal = "decimal" // 按 tokens.decimals 换算的小数，如 "1.5"
+)
+
+var decimalAmountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// ResolveToken 把代币地址或 symbol 解析为 tokens 表中的代币
+// 地址不在 tokens 表中时仍返回该地址（精度未知）；symbol 大小写不敏感，没有匹配或匹配到多个代币时返回错误
+func (q *Quote) ResolveToken(ref string) (*TokenInfo, error) {
+	ref = strings.TrimSpace(ref)
+	if common.IsHexAddress(ref) {
+		token, err := scanTokenInfo(q.db.QueryRow(`
+			SELECT address, symbol, name, decimals FROM tokens WHERE LOWER(address) = LOWER($1)
+		`, ref))
+		if errors.Is(err, sql.ErrNoRows) {
+			return &TokenInfo{Address: ref}, nil
+		}
+		return token, err
+	}
+
+	rows, err := q.db.Query(`
+		SELECT address, symbol, name, decimals FROM tokens WHERE LOWER(symbol) = LOWER($1) ORDER BY address
+	`, ref)
+	if err != nil {
+		return nil, fmt.Errorf("查询代币 %s 失败: %w", ref, err)
+	}
+	defer rows.Close()
+
+	var tokens []*TokenInfo
+	for rows.Next() {
+		token, err := scanTokenInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch len(tokens) {
+	case 0:
+		return nil, fmt.Errorf("未找到 symbol 为 %s 的代币", ref)
+	case 1:
+		return tokens[0], nil
+	default:
+		addresses := make([]string, len(tokens))
+		for i, token := range tokens {
+			addresses[i] = token.Address
+		}
+		return nil, fmt.Errorf("symbol %s 对应多个代币（%s），请使用代币地址", ref, strings.Join(addresses, ", "))
+	}
+}
+
+// scanTokenInfo 读取 address, symbol, name, decimals
+func scanTokenInfo(row rowScanner) (*TokenInfo, error) {
+	var address string
+	var symbol, name sql.NullString
+	var decimals sql.NullInt64
+	if err := row.Scan(&address, &symbol, &name, &decimals); err != nil {
+		return nil, err
+	}
+	token := newTokenInfo(address, symbol, name, decimals)
+	return &token, nil
+}
+
+// parseUnits 把小数金额按精度换算为最小单位的整数（如 "1.5"、6 位精度 = 1500000），小数位数不能超过精度
+func parseUnits(amount string, decimals int64) (*big.Int, error) {
+	if !decimalAmountPattern.MatchString(amount) {
+		return nil, fmt.Errorf("无效的小数金额: %s", amount)
+	}
+	intPart, fracPart, _ := strings.Cut(amount, ".")
+	if int64(len(fracPart)) > decimals {
+		return nil, fmt.Errorf("金额 %s 的小数位数超过代币精度 %d", amount, decimals)
+	}
+	value, _ := new(big.Int).SetString(intPart+fracPart+strings.Repeat("0", int(decimals)-len(fracPart)), 10)
+	return value, nil
+}
+
+// resolveQuoteRequest 把请求中的代币 symbol 解析为地址，把小数金额换算为最小单位，
+// 并记录两个代币的信息用于在响应中返回格式化金额
+func (q *Quote) resolveQuoteRequest(req *QuoteRequest) error {
+	switch req.AmountFormat {
+	case "", AmountFormatRaw, AmountFormatDecimal:
+	default:
+		return fmt.Errorf("不支持的 amountFormat %s", req.AmountFormat)
+	}
+
+	tokenIn, err := q.ResolveToken(req.TokenIn)
+	if err != nil {
+		return fmt.Errorf("tokenIn: %w", err)
+	}
+	tokenOut, err := q.ResolveToken(req.TokenOut)
+	if err != nil {
+		return fmt.Errorf("tokenOut: %w", err)
+	}
+
+	if req.AmountFormat == AmountFormatDecimal {
+		for _, amount := range []struct {
+			value *string
+			token *TokenInfo
+		}{{&req.AmountIn, tokenIn}, {&req.AmountOut, tokenOut}} {
+			if *amount.value == "" {
+				continue
+			}
+			if amount.token.Decimals == nil {
+				return fmt.Errorf("代币 %s 的精度未知，请使用最小单位的金额", amount.token.Address)
+			}
+			raw, err := parseUnits(*amount.value, *amount.token.Decimals)
+			if err != nil {
+				return err
+			}
+			*amount.value = raw.String()
+		}
+		req.AmountFormat = AmountFormatRaw
+	}
+
+	req.TokenIn, req.TokenOut = tokenIn.Address, tokenOut.Address
+	req.tokenIn, req.tokenOut = tokenIn, tokenOut
+	return nil
+}
+
+// setTokens 在报价响应中返回两个代币的信息和按精度格式化的金额（精度未知时不返回格式化金额）
+func (resp *QuoteResponse) setTokens(tokenIn, tokenOut *TokenInfo) {
+	if tokenIn == nil || tokenOut == nil {
+		return
+	}
+	resp.TokenIn, resp.TokenOut = tokenIn, tokenOut
+	resp.AmountInFormatted = formatAmount(resp.AmountIn, tokenIn.Decimals)
+	resp.AmountOutFormatted = formatAmount(resp.AmountOut, tokenOut.Decimals)
+}
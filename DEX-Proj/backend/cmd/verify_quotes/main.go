@@ -0,0 +1,162 @@
+// verify_quotes 随机抽取池子和输入金额，对比链下报价与 SwapRouter.quoteExactInput 的结果
+//
+// 运行：go run ./cmd/verify_quotes -config ../sync/config.yaml -n 100
+//
+// 每个样本按 POST /api/v1/quote 的 verify 计算报价（Handler.RequestQuote），在报价所用状态的区块上
+// eth_call quoteExactInput 并与报价的 amountOut 对比。差值超过 -tolerance 的样本会被打印，存在不一致或调用失败时以非 0 状态码退出；
+// 多跳或拆单的报价无法用一个 indexPath 复现，计为跳过。
+// 本地开发链：npx hardhat node 部署合约并运行 sync 服务后，使用 -rpc http://127.0.0.1:8545 -swap-router <地址>；
+// main_test.go 的 TestSweepOnHardhat 自动完成启动 hardhat node、部署、同步和校验（VERIFY_QUOTES_E2E=1 时运行）。
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"math/rand"
+	"os"
+
+	"dex-bot/api"
+	"dex-bot/pkg/config"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func main() {
+	configPath := flag.String("config", "../sync/config.yaml", "配置文件路径")
+	dbPath := flag.String("db", "", "SQLite 数据库文件路径（如果使用 SQLite）")
+	rpcURL := flag.String("rpc", "", "以太坊 RPC 地址（默认读取配置文件的 RPC.Urls 的第一个节点或 RPC.Url）")
+	swapRouter := flag.String("swap-router", "", "SwapRouter 合约地址（默认读取配置文件的 Contracts.SwapRouter）")
+	count := flag.Int("n", 50, "样本数量")
+	seed := flag.Int64("seed", 20240101, "随机数种子")
+	tolerance := flag.Int64("tolerance", 0, "允许的 amountOut 差值（最小单位，绝对值）")
+	flag.Parse()
+
+	db, err := openDB(*configPath, *dbPath, rpcURL, swapRouter)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if *rpcURL == "" {
+		log.Fatalf("RPC url is required (-rpc, RPC.Urls or RPC.Url in %s)", *configPath)
+	}
+	client, err := ethclient.Dial(*rpcURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to RPC: %v", err)
+	}
+	quoter, err := api.NewOnchainQuoter(client, *swapRouter)
+	if err != nil {
+		log.Fatalf("Failed to create quoter: %v", err)
+	}
+
+	quote := api.NewQuote(db)
+	handler := api.NewHandler(quote, *swapRouter)
+	handler.SetOnchainQuoter(quoter)
+	samples, err := quote.SampleQuotes(rand.New(rand.NewSource(*seed)), *count)
+	if err != nil {
+		log.Fatalf("Failed to sample quotes: %v", err)
+	}
+
+	result := sweep(context.Background(), handler, samples, big.NewInt(*tolerance))
+	if !result.ok() {
+		fmt.Printf("❌ %d/%d samples mismatched, %d failed, %d skipped (multi-hop or split)\n", result.mismatches, result.verified, result.failures, result.skipped)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ All %d samples matched, %d skipped (multi-hop or split)\n", result.verified, result.skipped)
+}
+
+// sweepResult 一次校验的统计
+type sweepResult struct {
+	verified   int // 完成校验的样本（不含跳过的）
+	mismatches int // 差值超过容差的样本
+	failures   int // 报价或 eth_call 失败的样本
+	skipped    int // 多跳或拆单、无法用一个 indexPath 复现的样本
+}
+
+// ok 没有不一致和失败，且至少校验了一个样本
+func (r sweepResult) ok() bool {
+	return r.mismatches == 0 && r.failures == 0 && r.verified > 0
+}
+
+// sweep 逐个按 verify 计算报价并与 quoteExactInput 对比，打印不一致和失败的样本
+func sweep(ctx context.Context, handler *api.Handler, samples []api.QuoteSample, tolerance *big.Int) sweepResult {
+	var result sweepResult
+	for i, sample := range samples {
+		resp, _, err := handler.RequestQuote(ctx, &api.QuoteRequest{
+			TokenIn:     sample.TokenIn,
+			TokenOut:    sample.TokenOut,
+			AmountIn:    sample.AmountIn.String(),
+			TradeType:   api.TradeTypeExactInput,
+			PoolAddress: sample.PoolAddress,
+			Verify:      true,
+		})
+		if errors.Is(err, api.ErrUnverifiableQuote) {
+			result.skipped++
+			continue
+		}
+		result.verified++
+		if err == nil && resp.Verification.Error != "" {
+			err = fmt.Errorf("%s", resp.Verification.Error)
+		}
+		if err != nil {
+			result.failures++
+			fmt.Printf("⚠️  #%d %s -> %s amountIn=%s pool=%q: %v\n", i, sample.TokenIn, sample.TokenOut, sample.AmountIn, sample.PoolAddress, err)
+			continue
+		}
+		v := resp.Verification
+
+		difference, _ := new(big.Int).SetString(v.Difference, 10)
+		if difference.CmpAbs(tolerance) > 0 {
+			result.mismatches++
+			fmt.Printf("❌ #%d %s -> %s amountIn=%s block=%d indexPath=%v offchain=%s onchain=%s diff=%s (%.4f bps)\n",
+				i, sample.TokenIn, sample.TokenOut, sample.AmountIn, v.Block, v.IndexPath,
+				v.OffchainAmountOut, v.OnchainAmountOut, v.Difference, v.DifferenceBps)
+		}
+	}
+	return result
+}
+
+// openDB 与 API 服务相同：指定 -db 时使用 SQLite，否则按配置文件连接 PostgreSQL；
+// 同时用配置文件补全未指定的 RPC 地址（RPC.Urls 的第一个节点或 RPC.Url）和 SwapRouter 地址
+func openDB(configPath, dbPath string, rpcURL, swapRouter *string) (*sql.DB, error) {
+	cfg, cfgErr := config.LoadConfig(configPath)
+	if cfgErr == nil {
+		if *rpcURL == "" {
+			*rpcURL = cfg.RPCURL()
+		}
+		if *swapRouter == "" {
+			*swapRouter = cfg.Contracts.SwapRouter
+		}
+	}
+
+	var db *sql.DB
+	var err error
+	if dbPath != "" {
+		db, err = sql.Open("sqlite3", dbPath)
+	} else {
+		if cfgErr != nil {
+			return nil, fmt.Errorf("read config %s: %w", configPath, cfgErr)
+		}
+		sslMode := "require"
+		if cfg.Database.Host == "localhost" || cfg.Database.Host == "127.0.0.1" {
+			sslMode = "disable"
+		}
+		db, err = sql.Open("postgres", fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.Name, sslMode))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"dex-bot/api"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// TestSweepOnHardhat 端到端校验报价：启动 hardhat node，用 scripts/verify_quotes_setup.ts 部署合约并创建池子、添加流动性、兑换，
+// 在临时数据库上运行 sync 服务同步到最新区块，然后与 verify_quotes 命令相同地逐个样本对比报价和 quoteExactInput，有任何不一致时失败。
+// 需要 Node.js（swap-contract 已 npm install）和本地 PostgreSQL，设置 VERIFY_QUOTES_E2E=1 时运行：
+//
+//	VERIFY_QUOTES_E2E=1 PGPASSWORD=... go test ./cmd/verify_quotes -run TestSweepOnHardhat -v
+//
+// 数据库连接读取 PGHOST / PGPORT / PGUSER / PGPASSWORD / PGDATABASE，默认 127.0.0.1:5432、postgres；
+// 测试在该实例上创建并在结束后删除一个临时数据库
+func TestSweepOnHardhat(t *testing.T) {
+	if os.Getenv("VERIFY_QUOTES_E2E") == "" {
+		t.Skip("设置 VERIFY_QUOTES_E2E=1 运行 hardhat 端到端测试")
+	}
+	const rpcURL = "http://127.0.0.1:8545"
+	contractDir, err := filepath.Abs(filepath.Join("..", "..", "..", "swap-contract"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncDir, err := filepath.Abs(filepath.Join("..", "..", "..", "sync"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workDir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	// 1. hardhat node（scripts 的 localhost 网络固定为 127.0.0.1:8545，端口被占用时无法保证连到的是本测试的节点）
+	if conn, err := net.DialTimeout("tcp", "127.0.0.1:8545", time.Second); err == nil {
+		conn.Close()
+		t.Fatal("127.0.0.1:8545 已被占用，请先停止正在运行的节点")
+	}
+	startProcess(t, contractDir, filepath.Join(workDir, "hardhat.log"), "npx", "hardhat", "node")
+	client := waitForNode(t, ctx, rpcURL)
+	defer client.Close()
+
+	// 2. 部署合约、创建池子并产生事件
+	deploymentFile := filepath.Join(workDir, "deployment.json")
+	setup := exec.CommandContext(ctx, "npx", "hardhat", "run", "scripts/verify_quotes_setup.ts", "--network", "localhost")
+	setup.Dir = contractDir
+	setup.Env = append(os.Environ(), "VERIFY_QUOTES_DEPLOYMENT="+deploymentFile)
+	if out, err := setup.CombinedOutput(); err != nil {
+		t.Fatalf("部署失败: %v\n%s", err, out)
+	}
+	var deployment struct {
+		PoolManager     string `json:"poolManager"`
+		PositionManager string `json:"positionManager"`
+		SwapRouter      string `json:"swapRouter"`
+	}
+	data, err := os.ReadFile(deploymentFile)
+	if err != nil {
+		t.Fatalf("读取部署结果失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &deployment); err != nil {
+		t.Fatalf("解析部署结果失败: %v", err)
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatalf("获取最新区块号失败: %v", err)
+	}
+
+	// 3. 临时数据库
+	pg := postgresFromEnv()
+	admin, err := sql.Open("postgres", pg.dsn(pg.name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	dbName := fmt.Sprintf("verify_quotes_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+dbName); err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP DATABASE IF EXISTS " + dbName + " WITH (FORCE)"); err != nil {
+			t.Logf("删除数据库 %s 失败: %v", dbName, err)
+		}
+	})
+	db, err := sql.Open("postgres", pg.dsn(dbName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// 4. sync 服务从 config.yaml 和 .sql/schema.sql 所在的工作目录启动
+	build := exec.CommandContext(ctx, "go", "build", "-o", filepath.Join(workDir, "sync"), ".")
+	build.Dir = syncDir
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("编译 sync 失败: %v\n%s", err, out)
+	}
+	schema, err := os.ReadFile(filepath.Join(syncDir, ".sql", "schema.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(workDir, ".sql"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, ".sql", "schema.sql"), schema, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf(`Database:
+  Host: %s
+  Port: %d
+  User: %s
+  Password: %q
+  Name: %s
+RPC:
+  Url: %s
+  WsUrl: ws://127.0.0.1:8545
+  StartBlock: 0
+  Confirmations: 0
+  MaxRetries: 3
+Contracts:
+  PoolManager: %s
+  PositionManager: %s
+  SwapRouter: %s
+`, pg.host, pg.port, pg.user, pg.password, dbName, rpcURL, deployment.PoolManager, deployment.PositionManager, deployment.SwapRouter)
+	if err := os.WriteFile(filepath.Join(workDir, "config.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	syncLog := filepath.Join(workDir, "sync.log")
+	startProcess(t, workDir, syncLog, filepath.Join(workDir, "sync"))
+	waitForSync(t, ctx, db, head, syncLog)
+
+	// 5. 与 verify_quotes 命令相同的校验
+	quoter, err := api.NewOnchainQuoter(client, deployment.SwapRouter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quote := api.NewQuote(db)
+	handler := api.NewHandler(quote, deployment.SwapRouter)
+	handler.SetOnchainQuoter(quoter)
+	samples, err := quote.SampleQuotes(rand.New(rand.NewSource(20240101)), 100)
+	if err != nil {
+		t.Fatalf("生成报价样本失败: %v", err)
+	}
+	result := sweep(ctx, handler, samples, big.NewInt(0))
+	t.Logf("verified=%d mismatches=%d failures=%d skipped=%d", result.verified, result.mismatches, result.failures, result.skipped)
+	if !result.ok() {
+		t.Fatalf("报价与 quoteExactInput 不一致: %+v", result)
+	}
+}
+
+// startProcess 在后台启动进程，输出写入 logFile，测试结束时结束整个进程组（npx 会再启动子进程）
+func startProcess(t *testing.T, dir, logFile, name string, args ...string) {
+	t.Helper()
+	out, err := os.Create(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		out.Close()
+		t.Fatalf("启动 %s 失败: %v", name, err)
+	}
+	t.Cleanup(func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+		out.Close()
+	})
+}
+
+// waitForNode 等待 hardhat node 开始响应 JSON-RPC
+func waitForNode(t *testing.T, ctx context.Context, url string) *ethclient.Client {
+	t.Helper()
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		if client, err := ethclient.DialContext(ctx, url); err == nil {
+			if _, err := client.BlockNumber(ctx); err == nil {
+				return client
+			}
+			client.Close()
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	t.Fatalf("hardhat node 在 1 分钟内没有启动")
+	return nil
+}
+
+// waitForSync 等待 sync 服务处理到 head 区块
+func waitForSync(t *testing.T, ctx context.Context, db *sql.DB, head uint64, syncLog string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Minute)
+	var last int64
+	for time.Now().Before(deadline) {
+		// 表由 sync 服务启动时创建，创建之前查询失败
+		if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(last_block), 0) FROM indexed_status").Scan(&last); err == nil && uint64(last) >= head {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+	output, _ := os.ReadFile(syncLog)
+	t.Fatalf("sync 服务 2 分钟内没有同步到区块 %d（当前 %d）:\n%s", head, last, output)
+}
+
+// postgresConfig 测试使用的 PostgreSQL 连接参数
+type postgresConfig struct {
+	host, user, password, name string
+	port                       int
+}
+
+func postgresFromEnv() postgresConfig {
+	getenv := func(key, def string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return def
+	}
+	port, err := strconv.Atoi(getenv("PGPORT", "5432"))
+	if err != nil {
+		port = 5432
+	}
+	return postgresConfig{
+		host:     getenv("PGHOST", "127.0.0.1"),
+		port:     port,
+		user:     getenv("PGUSER", "postgres"),
+		password: os.Getenv("PGPASSWORD"),
+		name:     getenv("PGDATABASE", "postgres"),
+	}
+}
+
+// dsn 与 sync 服务相同：本地数据库不使用 SSL
+func (c postgresConfig) dsn(dbName string) string {
+	sslMode := "require"
+	if c.host == "localhost" || c.host == "127.0.0.1" {
+		sslMode = "disable"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password='%s' dbname=%s sslmode=%s", c.host, c.port, c.user, c.password, dbName, sslMode)
+}
@@ -23,29 +23,189 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/quote": {
-            "post": {
-                "description": "根据输入代币、输出代币和输入金额计算输出金额，支持跨多个tick区间的精确计算",
-                "consumes": [
+        "/api/v1/pairs/{tokenA}/{tokenB}/depth": {
+            "get": {
+                "description": "汇总交易对所有有流动性的池子：价格区间的流动性为各池子之和，滑点曲线按最优拆分（与报价接口相同）计算。tokenA 为滑点曲线的输入代币，价格区间的价格以 token1/token0 表示",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "Pool"
+                ],
+                "summary": "查询交易对的流动性分布和滑点曲线",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "输入代币地址或 symbol",
+                        "name": "tokenA",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "输出代币地址或 symbol",
+                        "name": "tokenB",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "逗号分隔的输入金额，如 1000000,5000000",
+                        "name": "sizes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sizes 的格式：raw（最小单位，默认）或 decimal（按代币精度的小数）",
+                        "name": "amountFormat",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.DepthResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pools": {
+            "get": {
+                "description": "按流动性降序返回池子列表，储备金额和价格按 tokens.decimals 调整",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Quote"
+                    "Pool"
                 ],
-                "summary": "获取交易报价（Uniswap V3模型）",
+                "summary": "分页查询池子列表",
                 "parameters": [
                     {
-                        "description": "报价请求",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
+                        "type": "string",
+                        "description": "交易对中包含该代币（token0 或 token1）",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "手续费等级",
+                        "name": "fee",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "页码，从1开始",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "每页数量，最大100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.QuoteRequest"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "allOf": [
+                                                {
+                                                    "$ref": "#/definitions/api.PageResult"
+                                                },
+                                                {
+                                                    "type": "object",
+                                                    "properties": {
+                                                        "items": {
+                                                            "type": "array",
+                                                            "items": {
+                                                                "$ref": "#/definitions/api.PoolDetail"
+                                                            }
+                                                        }
+                                                    }
+                                                }
+                                            ]
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
                         }
                     }
+                }
+            }
+        },
+        "/api/v1/pools/{address}": {
+            "get": {
+                "description": "返回池子状态、代币信息，以及按 tokens.decimals 调整后的储备金额和价格",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pool"
+                ],
+                "summary": "查询池子详情",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "池子地址",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
                     "200": {
@@ -59,114 +219,1856 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/api.QuoteResponse"
+                                            "$ref": "#/definitions/api.PoolDetail"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pools/{address}/candles": {
+            "get": {
+                "description": "返回由 Swap 聚合的 OHLCV K 线，按时间升序。价格按 tokens.decimals 调整，默认为 1 token0 可兑换的 token1 数量，invert=true 时以 token1 为 base；成交量为两个代币各自的绝对值之和",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pool"
+                ],
+                "summary": "查询池子的 K 线",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "池子地址",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "K 线周期：1m、5m、1h、1d",
+                        "name": "interval",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "开始时间（unix 秒，含）",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "结束时间（unix 秒，含）",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 200,
+                        "description": "返回时间范围内最近的 K 线数量，最大1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "是否以 token1 为 base 计价",
+                        "name": "invert",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.CandleSeries"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pools/{address}/depth": {
+            "get": {
+                "description": "遍历池子已初始化的 tick，返回各价格区间的活跃流动性和锁定的代币数量；并按一组输入金额计算报价，返回输出金额和价格影响。未指定 sizes 时按池子最多可成交输入（capacity）的 1%、2%、5%、10%、25%、50%、75%、100% 计算",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pool"
+                ],
+                "summary": "查询池子的流动性分布和滑点曲线",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "池子地址",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "滑点曲线的输入代币地址或 symbol，默认为 token0",
+                        "name": "tokenIn",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "逗号分隔的输入金额，如 1000000,5000000",
+                        "name": "sizes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sizes 的格式：raw（最小单位，默认）或 decimal（按代币精度的小数）",
+                        "name": "amountFormat",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.DepthResult"
                                         }
                                     }
                                 }
                             ]
                         }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/api.Response"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pools/{address}/liquidity-events": {
+            "get": {
+                "description": "按区块倒序返回 MINT/BURN 记录，金额按 tokens.decimals 调整",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pool"
+                ],
+                "summary": "分页查询池子的流动性事件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "池子地址",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "事件类型：MINT 或 BURN",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "流动性 owner 地址",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "起始区块（含）",
+                        "name": "fromBlock",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "结束区块（含）",
+                        "name": "toBlock",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "页码，从1开始",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "每页数量，最大100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "allOf": [
+                                                {
+                                                    "$ref": "#/definitions/api.PageResult"
+                                                },
+                                                {
+                                                    "type": "object",
+                                                    "properties": {
+                                                        "items": {
+                                                            "type": "array",
+                                                            "items": {
+                                                                "$ref": "#/definitions/api.LiquidityEventInfo"
+                                                            }
+                                                        }
+                                                    }
+                                                }
+                                            ]
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pools/{address}/swaps": {
+            "get": {
+                "description": "按区块倒序返回 Swap 记录，金额按 tokens.decimals 调整（池子视角：正数流入、负数流出）",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pool"
+                ],
+                "summary": "分页查询池子的交易记录",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "池子地址",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "sender 或 recipient 地址",
+                        "name": "account",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "起始区块（含）",
+                        "name": "fromBlock",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "结束区块（含）",
+                        "name": "toBlock",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "页码，从1开始",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "每页数量，最大100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "allOf": [
+                                                {
+                                                    "$ref": "#/definitions/api.PageResult"
+                                                },
+                                                {
+                                                    "type": "object",
+                                                    "properties": {
+                                                        "items": {
+                                                            "type": "array",
+                                                            "items": {
+                                                                "$ref": "#/definitions/api.SwapInfo"
+                                                            }
+                                                        }
+                                                    }
+                                                }
+                                            ]
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/positions": {
+            "get": {
+                "description": "按创建区块倒序返回 position，tokensOwed 按 tokens.decimals 调整",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Position"
+                ],
+                "summary": "分页查询 position 列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "position 所有者地址",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "池子地址",
+                        "name": "pool",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "只返回流动性大于0的 position",
+                        "name": "active",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "页码，从1开始",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "每页数量，最大100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "allOf": [
+                                                {
+                                                    "$ref": "#/definitions/api.PageResult"
+                                                },
+                                                {
+                                                    "type": "object",
+                                                    "properties": {
+                                                        "items": {
+                                                            "type": "array",
+                                                            "items": {
+                                                                "$ref": "#/definitions/api.PositionInfo"
+                                                            }
+                                                        }
+                                                    }
+                                                }
+                                            ]
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/positions/{id}/fees": {
+            "get": {
+                "description": "返回 position 已领取的手续费和已赚取但尚未领取的手续费。tokensOwed 同时包含手续费和 Burn 退出的本金，按「先领本金、后领手续费」拆分",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Position"
+                ],
+                "summary": "获取 position 的手续费统计",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Position ID（NFT tokenId 或虚拟 position ID）",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.PositionFees"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/quote": {
+            "post": {
+                "description": "根据输入代币、输出代币和输入金额计算输出金额（EXACT_INPUT），或根据期望的输出金额计算所需的输入金额（EXACT_OUTPUT，含手续费），支持跨多个tick区间的精确计算；未指定池子时自动搜索直连及2跳、3跳路由；指定 indexPath 时按 SwapRouter 的执行方式在交易对的这些池子中依次成交，sqrtPriceLimitX96 与 Pool.swap 的价格限制相同",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Quote"
+                ],
+                "summary": "获取交易报价（Uniswap V3模型）",
+                "parameters": [
+                    {
+                        "description": "报价请求",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.QuoteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.QuoteResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stream": {
+            "get": {
+                "description": "升级为 WebSocket 连接。客户端发送 {\"type\":\"subscribe\",\"id\":\"q1\",\"channel\":\"quote\",\"quote\":{...}} 订阅报价，或 {\"type\":\"subscribe\",\"id\":\"p1\",\"channel\":\"pool\",\"pool\":\"0x...\"} 订阅池子；{\"type\":\"unsubscribe\",\"id\":\"q1\"} 取消订阅。\n订阅后立即推送一次当前结果，之后每当相关池子的状态变化时推送新的 QuoteResponse 或 PoolDetail，消息中的 block 为计算所依据的区块高度。需要 PostgreSQL 的池子变化通知。",
+                "tags": [
+                    "Quote"
+                ],
+                "summary": "通过 WebSocket 订阅实时报价和池子价格",
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/api.StreamMessage"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/swap/tx": {
+            "post": {
+                "description": "按当前池子状态重新计算报价，返回 SwapRouter.exactInput（EXACT_INPUT，带 amountOutMinimum）或 exactOutput（EXACT_OUTPUT，带 amountInMaximum）的 calldata。SwapRouter 一笔交易只兑换一个交易对，indexPath 中的池子按顺序成交；请求中指定的 indexPath 和 sqrtPriceLimitX96 原样写入交易",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Quote"
+                ],
+                "summary": "构建带滑点保护的兑换交易",
+                "parameters": [
+                    {
+                        "description": "交易构建请求",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.SwapTxRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.SwapTx"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tokens": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Token"
+                ],
+                "summary": "分页查询代币列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "按 symbol 过滤（大小写不敏感）",
+                        "name": "symbol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "页码，从1开始",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "每页数量，最大100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "allOf": [
+                                                {
+                                                    "$ref": "#/definitions/api.PageResult"
+                                                },
+                                                {
+                                                    "type": "object",
+                                                    "properties": {
+                                                        "items": {
+                                                            "type": "array",
+                                                            "items": {
+                                                                "$ref": "#/definitions/api.TokenInfo"
+                                                            }
+                                                        }
+                                                    }
+                                                }
+                                            ]
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.Candle": {
+            "type": "object",
+            "properties": {
+                "close": {
+                    "type": "string"
+                },
+                "high": {
+                    "type": "string"
+                },
+                "low": {
+                    "type": "string"
+                },
+                "open": {
+                    "type": "string"
+                },
+                "swapCount": {
+                    "type": "integer"
+                },
+                "time": {
+                    "description": "K 线开始时间（UTC）",
+                    "type": "string"
+                },
+                "volume0": {
+                    "description": "token0 成交量（最小单位，买卖两个方向之和）",
+                    "type": "string"
+                },
+                "volume0Formatted": {
+                    "type": "string"
+                },
+                "volume1": {
+                    "description": "token1 成交量（最小单位）",
+                    "type": "string"
+                },
+                "volume1Formatted": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.CandleSeries": {
+            "type": "object",
+            "properties": {
+                "baseToken": {
+                    "$ref": "#/definitions/api.TokenInfo"
+                },
+                "candles": {
+                    "description": "按时间升序",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Candle"
+                    }
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "inverted": {
+                    "type": "boolean"
+                },
+                "pool": {
+                    "type": "string"
+                },
+                "quoteToken": {
+                    "$ref": "#/definitions/api.TokenInfo"
+                }
+            }
+        },
+        "api.DepthPool": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "fee": {
+                    "type": "integer"
+                },
+                "liquidity": {
+                    "type": "string"
+                },
+                "poolIndex": {
+                    "type": "integer"
+                },
+                "price": {
+                    "description": "当前价格 token1/token0（按精度调整）",
+                    "type": "string"
+                },
+                "tick": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.DepthResult": {
+            "type": "object",
+            "properties": {
+                "bands": {
+                    "description": "按 tick 升序排列的价格区间，只包含有流动性的区间",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.LiquidityBand"
+                    }
+                },
+                "capacity": {
+                    "description": "所有池子到达价格区间边界前最多可成交的输入金额（含手续费）",
+                    "type": "string"
+                },
+                "pools": {
+                    "description": "参与计算的池子",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DepthPool"
+                    }
+                },
+                "slippageCurve": {
+                    "description": "各档输入金额的报价",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SlippagePoint"
+                    }
+                },
+                "token0": {
+                    "description": "价格区间的价格以 token1/token0 表示",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.TokenInfo"
+                        }
+                    ]
+                },
+                "token1": {
+                    "description": "token1",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.TokenInfo"
+                        }
+                    ]
+                },
+                "tokenIn": {
+                    "description": "滑点曲线的输入代币",
+                    "type": "string"
+                },
+                "tokenOut": {
+                    "description": "滑点曲线的输出代币",
+                    "type": "string"
+                }
+            }
+        },
+        "api.LiquidityBand": {
+            "type": "object",
+            "properties": {
+                "amount0": {
+                    "description": "按各池子当前价格，区间内锁定的 token0 数量（最小单位）",
+                    "type": "string"
+                },
+                "amount1": {
+                    "description": "区间内锁定的 token1 数量（最小单位）",
+                    "type": "string"
+                },
+                "liquidity": {
+                    "description": "区间内的活跃流动性（所有池子之和）",
+                    "type": "string"
+                },
+                "priceLower": {
+                    "description": "tickLower 对应的价格 token1/token0（按精度调整）",
+                    "type": "string"
+                },
+                "priceUpper": {
+                    "description": "tickUpper 对应的价格",
+                    "type": "string"
+                },
+                "tickLower": {
+                    "type": "integer"
+                },
+                "tickUpper": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.LiquidityEventInfo": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "流动性数量",
+                    "type": "string"
+                },
+                "amount0": {
+                    "type": "string"
+                },
+                "amount0Formatted": {
+                    "type": "string"
+                },
+                "amount1": {
+                    "type": "string"
+                },
+                "amount1Formatted": {
+                    "type": "string"
+                },
+                "blockNumber": {
+                    "type": "integer"
+                },
+                "blockTimestamp": {
+                    "type": "string"
+                },
+                "logIndex": {
+                    "type": "integer"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "positionId": {
+                    "description": "BURN 事件对应的 position，无法确定时为空",
+                    "type": "string"
+                },
+                "transactionHash": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "MINT 或 BURN",
+                    "type": "string"
+                }
+            }
+        },
+        "api.PageResult": {
+            "type": "object",
+            "properties": {
+                "items": {},
+                "pagination": {
+                    "$ref": "#/definitions/api.Pagination"
+                }
+            }
+        },
+        "api.Pagination": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "description": "每页数量",
+                    "type": "integer"
+                },
+                "page": {
+                    "description": "当前页码（从1开始）",
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "符合条件的总条数",
+                    "type": "integer"
+                },
+                "totalPages": {
+                    "description": "总页数",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.PoolDetail": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "createdBlock": {
+                    "type": "integer"
+                },
+                "fee": {
+                    "type": "integer"
+                },
+                "liquidity": {
+                    "type": "string"
+                },
+                "poolIndex": {
+                    "description": "池子在交易对下的序号，未同步时为 null",
+                    "type": "integer"
+                },
+                "price": {
+                    "description": "按 decimals 调整后的价格（1 token0 = price token1）",
+                    "type": "string"
+                },
+                "reserve0": {
+                    "type": "string"
+                },
+                "reserve0Formatted": {
+                    "type": "string"
+                },
+                "reserve1": {
+                    "type": "string"
+                },
+                "reserve1Formatted": {
+                    "type": "string"
+                },
+                "sqrtPriceX96": {
+                    "type": "string"
+                },
+                "tick": {
+                    "type": "integer"
+                },
+                "tickLower": {
+                    "type": "integer"
+                },
+                "tickUpper": {
+                    "type": "integer"
+                },
+                "token0": {
+                    "$ref": "#/definitions/api.TokenInfo"
+                },
+                "token1": {
+                    "$ref": "#/definitions/api.TokenInfo"
+                }
+            }
+        },
+        "api.PoolSplit": {
+            "type": "object",
+            "properties": {
+                "amountIn": {
+                    "description": "分配到该池子的输入金额",
+                    "type": "string"
+                },
+                "amountInUsed": {
+                    "description": "该池子实际消耗的输入金额，价格到达区间边界时小于 amountIn",
+                    "type": "string"
+                },
+                "amountOut": {
+                    "description": "该池子的输出金额",
+                    "type": "string"
+                },
+                "boundaryHit": {
+                    "description": "该池子到达的价格区间边界",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.RangeBoundary"
+                        }
+                    ]
+                },
+                "fee": {
+                    "description": "池子手续费",
+                    "type": "integer"
+                },
+                "midPriceImpact": {
+                    "description": "该池子中间价变差的百分比",
+                    "type": "number"
+                },
+                "partialFill": {
+                    "description": "该池子的价格区间被耗尽",
+                    "type": "boolean"
+                },
+                "percent": {
+                    "description": "分配到该池子的输入金额百分比",
+                    "type": "number"
+                },
+                "poolAddress": {
+                    "description": "池子地址",
+                    "type": "string"
+                },
+                "poolIndex": {
+                    "description": "池子在交易对下的序号（对应 SwapRouter 的 indexPath），未同步时为 null",
+                    "type": "integer"
+                },
+                "priceImpact": {
+                    "description": "该池子成交均价相对中间价变差的百分比",
+                    "type": "number"
+                }
+            }
+        },
+        "api.PositionFees": {
+            "type": "object",
+            "properties": {
+                "collected0": {
+                    "description": "累计领取的 token0（含本金）",
+                    "type": "string"
+                },
+                "collected1": {
+                    "description": "累计领取的 token1（含本金）",
+                    "type": "string"
+                },
+                "collectedFee0": {
+                    "description": "已领取的 token0 手续费",
+                    "type": "string"
+                },
+                "collectedFee1": {
+                    "description": "已领取的 token1 手续费",
+                    "type": "string"
+                },
+                "liquidity": {
+                    "description": "position 当前的流动性",
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "poolAddress": {
+                    "type": "string"
+                },
+                "positionId": {
+                    "type": "string"
+                },
+                "principal0": {
+                    "description": "Burn 退出的 token0 本金",
+                    "type": "string"
+                },
+                "principal1": {
+                    "description": "Burn 退出的 token1 本金",
+                    "type": "string"
+                },
+                "token0": {
+                    "type": "string"
+                },
+                "token1": {
+                    "type": "string"
+                },
+                "tokensOwed0": {
+                    "description": "当前可领取的 token0（含本金）",
+                    "type": "string"
+                },
+                "tokensOwed1": {
+                    "description": "当前可领取的 token1（含本金）",
+                    "type": "string"
+                },
+                "uncollectedFee0": {
+                    "description": "已赚取但尚未领取的 token0 手续费",
+                    "type": "string"
+                },
+                "uncollectedFee1": {
+                    "description": "已赚取但尚未领取的 token1 手续费",
+                    "type": "string"
+                },
+                "unsettledFee0": {
+                    "description": "上次结算后新产生、尚未计入 tokensOwed 的 token0 手续费",
+                    "type": "string"
+                },
+                "unsettledFee1": {
+                    "description": "上次结算后新产生、尚未计入 tokensOwed 的 token1 手续费",
+                    "type": "string"
+                },
+                "updatedBlock": {
+                    "description": "tokensOwed 最后同步的区块",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.PositionInfo": {
+            "type": "object",
+            "properties": {
+                "createdBlock": {
+                    "type": "integer"
+                },
+                "id": {
+                    "description": "NFT tokenId 或虚拟 position ID",
+                    "type": "string"
+                },
+                "liquidity": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "poolAddress": {
+                    "type": "string"
+                },
+                "tickLower": {
+                    "type": "integer"
+                },
+                "tickUpper": {
+                    "type": "integer"
+                },
+                "token0": {
+                    "$ref": "#/definitions/api.TokenInfo"
+                },
+                "token1": {
+                    "$ref": "#/definitions/api.TokenInfo"
+                },
+                "tokensOwed0": {
+                    "type": "string"
+                },
+                "tokensOwed0Formatted": {
+                    "type": "string"
+                },
+                "tokensOwed1": {
+                    "type": "string"
+                },
+                "tokensOwed1Formatted": {
+                    "type": "string"
+                },
+                "updatedBlock": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.QuoteRequest": {
+            "type": "object",
+            "required": [
+                "tokenIn",
+                "tokenOut"
+            ],
+            "properties": {
+                "amountFormat": {
+                    "description": "可选：raw（默认，最小单位的整数）或 decimal（按代币精度的小数，如 \"1.5\"）",
+                    "type": "string"
+                },
+                "amountIn": {
+                    "description": "精确输入时必填：输入金额",
+                    "type": "string"
+                },
+                "amountOut": {
+                    "description": "精确输出时必填：期望得到的输出金额",
+                    "type": "string"
+                },
+                "indexPath": {
+                    "description": "可选：交易对中按顺序成交的池子序号（与 SwapRouter 的 indexPath 相同），与 poolAddress 互斥",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "poolAddress": {
+                    "description": "可选：指定池子地址",
+                    "type": "string"
+                },
+                "sqrtPriceLimitX96": {
+                    "description": "可选：价格限制（与 Pool.swap 相同），需要同时指定 poolAddress 或 indexPath",
+                    "type": "string"
+                },
+                "tokenIn": {
+                    "description": "输入代币地址或 symbol",
+                    "type": "string"
+                },
+                "tokenOut": {
+                    "description": "输出代币地址或 symbol",
+                    "type": "string"
+                },
+                "tradeType": {
+                    "description": "可选：EXACT_INPUT（默认）或 EXACT_OUTPUT",
+                    "type": "string"
+                },
+                "verify": {
+                    "description": "可选：同时调用链上 SwapRouter.quoteExactInput 对比结果（仅 EXACT_INPUT）",
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.QuoteResponse": {
+            "type": "object",
+            "properties": {
+                "amountIn": {
+                    "description": "输入金额（最小单位）",
+                    "type": "string"
+                },
+                "amountInFormatted": {
+                    "description": "按 tokenIn 精度格式化的输入金额，精度未知时不返回",
+                    "type": "string"
+                },
+                "amountInRemaining": {
+                    "description": "池子价格区间耗尽后未成交的输入金额",
+                    "type": "string"
+                },
+                "amountInUsed": {
+                    "description": "实际消耗的输入金额（含手续费），部分成交时小于 amountIn",
+                    "type": "string"
+                },
+                "amountOut": {
+                    "description": "输出金额（最小单位）",
+                    "type": "string"
+                },
+                "amountOutFormatted": {
+                    "description": "按 tokenOut 精度格式化的输出金额，精度未知时不返回",
+                    "type": "string"
+                },
+                "boundaryHit": {
+                    "description": "单个池子部分成交时到达的价格区间边界",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.RangeBoundary"
+                        }
+                    ]
+                },
+                "crossedTicks": {
+                    "description": "跨越的tick数量",
+                    "type": "integer"
+                },
+                "executionPrice": {
+                    "description": "成交均价 amountOut/amountIn（按精度调整）",
+                    "type": "string"
+                },
+                "finalPrice": {
+                    "description": "交易后的中间价（tokenOut/tokenIn，按精度调整；多跳或拆单时为空）",
+                    "type": "string"
+                },
+                "initialPrice": {
+                    "description": "交易前的中间价（tokenOut/tokenIn，按精度调整；多跳或拆单时为空）",
+                    "type": "string"
+                },
+                "midPriceImpact": {
+                    "description": "交易后中间价相对交易前变差的百分比",
+                    "type": "number"
+                },
+                "newSqrtPriceX96": {
+                    "description": "交易后的价格",
+                    "type": "string"
+                },
+                "newTick": {
+                    "description": "交易后的tick",
+                    "type": "integer"
+                },
+                "partialFill": {
+                    "description": "只成交了部分输入",
+                    "type": "boolean"
+                },
+                "path": {
+                    "description": "代币路径（未指定池子时返回）",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "poolAddress": {
+                    "description": "使用的池子地址",
+                    "type": "string"
+                },
+                "priceImpact": {
+                    "description": "成交均价相对交易前中间价变差的百分比（包含手续费）",
+                    "type": "number"
+                },
+                "remainderRoute": {
+                    "description": "指定池子部分成交时，未成交的输入在交易对其他池子中的拆单报价",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.RouteHop"
+                        }
+                    ]
+                },
+                "route": {
+                    "description": "路由每一跳的详情（未指定池子时返回）",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.RouteHop"
+                    }
+                },
+                "simulated": {
+                    "type": "boolean"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "tokenIn": {
+                    "description": "输入代币",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.TokenInfo"
                         }
-                    },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/api.Response"
+                    ]
+                },
+                "tokenOut": {
+                    "description": "输出代币",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.TokenInfo"
                         }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.Response"
+                    ]
+                },
+                "totalAmountOut": {
+                    "description": "amountOut 加上 remainderRoute 的输出",
+                    "type": "string"
+                },
+                "tradeType": {
+                    "description": "报价类型：EXACT_INPUT 或 EXACT_OUTPUT",
+                    "type": "string"
+                },
+                "verification": {
+                    "description": "verify 为 true 时返回链上报价的对比结果",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.QuoteVerification"
                         }
+                    ]
+                }
+            }
+        },
+        "api.QuoteVerification": {
+            "type": "object",
+            "properties": {
+                "block": {
+                    "description": "eth_call 使用的区块：报价所用池子状态的扫描高度，为 0 时为最新区块",
+                    "type": "integer"
+                },
+                "difference": {
+                    "description": "onchainAmountOut - offchainAmountOut",
+                    "type": "string"
+                },
+                "differenceBps": {
+                    "description": "差值相对链上输出的万分比",
+                    "type": "number"
+                },
+                "error": {
+                    "description": "无法完成对比时的原因",
+                    "type": "string"
+                },
+                "indexPath": {
+                    "description": "按顺序成交的池子序号（与 /swap/tx 选择的顺序相同，或请求指定的 indexPath）",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "match": {
+                    "description": "两者完全一致",
+                    "type": "boolean"
+                },
+                "offchainAmountOut": {
+                    "description": "按 SwapRouter 的执行方式在链下模拟该 indexPath 的输出",
+                    "type": "string"
+                },
+                "onchainAmountOut": {
+                    "description": "quoteExactInput 的输出",
+                    "type": "string"
+                },
+                "pools": {
+                    "description": "indexPath 对应的池子地址",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.QuoteRequest": {
+        },
+        "api.RangeBoundary": {
+            "type": "object",
+            "properties": {
+                "price": {
+                    "description": "边界价格 token1/token0（按精度调整）",
+                    "type": "string"
+                },
+                "side": {
+                    "description": "lower：token0 -\u003e token1 时价格下降到 tick_lower；upper：token1 -\u003e token0 时价格上升到 tick_upper；limit：先到达了 sqrtPriceLimitX96",
+                    "type": "string"
+                },
+                "tick": {
+                    "description": "边界 tick（limit 时为价格限制所在的 tick）",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.Response": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer"
+                },
+                "data": {},
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.RouteHop": {
             "type": "object",
-            "required": [
-                "amountIn",
-                "tokenIn",
-                "tokenOut"
-            ],
             "properties": {
                 "amountIn": {
+                    "description": "该跳输入金额",
+                    "type": "string"
+                },
+                "amountInRemaining": {
+                    "description": "该跳所有池子的价格区间都被耗尽后未成交的输入金额",
+                    "type": "string"
+                },
+                "amountInUsed": {
+                    "description": "该跳实际消耗的输入金额",
+                    "type": "string"
+                },
+                "amountOut": {
+                    "description": "该跳输出金额",
+                    "type": "string"
+                },
+                "crossedTicks": {
+                    "description": "该跳跨越的tick数量",
+                    "type": "integer"
+                },
+                "executionPrice": {
+                    "description": "该跳成交均价 amountOut/amountIn（按精度调整）",
                     "type": "string"
                 },
+                "fee": {
+                    "description": "该跳池子手续费（拆单时为分配金额最多的池子）",
+                    "type": "integer"
+                },
+                "midPriceImpact": {
+                    "description": "该跳中间价变差的百分比（拆单时按输入金额加权）",
+                    "type": "number"
+                },
+                "partialFill": {
+                    "description": "该跳只成交了部分输入",
+                    "type": "boolean"
+                },
                 "poolAddress": {
-                    "description": "可选：指定池子地址",
+                    "description": "该跳使用的池子地址（拆单时为分配金额最多的池子）",
                     "type": "string"
                 },
+                "priceImpact": {
+                    "description": "该跳成交均价相对中间价变差的百分比（拆单时按输入金额加权）",
+                    "type": "number"
+                },
+                "splits": {
+                    "description": "该跳在同一交易对多个池子间的拆单明细",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.PoolSplit"
+                    }
+                },
                 "tokenIn": {
+                    "description": "该跳输入代币",
                     "type": "string"
                 },
                 "tokenOut": {
+                    "description": "该跳输出代币",
                     "type": "string"
                 }
             }
         },
-        "api.QuoteResponse": {
+        "api.SlippagePoint": {
             "type": "object",
             "properties": {
                 "amountIn": {
-                    "description": "输入金额",
+                    "type": "string"
+                },
+                "amountInFormatted": {
                     "type": "string"
                 },
                 "amountOut": {
-                    "description": "输出金额",
                     "type": "string"
                 },
-                "crossedTicks": {
-                    "description": "跨越的tick数量",
+                "amountOutFormatted": {
+                    "type": "string"
+                },
+                "executionPrice": {
+                    "description": "tokenOut/tokenIn（按精度调整）",
+                    "type": "string"
+                },
+                "filled": {
+                    "description": "输入全部成交，没有池子价格区间耗尽后剩余的输入",
+                    "type": "boolean"
+                },
+                "midPriceImpact": {
+                    "description": "中间价变差的百分比",
+                    "type": "number"
+                },
+                "priceImpact": {
+                    "description": "成交均价相对中间价变差的百分比",
+                    "type": "number"
+                }
+            }
+        },
+        "api.StreamMessage": {
+            "type": "object",
+            "properties": {
+                "block": {
+                    "description": "计算所依据的池子状态的区块高度",
                     "type": "integer"
                 },
-                "finalPrice": {
-                    "description": "最终价格",
+                "data": {
+                    "description": "quote 为 QuoteResponse，pool 为 PoolDetail"
+                },
+                "id": {
+                    "description": "对应的订阅 ID",
                     "type": "string"
                 },
-                "initialPrice": {
-                    "description": "初始价格",
+                "message": {
+                    "description": "错误信息",
                     "type": "string"
                 },
-                "newSqrtPriceX96": {
-                    "description": "交易后的价格",
+                "type": {
+                    "description": "quote、pool、unsubscribed 或 error",
+                    "type": "string"
+                }
+            }
+        },
+        "api.SwapInfo": {
+            "type": "object",
+            "properties": {
+                "amount0": {
+                    "description": "池子视角：正数为流入池子，负数为流出池子",
                     "type": "string"
                 },
-                "newTick": {
-                    "description": "交易后的tick",
+                "amount0Formatted": {
+                    "type": "string"
+                },
+                "amount1": {
+                    "type": "string"
+                },
+                "amount1Formatted": {
+                    "type": "string"
+                },
+                "blockNumber": {
+                    "type": "integer"
+                },
+                "blockTimestamp": {
+                    "type": "string"
+                },
+                "liquidity": {
+                    "type": "string"
+                },
+                "logIndex": {
+                    "type": "integer"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "sender": {
+                    "type": "string"
+                },
+                "sqrtPriceX96": {
+                    "type": "string"
+                },
+                "tick": {
+                    "type": "integer"
+                },
+                "transactionHash": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.SwapTx": {
+            "type": "object",
+            "properties": {
+                "amountIn": {
+                    "description": "按当前池子状态模拟的输入金额",
+                    "type": "string"
+                },
+                "amountInMaximum": {
+                    "description": "EXACT_OUTPUT：amountIn 加上滑点",
+                    "type": "string"
+                },
+                "amountOut": {
+                    "description": "按当前池子状态模拟的输出金额",
+                    "type": "string"
+                },
+                "amountOutMinimum": {
+                    "description": "EXACT_INPUT：amountOut 扣除滑点",
+                    "type": "string"
+                },
+                "data": {
+                    "description": "ABI 编码的 calldata",
+                    "type": "string"
+                },
+                "deadline": {
+                    "type": "integer"
+                },
+                "indexPath": {
+                    "description": "按顺序成交的池子序号",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "method": {
+                    "description": "exactInput 或 exactOutput",
+                    "type": "string"
+                },
+                "pools": {
+                    "description": "indexPath 对应的池子地址",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "slippageBps": {
+                    "type": "integer"
+                },
+                "sqrtPriceLimitX96": {
+                    "type": "string"
+                },
+                "to": {
+                    "description": "SwapRouter 地址",
+                    "type": "string"
+                },
+                "tokenIn": {
+                    "type": "string"
+                },
+                "tokenOut": {
+                    "type": "string"
+                },
+                "tradeType": {
+                    "type": "string"
+                },
+                "value": {
+                    "description": "SwapRouter 不接收原生币，固定为 0",
+                    "type": "string"
+                }
+            }
+        },
+        "api.SwapTxRequest": {
+            "type": "object",
+            "required": [
+                "recipient",
+                "tokenIn",
+                "tokenOut"
+            ],
+            "properties": {
+                "amountFormat": {
+                    "description": "可选：raw（默认，最小单位的整数）或 decimal（按代币精度的小数，如 \"1.5\"）",
+                    "type": "string"
+                },
+                "amountIn": {
+                    "description": "精确输入时必填：输入金额",
+                    "type": "string"
+                },
+                "amountOut": {
+                    "description": "精确输出时必填：期望得到的输出金额",
+                    "type": "string"
+                },
+                "deadline": {
+                    "description": "可选：交易截止时间（unix 秒），默认当前时间 + 1200 秒",
                     "type": "integer"
                 },
+                "indexPath": {
+                    "description": "可选：交易对中按顺序成交的池子序号（与 SwapRouter 的 indexPath 相同），与 poolAddress 互斥",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
                 "poolAddress": {
-                    "description": "使用的池子地址",
+                    "description": "可选：指定池子地址",
                     "type": "string"
                 },
-                "priceImpact": {
-                    "description": "价格影响百分比",
-                    "type": "number"
+                "recipient": {
+                    "description": "接收输出代币的地址",
+                    "type": "string"
                 },
-                "simulated": {
-                    "type": "boolean"
+                "slippageBps": {
+                    "description": "可选：滑点容忍度（基点），默认 50（0.5%），最大 5000",
+                    "type": "integer"
                 },
-                "success": {
+                "sqrtPriceLimitX96": {
+                    "description": "可选：价格限制（与 Pool.swap 相同），需要同时指定 poolAddress 或 indexPath",
+                    "type": "string"
+                },
+                "tokenIn": {
+                    "description": "输入代币地址或 symbol",
+                    "type": "string"
+                },
+                "tokenOut": {
+                    "description": "输出代币地址或 symbol",
+                    "type": "string"
+                },
+                "tradeType": {
+                    "description": "可选：EXACT_INPUT（默认）或 EXACT_OUTPUT",
+                    "type": "string"
+                },
+                "verify": {
+                    "description": "可选：同时调用链上 SwapRouter.quoteExactInput 对比结果（仅 EXACT_INPUT）",
                     "type": "boolean"
                 }
             }
         },
-        "api.Response": {
+        "api.TokenInfo": {
             "type": "object",
             "properties": {
-                "code": {
+                "address": {
+                    "type": "string"
+                },
+                "decimals": {
+                    "description": "未同步到代币信息时为 null，此时不返回格式化金额",
                     "type": "integer"
                 },
-                "data": {},
-                "message": {
+                "name": {
+                    "type": "string"
+                },
+                "symbol": {
                     "type": "string"
                 }
             }
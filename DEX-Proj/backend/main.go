@@ -8,7 +8,9 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
@@ -35,6 +37,10 @@ func main() {
 	dbPath := flag.String("db", "", "SQLite 数据库文件路径（如果使用 SQLite）")
 	port := flag.String("port", "8080", "服务端口")
 	mode := flag.String("mode", "release", "运行模式: debug, release")
+	cacheInterval := flag.Duration("pool-cache-interval", 2*time.Second, "池子缓存增量刷新间隔，为 0 时不使用缓存，报价直接查询数据库")
+	poolNotify := flag.Bool("pool-notify", true, "LISTEN sync 服务的池子变化通知并立即刷新池子缓存（仅 PostgreSQL）")
+	swapRouter := flag.String("swap-router", "", "SwapRouter 合约地址（默认读取配置文件的 Contracts.SwapRouter）")
+	rpcURL := flag.String("rpc", "", "以太坊 RPC 地址，用于校验链上报价（默认读取配置文件的 RPC.Urls 的第一个节点或 RPC.Url）")
 	flag.Parse()
 
 	// 设置 Gin 模式
@@ -42,6 +48,7 @@ func main() {
 
 	var db *sql.DB
 	var err error
+	var connStr string // PostgreSQL 连接字符串，使用 SQLite 时为空
 
 	// 优先使用 PostgreSQL（从配置文件读取）
 	if *dbPath == "" {
@@ -56,13 +63,20 @@ func main() {
 				log.Fatalf("打开数据库失败: %v", err)
 			}
 		} else {
+			if *swapRouter == "" {
+				*swapRouter = cfg.Contracts.SwapRouter
+			}
+			if *rpcURL == "" {
+				*rpcURL = cfg.RPCURL()
+			}
+
 			// 使用 PostgreSQL
 			log.Printf("使用 PostgreSQL 数据库: %s:%d/%s", cfg.Database.Host, cfg.Database.Port, cfg.Database.Name)
 			sslMode := "require"
 			if cfg.Database.Host == "localhost" || cfg.Database.Host == "127.0.0.1" {
 				sslMode = "disable"
 			}
-			connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			connStr = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 				cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.Name, sslMode)
 			db, err = sql.Open("postgres", connStr)
 			if err != nil {
@@ -90,7 +104,44 @@ func main() {
 
 	// 创建 Quote 和 Handler
 	quote := api.NewQuote(db)
-	handler := api.NewHandler(quote)
+	var cache *api.PoolCache
+	if *cacheInterval > 0 {
+		cache = api.NewPoolCache(db)
+		if err := cache.Load(); err != nil {
+			log.Printf("加载池子缓存失败，报价直接查询数据库: %v", err)
+			cache = nil
+		} else {
+			quote.SetPoolCache(cache)
+			go cache.Run(*cacheInterval, nil)
+		}
+	}
+
+	handler := api.NewHandler(quote, *swapRouter)
+
+	// 配置了 RPC 时支持报价请求的 verify：通过 eth_call 调用 SwapRouter.quoteExactInput 对比链下结果
+	if *rpcURL != "" {
+		client, err := ethclient.Dial(*rpcURL)
+		if err != nil {
+			log.Printf("连接 RPC 失败，不支持链上报价校验: %v", err)
+		} else if quoter, err := api.NewOnchainQuoter(client, *swapRouter); err != nil {
+			log.Printf("不支持链上报价校验: %v", err)
+		} else {
+			handler.SetOnchainQuoter(quoter)
+		}
+	} else {
+		log.Printf("⚠️  未配置 RPC 地址（-rpc、RPC.Urls 或 RPC.Url），报价请求的 verify 返回 503")
+	}
+
+	// 监听 sync 服务的池子变化通知，池子变化后立即刷新缓存，并推送给 WebSocket 订阅者
+	if *poolNotify && connStr != "" {
+		notifier := api.NewPoolNotifier(connStr, cache)
+		handler.SetPoolNotifier(notifier)
+		go func() {
+			if err := notifier.Run(nil); err != nil {
+				log.Printf("监听池子变化通知失败: %v", err)
+			}
+		}()
+	}
 
 	// 设置路由
 	api.SetupRoutes(r, handler)
@@ -14,6 +14,22 @@ type Config struct {
 		Password string `yaml:"Password"`
 		Name     string `yaml:"Name"`
 	} `yaml:"Database"`
+	RPC struct {
+		Url string `yaml:"Url"`
+		// Urls 多个 RPC 节点，与 sync 服务相同，配置后忽略 Url
+		Urls []string `yaml:"Urls"`
+	} `yaml:"RPC"`
+	Contracts struct {
+		SwapRouter string `yaml:"SwapRouter"`
+	} `yaml:"Contracts"`
+}
+
+// RPCURL 返回链上查询使用的 RPC 地址：配置了 RPC.Urls 时使用第一个节点（sync 服务此时忽略 RPC.Url），否则使用 RPC.Url
+func (c *Config) RPCURL() string {
+	if len(c.RPC.Urls) > 0 {
+		return c.RPC.Urls[0]
+	}
+	return c.RPC.Url
 }
 
 // LoadConfig 从文件加载配置
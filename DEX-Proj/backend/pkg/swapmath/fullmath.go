@@ -0,0 +1,82 @@
+// Package swapmath 是 MetaNodeSwap 合约中 TickMath、SqrtPriceMath、SwapMath、FullMath 的 Go 实现
+//
+// 所有计算都使用 big.Int 模拟 Solidity 的 uint256/int256 运算，舍入方向与合约逐一对应，
+// 保证链下报价与链上 Pool.swap 的结果完全一致。合约中 require/revert 的情况在这里返回 error。
+package swapmath
+
+import (
+	"errors"
+	"math/big"
+)
+
+var (
+	// Q96 = 2^96，对应 FixedPoint96.Q96
+	Q96 = new(big.Int).Lsh(big.NewInt(1), 96)
+	// Q128 = 2^128，对应 FixedPoint128.Q128
+	Q128 = new(big.Int).Lsh(big.NewInt(1), 128)
+
+	maxUint128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	maxUint160 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+	maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+)
+
+var (
+	ErrMulDivOverflow = errors.New("swapmath: mulDiv 结果溢出 uint256 或分母为0")
+	ErrUint160        = errors.New("swapmath: 结果溢出 uint160")
+	ErrInvalidInput   = errors.New("swapmath: 参数超出合约类型范围")
+)
+
+// MulDiv 对应 FullMath.mulDiv：floor(a * b / denominator)，中间乘积按 512 位计算
+// 分母为0或结果超过 uint256 时合约 revert，这里返回 ErrMulDivOverflow
+func MulDiv(a, b, denominator *big.Int) (*big.Int, error) {
+	if denominator.Sign() == 0 {
+		return nil, ErrMulDivOverflow
+	}
+	result := new(big.Int).Mul(a, b)
+	result.Quo(result, denominator)
+	if result.Cmp(maxUint256) > 0 {
+		return nil, ErrMulDivOverflow
+	}
+	return result, nil
+}
+
+// MulDivRoundingUp 对应 FullMath.mulDivRoundingUp：ceil(a * b / denominator)
+func MulDivRoundingUp(a, b, denominator *big.Int) (*big.Int, error) {
+	if denominator.Sign() == 0 {
+		return nil, ErrMulDivOverflow
+	}
+	product := new(big.Int).Mul(a, b)
+	result, remainder := new(big.Int).QuoRem(product, denominator, new(big.Int))
+	if result.Cmp(maxUint256) > 0 {
+		return nil, ErrMulDivOverflow
+	}
+	if remainder.Sign() != 0 {
+		// require(++result > 0)：结果为 uint256 最大值时再加1会溢出
+		if result.Cmp(maxUint256) == 0 {
+			return nil, ErrMulDivOverflow
+		}
+		result.Add(result, big.NewInt(1))
+	}
+	return result, nil
+}
+
+// DivRoundingUp 对应 UnsafeMath.divRoundingUp：ceil(x / y)
+// 与 EVM 的 div/mod 一致，y 为0时返回0
+func DivRoundingUp(x, y *big.Int) *big.Int {
+	if y.Sign() == 0 {
+		return big.NewInt(0)
+	}
+	quotient, remainder := new(big.Int).QuoRem(x, y, new(big.Int))
+	if remainder.Sign() > 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	return quotient
+}
+
+// toUint160 对应 SafeCast.toUint160
+func toUint160(x *big.Int) (*big.Int, error) {
+	if x.Sign() < 0 || x.Cmp(maxUint160) > 0 {
+		return nil, ErrUint160
+	}
+	return x, nil
+}
@@ -0,0 +1,140 @@
+package swapmath
+
+import (
+	"errors"
+	"math/big"
+)
+
+var ErrPriceRequire = errors.New("swapmath: SqrtPriceMath require 失败（价格或流动性为0，或价格越界）")
+
+// GetNextSqrtPriceFromAmount0RoundingUp 对应 SqrtPriceMath.getNextSqrtPriceFromAmount0RoundingUp
+// 根据 token0 的变化量计算新价格，结果向上取整
+//
+// 公式：sqrt(P_new) = L * sqrt(P) / (L ± amount * sqrt(P))
+func GetNextSqrtPriceFromAmount0RoundingUp(sqrtPX96, liquidity, amount *big.Int, add bool) (*big.Int, error) {
+	// amount 为0时直接返回，否则结果不保证等于输入价格
+	if amount.Sign() == 0 {
+		return new(big.Int).Set(sqrtPX96), nil
+	}
+	numerator1 := new(big.Int).Lsh(liquidity, 96)
+	product := new(big.Int).Mul(amount, sqrtPX96)
+
+	if add {
+		// 合约用 Solidity 0.8 编译，amount * sqrtPX96 和 numerator1 + product 是检查溢出的运算，溢出时直接 revert，
+		// 不会走到 Uniswap V3（0.7）中 divRoundingUp(numerator1, numerator1 / sqrtPX96 + amount) 的分支
+		if product.Cmp(maxUint256) > 0 {
+			return nil, ErrPriceRequire
+		}
+		denominator := new(big.Int).Add(numerator1, product)
+		if denominator.Cmp(maxUint256) > 0 {
+			return nil, ErrPriceRequire
+		}
+		return MulDivRoundingUp(numerator1, sqrtPX96, denominator)
+	}
+
+	// 乘积溢出说明分母必然下溢
+	if product.Cmp(maxUint256) > 0 || numerator1.Cmp(product) <= 0 {
+		return nil, ErrPriceRequire
+	}
+	denominator := new(big.Int).Sub(numerator1, product)
+	result, err := MulDivRoundingUp(numerator1, sqrtPX96, denominator)
+	if err != nil {
+		return nil, err
+	}
+	return toUint160(result)
+}
+
+// GetNextSqrtPriceFromAmount1RoundingDown 对应 SqrtPriceMath.getNextSqrtPriceFromAmount1RoundingDown
+// 根据 token1 的变化量计算新价格，结果向下取整
+//
+// 公式：sqrt(P_new) = sqrt(P) ± amount / L
+func GetNextSqrtPriceFromAmount1RoundingDown(sqrtPX96, liquidity, amount *big.Int, add bool) (*big.Int, error) {
+	if add {
+		// amount <= uint160 最大值时合约直接移位相除，否则用 mulDiv，两者结果相同
+		quotient, err := MulDiv(amount, Q96, liquidity)
+		if err != nil {
+			return nil, err
+		}
+		result := new(big.Int).Add(sqrtPX96, quotient)
+		if result.Cmp(maxUint256) > 0 {
+			return nil, ErrPriceRequire
+		}
+		return toUint160(result)
+	}
+
+	quotient, err := MulDivRoundingUp(amount, Q96, liquidity)
+	if err != nil {
+		return nil, err
+	}
+	if sqrtPX96.Cmp(quotient) <= 0 {
+		return nil, ErrPriceRequire
+	}
+	return new(big.Int).Sub(sqrtPX96, quotient), nil
+}
+
+// GetNextSqrtPriceFromInput 对应 SqrtPriceMath.getNextSqrtPriceFromInput
+// 舍入方向保证新价格不会越过目标价格
+func GetNextSqrtPriceFromInput(sqrtPX96, liquidity, amountIn *big.Int, zeroForOne bool) (*big.Int, error) {
+	if sqrtPX96.Sign() <= 0 || liquidity.Sign() <= 0 {
+		return nil, ErrPriceRequire
+	}
+	if zeroForOne {
+		return GetNextSqrtPriceFromAmount0RoundingUp(sqrtPX96, liquidity, amountIn, true)
+	}
+	return GetNextSqrtPriceFromAmount1RoundingDown(sqrtPX96, liquidity, amountIn, true)
+}
+
+// GetNextSqrtPriceFromOutput 对应 SqrtPriceMath.getNextSqrtPriceFromOutput
+// 舍入方向保证新价格会越过目标价格
+func GetNextSqrtPriceFromOutput(sqrtPX96, liquidity, amountOut *big.Int, zeroForOne bool) (*big.Int, error) {
+	if sqrtPX96.Sign() <= 0 || liquidity.Sign() <= 0 {
+		return nil, ErrPriceRequire
+	}
+	if zeroForOne {
+		return GetNextSqrtPriceFromAmount1RoundingDown(sqrtPX96, liquidity, amountOut, false)
+	}
+	return GetNextSqrtPriceFromAmount0RoundingUp(sqrtPX96, liquidity, amountOut, false)
+}
+
+// GetAmount0Delta 对应 SqrtPriceMath.getAmount0Delta(uint160, uint160, uint128, bool)
+// 计算两个价格之间的 token0 数量：L * 2^96 * (sqrtB - sqrtA) / sqrtB / sqrtA
+func GetAmount0Delta(sqrtRatioAX96, sqrtRatioBX96, liquidity *big.Int, roundUp bool) (*big.Int, error) {
+	if sqrtRatioAX96.Cmp(sqrtRatioBX96) > 0 {
+		sqrtRatioAX96, sqrtRatioBX96 = sqrtRatioBX96, sqrtRatioAX96
+	}
+
+	numerator1 := new(big.Int).Lsh(liquidity, 96)
+	numerator2 := new(big.Int).Sub(sqrtRatioBX96, sqrtRatioAX96)
+
+	if sqrtRatioAX96.Sign() <= 0 {
+		return nil, ErrPriceRequire
+	}
+
+	if roundUp {
+		v, err := MulDivRoundingUp(numerator1, numerator2, sqrtRatioBX96)
+		if err != nil {
+			return nil, err
+		}
+		return DivRoundingUp(v, sqrtRatioAX96), nil
+	}
+
+	v, err := MulDiv(numerator1, numerator2, sqrtRatioBX96)
+	if err != nil {
+		return nil, err
+	}
+	return v.Quo(v, sqrtRatioAX96), nil
+}
+
+// GetAmount1Delta 对应 SqrtPriceMath.getAmount1Delta(uint160, uint160, uint128, bool)
+// 计算两个价格之间的 token1 数量：L * (sqrtB - sqrtA) / 2^96
+func GetAmount1Delta(sqrtRatioAX96, sqrtRatioBX96, liquidity *big.Int, roundUp bool) (*big.Int, error) {
+	if sqrtRatioAX96.Cmp(sqrtRatioBX96) > 0 {
+		sqrtRatioAX96, sqrtRatioBX96 = sqrtRatioBX96, sqrtRatioAX96
+	}
+
+	diff := new(big.Int).Sub(sqrtRatioBX96, sqrtRatioAX96)
+	if roundUp {
+		return MulDivRoundingUp(liquidity, diff, Q96)
+	}
+	return MulDiv(liquidity, diff, Q96)
+}
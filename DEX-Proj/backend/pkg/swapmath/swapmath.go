@@ -0,0 +1,129 @@
+package swapmath
+
+import (
+	"math/big"
+)
+
+// SwapStep computeSwapStep 的计算结果
+type SwapStep struct {
+	SqrtRatioNextX96 *big.Int // 交易后的价格，不会越过目标价格
+	AmountIn         *big.Int // 输入金额（不含手续费）
+	AmountOut        *big.Int // 输出金额
+	FeeAmount        *big.Int // 作为手续费收取的输入金额
+}
+
+var feeDenominator = big.NewInt(1000000)
+
+// ComputeSwapStep 对应 SwapMath.computeSwapStep：计算在单个价格区间内交易的结果
+//
+// amountRemaining 为正数表示精确输入（剩余可支付的输入金额，含手续费），
+// 为负数表示精确输出（剩余需要得到的输出金额）；交易方向由当前价格和目标价格推断：
+// sqrtRatioCurrentX96 >= sqrtRatioTargetX96 时为 zeroForOne。feePips 以百万分之一为单位（3000 = 0.3%）。
+func ComputeSwapStep(sqrtRatioCurrentX96, sqrtRatioTargetX96, liquidity, amountRemaining *big.Int, feePips int64) (*SwapStep, error) {
+	if sqrtRatioCurrentX96.Sign() < 0 || sqrtRatioCurrentX96.Cmp(maxUint160) > 0 ||
+		sqrtRatioTargetX96.Sign() < 0 || sqrtRatioTargetX96.Cmp(maxUint160) > 0 ||
+		liquidity.Sign() < 0 || liquidity.Cmp(maxUint128) > 0 ||
+		feePips < 0 || feePips >= 1000000 {
+		return nil, ErrInvalidInput
+	}
+
+	zeroForOne := sqrtRatioCurrentX96.Cmp(sqrtRatioTargetX96) >= 0
+	exactIn := amountRemaining.Sign() >= 0
+	fee := big.NewInt(feePips)
+	feeComplement := new(big.Int).Sub(feeDenominator, fee)
+
+	step := &SwapStep{}
+	var err error
+
+	if exactIn {
+		amountRemainingLessFee, err := MulDiv(amountRemaining, feeComplement, feeDenominator)
+		if err != nil {
+			return nil, err
+		}
+		if zeroForOne {
+			step.AmountIn, err = GetAmount0Delta(sqrtRatioTargetX96, sqrtRatioCurrentX96, liquidity, true)
+		} else {
+			step.AmountIn, err = GetAmount1Delta(sqrtRatioCurrentX96, sqrtRatioTargetX96, liquidity, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if amountRemainingLessFee.Cmp(step.AmountIn) >= 0 {
+			step.SqrtRatioNextX96 = new(big.Int).Set(sqrtRatioTargetX96)
+		} else {
+			step.SqrtRatioNextX96, err = GetNextSqrtPriceFromInput(sqrtRatioCurrentX96, liquidity, amountRemainingLessFee, zeroForOne)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		amountOutRemaining := new(big.Int).Neg(amountRemaining)
+		if zeroForOne {
+			step.AmountOut, err = GetAmount1Delta(sqrtRatioTargetX96, sqrtRatioCurrentX96, liquidity, false)
+		} else {
+			step.AmountOut, err = GetAmount0Delta(sqrtRatioCurrentX96, sqrtRatioTargetX96, liquidity, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if amountOutRemaining.Cmp(step.AmountOut) >= 0 {
+			step.SqrtRatioNextX96 = new(big.Int).Set(sqrtRatioTargetX96)
+		} else {
+			step.SqrtRatioNextX96, err = GetNextSqrtPriceFromOutput(sqrtRatioCurrentX96, liquidity, amountOutRemaining, zeroForOne)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	max := sqrtRatioTargetX96.Cmp(step.SqrtRatioNextX96) == 0
+
+	// 计算实际的输入/输出金额
+	if zeroForOne {
+		if !(max && exactIn) {
+			step.AmountIn, err = GetAmount0Delta(step.SqrtRatioNextX96, sqrtRatioCurrentX96, liquidity, true)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !(max && !exactIn) {
+			step.AmountOut, err = GetAmount1Delta(step.SqrtRatioNextX96, sqrtRatioCurrentX96, liquidity, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if !(max && exactIn) {
+			step.AmountIn, err = GetAmount1Delta(sqrtRatioCurrentX96, step.SqrtRatioNextX96, liquidity, true)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !(max && !exactIn) {
+			step.AmountOut, err = GetAmount0Delta(sqrtRatioCurrentX96, step.SqrtRatioNextX96, liquidity, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 精确输出时，输出金额不能超过剩余需要的输出金额
+	if !exactIn {
+		amountOutRemaining := new(big.Int).Neg(amountRemaining)
+		if step.AmountOut.Cmp(amountOutRemaining) > 0 {
+			step.AmountOut = amountOutRemaining
+		}
+	}
+
+	if exactIn && step.SqrtRatioNextX96.Cmp(sqrtRatioTargetX96) != 0 {
+		// 没有到达目标价格，剩余的输入全部作为手续费
+		step.FeeAmount = new(big.Int).Sub(amountRemaining, step.AmountIn)
+	} else {
+		step.FeeAmount, err = MulDivRoundingUp(step.AmountIn, fee, feeComplement)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return step, nil
+}
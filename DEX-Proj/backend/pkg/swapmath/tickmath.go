@@ -0,0 +1,155 @@
+package swapmath
+
+import (
+	"errors"
+	"math/big"
+)
+
+const (
+	// MinTick 对应 TickMath.MIN_TICK
+	MinTick = -887272
+	// MaxTick 对应 TickMath.MAX_TICK
+	MaxTick = 887272
+)
+
+var (
+	// MinSqrtPrice 对应 TickMath.MIN_SQRT_PRICE，即 getSqrtPriceAtTick(MinTick)
+	MinSqrtPrice = big.NewInt(4295128739)
+	// MaxSqrtPrice 对应 TickMath.MAX_SQRT_PRICE，即 getSqrtPriceAtTick(MaxTick)
+	MaxSqrtPrice, _ = new(big.Int).SetString("1461446703485210103287273052203988822378723970342", 10)
+)
+
+var (
+	ErrInvalidTick      = errors.New("swapmath: tick 超出 [MIN_TICK, MAX_TICK] 范围")
+	ErrInvalidSqrtPrice = errors.New("swapmath: sqrtPriceX96 超出 [MIN_SQRT_PRICE, MAX_SQRT_PRICE) 范围")
+)
+
+// tickRatios 是 getSqrtPriceAtTick 中每一位对应的 Q128.128 常量：1/sqrt(1.0001^(2^i))
+var tickRatios = [...]struct {
+	bit   int64
+	ratio *big.Int
+}{
+	{0x2, hexBig("fff97272373d413259a46990580e213a")},
+	{0x4, hexBig("fff2e50f5f656932ef12357cf3c7fdcc")},
+	{0x8, hexBig("ffe5caca7e10e4e61c3624eaa0941cd0")},
+	{0x10, hexBig("ffcb9843d60f6159c9db58835c926644")},
+	{0x20, hexBig("ff973b41fa98c081472e6896dfb254c0")},
+	{0x40, hexBig("ff2ea16466c96a3843ec78b326b52861")},
+	{0x80, hexBig("fe5dee046a99a2a811c461f1969c3053")},
+	{0x100, hexBig("fcbe86c7900a88aedcffc83b479aa3a4")},
+	{0x200, hexBig("f987a7253ac413176f2b074cf7815e54")},
+	{0x400, hexBig("f3392b0822b70005940c7a398e4b70f3")},
+	{0x800, hexBig("e7159475a2c29b7443b29c7fa6e889d9")},
+	{0x1000, hexBig("d097f3bdfd2022b8845ad8f792aa5825")},
+	{0x2000, hexBig("a9f746462d870fdf8a65dc1f90e061e5")},
+	{0x4000, hexBig("70d869a156d2a1b890bb3df62baf32f7")},
+	{0x8000, hexBig("31be135f97d08fd981231505542fcfa6")},
+	{0x10000, hexBig("9aa508b5b7a84e1c677de54f3e99bc9")},
+	{0x20000, hexBig("5d6af8dedb81196699c329225ee604")},
+	{0x40000, hexBig("2216e584f5fa1ea926041bedfe98")},
+	{0x80000, hexBig("48a170391f7dc42444e8fa2")},
+}
+
+var (
+	tickRatioBit0 = hexBig("fffcb933bd6fad37aa2d162d1a594001")
+	// log_sqrt10001 的系数及误差修正常量，见 TickMath.getTickAtSqrtPrice
+	logSqrt10001Multiplier, _ = new(big.Int).SetString("255738958999603826347141", 10)
+	tickLowOffset, _          = new(big.Int).SetString("3402992956809132418596140100660247210", 10)
+	tickHiOffset, _           = new(big.Int).SetString("291339464771989622907027621153398088495", 10)
+)
+
+func hexBig(s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("swapmath: invalid hex constant " + s)
+	}
+	return v
+}
+
+// GetSqrtPriceAtTick 对应 TickMath.getSqrtPriceAtTick：计算 sqrt(1.0001^tick) * 2^96
+// 结果向上取整，保证 GetTickAtSqrtPrice(GetSqrtPriceAtTick(tick)) == tick
+func GetSqrtPriceAtTick(tick int64) (*big.Int, error) {
+	absTick := tick
+	if absTick < 0 {
+		absTick = -absTick
+	}
+	if absTick > MaxTick {
+		return nil, ErrInvalidTick
+	}
+
+	price := new(big.Int).Set(Q128)
+	if absTick&0x1 != 0 {
+		price.Set(tickRatioBit0)
+	}
+	for _, r := range tickRatios {
+		if absTick&r.bit != 0 {
+			price.Mul(price, r.ratio)
+			price.Rsh(price, 128)
+		}
+	}
+
+	// if (tick > 0) price = type(uint256).max / price
+	if tick > 0 {
+		price.Quo(maxUint256, price)
+	}
+
+	// Q128.128 -> Q128.96，向上取整
+	price.Add(price, big.NewInt(1<<32-1))
+	price.Rsh(price, 32)
+
+	return price, nil
+}
+
+// GetTickAtSqrtPrice 对应 TickMath.getTickAtSqrtPrice：返回满足 GetSqrtPriceAtTick(tick) <= sqrtPriceX96 的最大 tick
+func GetTickAtSqrtPrice(sqrtPriceX96 *big.Int) (int64, error) {
+	if sqrtPriceX96.Cmp(MinSqrtPrice) < 0 || sqrtPriceX96.Cmp(MaxSqrtPrice) >= 0 {
+		return 0, ErrInvalidSqrtPrice
+	}
+
+	price := new(big.Int).Lsh(sqrtPriceX96, 32)
+
+	msb := int64(price.BitLen() - 1)
+	r := new(big.Int)
+	if msb >= 128 {
+		r.Rsh(price, uint(msb-127))
+	} else {
+		r.Lsh(price, uint(127-msb))
+	}
+
+	// log_2 = (msb - 128) << 64，Q64.64 定点数
+	log2 := big.NewInt(msb - 128)
+	log2.Lsh(log2, 64)
+
+	// 逐位计算 log_2 的小数部分（共14位，对应合约中的14段 assembly）
+	for bit := uint(63); bit >= 50; bit-- {
+		r.Mul(r, r)
+		r.Rsh(r, 127)
+		f := r.Bit(128)
+		if f == 1 {
+			// log_2 的低64位为0，or 与 add 等价
+			log2.Add(log2, new(big.Int).Lsh(big.NewInt(1), bit))
+			r.Rsh(r, 1)
+		}
+	}
+
+	logSqrt10001 := new(big.Int).Mul(log2, logSqrt10001Multiplier)
+
+	// big.Int 的 Rsh 对负数是算术右移（向负无穷取整），与 Solidity 的 int256 >> 一致
+	tickLow := new(big.Int).Sub(logSqrt10001, tickLowOffset)
+	tickLow.Rsh(tickLow, 128)
+	tickHi := new(big.Int).Add(logSqrt10001, tickHiOffset)
+	tickHi.Rsh(tickHi, 128)
+
+	if tickLow.Cmp(tickHi) == 0 {
+		return tickLow.Int64(), nil
+	}
+
+	sqrtPriceAtTickHi, err := GetSqrtPriceAtTick(tickHi.Int64())
+	if err != nil {
+		return 0, err
+	}
+	if sqrtPriceAtTickHi.Cmp(sqrtPriceX96) <= 0 {
+		return tickHi.Int64(), nil
+	}
+	return tickLow.Int64(), nil
+}
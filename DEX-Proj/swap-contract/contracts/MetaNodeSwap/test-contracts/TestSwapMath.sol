@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+import "../libraries/TickMath.sol";
+import "../libraries/SqrtPriceMath.sol";
+import "../libraries/SwapMath.sol";
+
+// 把 TickMath / SqrtPriceMath / SwapMath 的 internal 函数暴露出来，
+// 用于生成后端 Go 实现（backend/pkg/swapmath）对比用的 golden vectors（scripts/swapmath_vectors.ts）
+contract TestSwapMath {
+    function getSqrtPriceAtTick(int24 tick) external pure returns (uint160) {
+        return TickMath.getSqrtPriceAtTick(tick);
+    }
+
+    function getTickAtSqrtPrice(
+        uint160 sqrtPriceX96
+    ) external pure returns (int24) {
+        return TickMath.getTickAtSqrtPrice(sqrtPriceX96);
+    }
+
+    function getAmount0Delta(
+        uint160 sqrtRatioAX96,
+        uint160 sqrtRatioBX96,
+        uint128 liquidity,
+        bool roundUp
+    ) external pure returns (uint256) {
+        return
+            SqrtPriceMath.getAmount0Delta(
+                sqrtRatioAX96,
+                sqrtRatioBX96,
+                liquidity,
+                roundUp
+            );
+    }
+
+    function getAmount1Delta(
+        uint160 sqrtRatioAX96,
+        uint160 sqrtRatioBX96,
+        uint128 liquidity,
+        bool roundUp
+    ) external pure returns (uint256) {
+        return
+            SqrtPriceMath.getAmount1Delta(
+                sqrtRatioAX96,
+                sqrtRatioBX96,
+                liquidity,
+                roundUp
+            );
+    }
+
+    function getNextSqrtPriceFromInput(
+        uint160 sqrtPX96,
+        uint128 liquidity,
+        uint256 amountIn,
+        bool zeroForOne
+    ) external pure returns (uint160) {
+        return
+            SqrtPriceMath.getNextSqrtPriceFromInput(
+                sqrtPX96,
+                liquidity,
+                amountIn,
+                zeroForOne
+            );
+    }
+
+    function getNextSqrtPriceFromOutput(
+        uint160 sqrtPX96,
+        uint128 liquidity,
+        uint256 amountOut,
+        bool zeroForOne
+    ) external pure returns (uint160) {
+        return
+            SqrtPriceMath.getNextSqrtPriceFromOutput(
+                sqrtPX96,
+                liquidity,
+                amountOut,
+                zeroForOne
+            );
+    }
+
+    function computeSwapStep(
+        uint160 sqrtRatioCurrentX96,
+        uint160 sqrtRatioTargetX96,
+        uint128 liquidity,
+        int256 amountRemaining,
+        uint24 feePips
+    )
+        external
+        pure
+        returns (
+            uint160 sqrtRatioNextX96,
+            uint256 amountIn,
+            uint256 amountOut,
+            uint256 feeAmount
+        )
+    {
+        return
+            SwapMath.computeSwapStep(
+                sqrtRatioCurrentX96,
+                sqrtRatioTargetX96,
+                liquidity,
+                amountRemaining,
+                feePips
+            );
+    }
+}
@@ -0,0 +1,240 @@
+import hre from "hardhat";
+import * as fs from "fs";
+import * as path from "path";
+
+// 用 TestSwapMath 合约生成 TickMath / SqrtPriceMath / SwapMath 的 golden vectors，
+// 期望值全部来自 Solidity 库函数，后端 backend/pkg/swapmath 的 go test 读取同一个文件逐条比对：
+//   npx hardhat run scripts/swapmath_vectors.ts
+// 输入由固定种子的伪随机数生成，覆盖边界值和随机值；合约 revert 的输入不写入文件。
+// 可以用环境变量 SWAPMATH_SEED / SWAPMATH_COUNT 修改种子和每类函数的随机向量数量
+
+const MIN_TICK = -887272;
+const MAX_TICK = 887272;
+const MIN_SQRT_PRICE = 4295128739n;
+const MAX_SQRT_PRICE = 1461446703485210103287273052203988822378723970342n;
+const Q96 = 1n << 96n;
+const FEE_TIERS = [0, 100, 500, 3000, 10000];
+
+const OUT = path.join(
+  __dirname,
+  "..",
+  "test",
+  "MetaNodeSwap",
+  "fixtures",
+  "swapmath_vectors.json"
+);
+
+// mulberry32：结果只取决于种子，重新生成时输入不变
+function prng(seed: number) {
+  let a = seed >>> 0;
+  return function next(): number {
+    a = (a + 0x6d2b79f5) >>> 0;
+    let t = a;
+    t = Math.imul(t ^ (t >>> 15), t | 1);
+    t ^= t + Math.imul(t ^ (t >>> 7), t | 61);
+    return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+  };
+}
+
+async function main() {
+  const seed = Number(process.env.SWAPMATH_SEED ?? 20240101);
+  const count = Number(process.env.SWAPMATH_COUNT ?? 200);
+  const rnd = prng(seed);
+  const swapMath = await hre.viem.deployContract("TestSwapMath");
+
+  // [0, n) 内的随机整数
+  const randInt = (n: number) => Math.floor(rnd() * n);
+
+  // [0, limit) 内的随机数（limit > 0）
+  const randBelow = (limit: bigint) => {
+    let v = 0n;
+    for (let bits = 0; bits < limit.toString(2).length + 32; bits += 32) {
+      v = (v << 32n) | BigInt(randInt(2 ** 32));
+    }
+    return v % limit;
+  };
+
+  // [1, 2^bits) 内的随机数，位数也随机，覆盖小数值和大数值
+  const randUint = (maxBits: number) => {
+    const bits = randInt(maxBits) + 1;
+    const v = randBelow(1n << BigInt(bits));
+    return v === 0n ? 1n : v;
+  };
+
+  const randTick = () => randInt(2 * MAX_TICK + 1) + MIN_TICK;
+
+  const randFee = () =>
+    randInt(4) === 0 ? randInt(1000000) : FEE_TIERS[randInt(FEE_TIERS.length)];
+
+  // revert 时返回 undefined，调用方跳过这个输入
+  const tryRead = async <T>(read: () => Promise<T>) => {
+    try {
+      return await read();
+    } catch {
+      return undefined;
+    }
+  };
+
+  const sqrtPriceAtTick = (tick: number) =>
+    swapMath.read.getSqrtPriceAtTick([tick]);
+
+  // 随机 tick 的价格加上一个不超过下一个 tick 的随机偏移
+  const randSqrtPrice = async () => {
+    let tick = randTick();
+    if (tick === MAX_TICK) tick--;
+    const lower = await sqrtPriceAtTick(tick);
+    const upper = await sqrtPriceAtTick(tick + 1);
+    return lower + randBelow(upper - lower);
+  };
+
+  // 与给定价格相差不超过 maxTickDelta 个 tick 的价格，模拟池子价格区间边界
+  const randNearbySqrtPrice = async (price: bigint, maxTickDelta: number) => {
+    const tick = await swapMath.read.getTickAtSqrtPrice([price]);
+    let target = tick + randInt(2 * maxTickDelta + 1) - maxTickDelta;
+    target = Math.min(Math.max(target, MIN_TICK), MAX_TICK);
+    return sqrtPriceAtTick(target);
+  };
+
+  const vectors: Record<string, unknown> = { seed };
+
+  // TickMath.getSqrtPriceAtTick：边界值 + 每一位单独置位 + 随机值
+  const ticks = [0, 1, -1, MIN_TICK, MIN_TICK + 1, MAX_TICK, MAX_TICK - 1];
+  for (let bit = 1; bit <= MAX_TICK; bit <<= 1) ticks.push(bit, -bit);
+  for (let i = 0; i < count; i++) ticks.push(randTick());
+  const getSqrtPriceAtTick = [];
+  for (const tick of ticks) {
+    const expected = await tryRead(() => sqrtPriceAtTick(tick));
+    if (expected === undefined) continue;
+    getSqrtPriceAtTick.push({ tick, expected: expected.toString() });
+  }
+  vectors.getSqrtPriceAtTick = getSqrtPriceAtTick;
+
+  // TickMath.getTickAtSqrtPrice：边界值、tick 价格及其 ±1、随机价格
+  const prices = [MIN_SQRT_PRICE, MAX_SQRT_PRICE - 1n, Q96];
+  for (let i = 0; i < count / 4; i++) {
+    const price = await sqrtPriceAtTick(randTick());
+    prices.push(price, price - 1n, price + 1n);
+  }
+  for (let i = 0; i < count; i++) prices.push(await randSqrtPrice());
+  const getTickAtSqrtPrice = [];
+  for (const price of prices) {
+    const expected = await tryRead(() =>
+      swapMath.read.getTickAtSqrtPrice([price])
+    );
+    if (expected === undefined) continue;
+    getTickAtSqrtPrice.push({ sqrtPriceX96: price.toString(), expected });
+  }
+  vectors.getTickAtSqrtPrice = getTickAtSqrtPrice;
+
+  // SqrtPriceMath.getAmount0Delta / getAmount1Delta
+  const getAmount0Delta = [];
+  const getAmount1Delta = [];
+  for (let i = 0; i < count; i++) {
+    const a = await randSqrtPrice();
+    const b = await randNearbySqrtPrice(a, 1 + randInt(200000));
+    const liquidity = randUint(128);
+    const roundUp = randInt(2) === 0;
+    const input = {
+      sqrtRatioAX96: a.toString(),
+      sqrtRatioBX96: b.toString(),
+      liquidity: liquidity.toString(),
+      roundUp,
+    };
+
+    const amount0 = await tryRead(() =>
+      swapMath.read.getAmount0Delta([a, b, liquidity, roundUp])
+    );
+    if (amount0 !== undefined) {
+      getAmount0Delta.push({ ...input, expected: amount0.toString() });
+    }
+    const amount1 = await tryRead(() =>
+      swapMath.read.getAmount1Delta([a, b, liquidity, roundUp])
+    );
+    if (amount1 !== undefined) {
+      getAmount1Delta.push({ ...input, expected: amount1.toString() });
+    }
+  }
+  vectors.getAmount0Delta = getAmount0Delta;
+  vectors.getAmount1Delta = getAmount1Delta;
+
+  // SqrtPriceMath.getNextSqrtPriceFromInput / getNextSqrtPriceFromOutput
+  const getNextSqrtPriceFromInput = [];
+  const getNextSqrtPriceFromOutput = [];
+  for (let i = 0; i < count; i++) {
+    const price = await randSqrtPrice();
+    const liquidity = randUint(128);
+    const amount = randUint(200);
+    const zeroForOne = randInt(2) === 0;
+    const input = {
+      sqrtPX96: price.toString(),
+      liquidity: liquidity.toString(),
+      amount: amount.toString(),
+      zeroForOne,
+    };
+
+    const fromInput = await tryRead(() =>
+      swapMath.read.getNextSqrtPriceFromInput([
+        price,
+        liquidity,
+        amount,
+        zeroForOne,
+      ])
+    );
+    if (fromInput !== undefined) {
+      getNextSqrtPriceFromInput.push({ ...input, expected: fromInput.toString() });
+    }
+    const fromOutput = await tryRead(() =>
+      swapMath.read.getNextSqrtPriceFromOutput([
+        price,
+        liquidity,
+        amount,
+        zeroForOne,
+      ])
+    );
+    if (fromOutput !== undefined) {
+      getNextSqrtPriceFromOutput.push({
+        ...input,
+        expected: fromOutput.toString(),
+      });
+    }
+  }
+  vectors.getNextSqrtPriceFromInput = getNextSqrtPriceFromInput;
+  vectors.getNextSqrtPriceFromOutput = getNextSqrtPriceFromOutput;
+
+  // SwapMath.computeSwapStep：精确输入/精确输出、两个方向、各种手续费
+  const computeSwapStep = [];
+  for (let i = 0; i < count * 2; i++) {
+    const current = await randSqrtPrice();
+    const target = await randNearbySqrtPrice(current, 1 + randInt(100000));
+    const liquidity = randUint(128);
+    let amount = randUint(200);
+    if (randInt(2) === 0) amount = -amount;
+    const fee = randFee();
+
+    const step = await tryRead(() =>
+      swapMath.read.computeSwapStep([current, target, liquidity, amount, fee])
+    );
+    if (step === undefined) continue;
+    const [sqrtRatioNextX96, amountIn, amountOut, feeAmount] = step;
+    computeSwapStep.push({
+      sqrtRatioCurrentX96: current.toString(),
+      sqrtRatioTargetX96: target.toString(),
+      liquidity: liquidity.toString(),
+      amountRemaining: amount.toString(),
+      feePips: fee,
+      sqrtRatioNextX96: sqrtRatioNextX96.toString(),
+      amountIn: amountIn.toString(),
+      amountOut: amountOut.toString(),
+      feeAmount: feeAmount.toString(),
+    });
+  }
+  vectors.computeSwapStep = computeSwapStep;
+
+  fs.writeFileSync(OUT, JSON.stringify(vectors, null, 2) + "\n");
+  console.log(`✅ Wrote vectors to ${OUT}`);
+}
+
+main().catch((error) => {
+  console.error(error);
+  process.exitCode = 1;
+});
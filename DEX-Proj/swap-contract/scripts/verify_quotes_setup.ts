@@ -0,0 +1,120 @@
+import hre from "hardhat";
+import * as fs from "fs";
+import { encodeSqrtRatioX96, TickMath } from "@uniswap/v3-sdk";
+
+// 为 backend/cmd/verify_quotes 的端到端测试（TestSweepOnHardhat）准备本地链：
+// 部署合约和代币，创建几个价格区间、手续费不同的池子，通过 PositionManager 添加流动性并做几笔兑换，
+// 最后把合约地址写入 VERIFY_QUOTES_DEPLOYMENT 指定的文件（未指定时只打印）：
+//   npx hardhat node
+//   npx hardhat run scripts/verify_quotes_setup.ts --network localhost
+
+const DEADLINE = BigInt(Math.floor(Date.now() / 1000) + 3600);
+const INIT_BALANCE = 10n ** 12n * 10n ** 18n;
+
+// price 为 token1/token0 的价格，按 [numerator, denominator] 给出
+const tickAt = (price: [number, number]) =>
+  TickMath.getTickAtSqrtRatio(encodeSqrtRatioX96(price[0], price[1]));
+const sqrtPriceAt = (price: [number, number]) =>
+  BigInt(encodeSqrtRatioX96(price[0], price[1]).toString());
+
+async function main() {
+  const [walletClient] = await hre.viem.getWalletClients();
+  const [sender] = await walletClient.getAddresses();
+
+  const tokens = [];
+  for (let i = 0; i < 3; i++) {
+    tokens.push(await hre.viem.deployContract("TestToken"));
+  }
+  tokens.sort((a, b) => (a.address.toLowerCase() < b.address.toLowerCase() ? -1 : 1));
+  const [tokenA, tokenB, tokenC] = tokens;
+
+  const poolManager = await hre.viem.deployContract("PoolManager");
+  const positionManager = await hre.viem.deployContract("PositionManager", [poolManager.address]);
+  const swapRouter = await hre.viem.deployContract("SwapRouter", [poolManager.address]);
+
+  for (const token of tokens) {
+    await token.write.mint([sender, INIT_BALANCE]);
+    await token.write.approve([positionManager.address, INIT_BALANCE]);
+    await token.write.approve([swapRouter.address, INIT_BALANCE]);
+  }
+
+  // 同一交易对的池子按创建顺序得到 index 0、1、2
+  const pools = [
+    { token0: tokenA, token1: tokenB, fee: 3000, range: [[1, 1], [40000, 1]], price: [10000, 1], amount: 50000n },
+    { token0: tokenA, token1: tokenB, fee: 10000, range: [[5000, 1], [20000, 1]], price: [10000, 1], amount: 20000n },
+    { token0: tokenA, token1: tokenB, fee: 500, range: [[9000, 1], [11000, 1]], price: [9500, 1], amount: 5000n },
+    { token0: tokenB, token1: tokenC, fee: 3000, range: [[1, 2], [2, 1]], price: [1, 1], amount: 1000000n },
+  ] as const;
+
+  const indexes = new Map<string, number>();
+  for (const pool of pools) {
+    const pair = `${pool.token0.address}-${pool.token1.address}`;
+    const index = indexes.get(pair) ?? 0;
+    indexes.set(pair, index + 1);
+
+    await poolManager.write.createAndInitializePoolIfNecessary([
+      {
+        token0: pool.token0.address,
+        token1: pool.token1.address,
+        fee: pool.fee,
+        tickLower: tickAt(pool.range[0] as [number, number]),
+        tickUpper: tickAt(pool.range[1] as [number, number]),
+        sqrtPriceX96: sqrtPriceAt(pool.price as [number, number]),
+      },
+    ]);
+    // 分两笔添加流动性，sync 服务会为每笔 Mint 写入一个 position
+    for (let i = 0; i < 2; i++) {
+      await positionManager.write.mint([
+        {
+          token0: pool.token0.address,
+          token1: pool.token1.address,
+          index,
+          amount0Desired: pool.amount * 10n ** 18n,
+          amount1Desired: pool.amount * 10n ** 18n * BigInt(pool.price[0]),
+          recipient: sender,
+          deadline: DEADLINE,
+        },
+      ]);
+    }
+  }
+
+  // 两个方向各做几笔兑换，让池子价格离开初始值
+  const swaps = [
+    { tokenIn: tokenA, tokenOut: tokenB, indexPath: [0, 1, 2], amountIn: 3n * 10n ** 18n },
+    { tokenIn: tokenB, tokenOut: tokenA, indexPath: [2, 0], amountIn: 20000n * 10n ** 18n },
+    { tokenIn: tokenA, tokenOut: tokenB, indexPath: [1], amountIn: 10n ** 18n },
+    { tokenIn: tokenC, tokenOut: tokenB, indexPath: [0], amountIn: 1000n * 10n ** 18n },
+  ];
+  for (const swap of swaps) {
+    const zeroForOne = swap.tokenIn.address.toLowerCase() < swap.tokenOut.address.toLowerCase();
+    await swapRouter.write.exactInput([
+      {
+        tokenIn: swap.tokenIn.address,
+        tokenOut: swap.tokenOut.address,
+        indexPath: swap.indexPath,
+        recipient: sender,
+        deadline: DEADLINE,
+        amountIn: swap.amountIn,
+        amountOutMinimum: 0n,
+        sqrtPriceLimitX96: zeroForOne ? 4295128740n : 1461446703485210103287273052203988822378723970341n,
+      },
+    ]);
+  }
+
+  const deployment = {
+    poolManager: poolManager.address,
+    positionManager: positionManager.address,
+    swapRouter: swapRouter.address,
+    tokens: tokens.map((token) => token.address),
+  };
+  const out = process.env.VERIFY_QUOTES_DEPLOYMENT;
+  if (out) {
+    fs.writeFileSync(out, JSON.stringify(deployment, null, 2) + "\n");
+  }
+  console.log(JSON.stringify(deployment, null, 2));
+}
+
+main().catch((error) => {
+  console.error(error);
+  process.exitCode = 1;
+});
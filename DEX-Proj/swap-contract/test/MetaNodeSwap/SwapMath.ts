@@ -0,0 +1,113 @@
+import { loadFixture } from "@nomicfoundation/hardhat-toolbox-viem/network-helpers";
+import { expect } from "chai";
+import hre from "hardhat";
+import * as fs from "fs";
+import * as path from "path";
+
+// golden vectors 由 scripts/swapmath_vectors.ts 调用 TestSwapMath 合约生成：
+//   npx hardhat run scripts/swapmath_vectors.ts
+// 后端 backend/pkg/swapmath 的 go test 用同一个文件校验 Go 实现；这里逐条重新调用合约库函数，
+// 库函数修改后结果与文件不一致时测试失败，需要重新生成 vectors 并运行 go test
+const vectors = JSON.parse(
+  fs.readFileSync(
+    path.join(__dirname, "fixtures", "swapmath_vectors.json"),
+    "utf8"
+  )
+);
+
+describe("SwapMath golden vectors", function () {
+  async function deployFixture() {
+    const swapMath = await hre.viem.deployContract("TestSwapMath");
+    return { swapMath };
+  }
+
+  it("TickMath.getSqrtPriceAtTick", async function () {
+    const { swapMath } = await loadFixture(deployFixture);
+    for (const v of vectors.getSqrtPriceAtTick) {
+      const result = await swapMath.read.getSqrtPriceAtTick([v.tick]);
+      expect(result, `tick=${v.tick}`).to.equal(BigInt(v.expected));
+    }
+  });
+
+  it("TickMath.getTickAtSqrtPrice", async function () {
+    const { swapMath } = await loadFixture(deployFixture);
+    for (const v of vectors.getTickAtSqrtPrice) {
+      const result = await swapMath.read.getTickAtSqrtPrice([
+        BigInt(v.sqrtPriceX96),
+      ]);
+      expect(result, `sqrtPriceX96=${v.sqrtPriceX96}`).to.equal(v.expected);
+    }
+  });
+
+  it("SqrtPriceMath.getAmount0Delta", async function () {
+    const { swapMath } = await loadFixture(deployFixture);
+    for (const v of vectors.getAmount0Delta) {
+      const result = await swapMath.read.getAmount0Delta([
+        BigInt(v.sqrtRatioAX96),
+        BigInt(v.sqrtRatioBX96),
+        BigInt(v.liquidity),
+        v.roundUp,
+      ]);
+      expect(result, JSON.stringify(v)).to.equal(BigInt(v.expected));
+    }
+  });
+
+  it("SqrtPriceMath.getAmount1Delta", async function () {
+    const { swapMath } = await loadFixture(deployFixture);
+    for (const v of vectors.getAmount1Delta) {
+      const result = await swapMath.read.getAmount1Delta([
+        BigInt(v.sqrtRatioAX96),
+        BigInt(v.sqrtRatioBX96),
+        BigInt(v.liquidity),
+        v.roundUp,
+      ]);
+      expect(result, JSON.stringify(v)).to.equal(BigInt(v.expected));
+    }
+  });
+
+  it("SqrtPriceMath.getNextSqrtPriceFromInput", async function () {
+    const { swapMath } = await loadFixture(deployFixture);
+    for (const v of vectors.getNextSqrtPriceFromInput) {
+      const result = await swapMath.read.getNextSqrtPriceFromInput([
+        BigInt(v.sqrtPX96),
+        BigInt(v.liquidity),
+        BigInt(v.amount),
+        v.zeroForOne,
+      ]);
+      expect(result, JSON.stringify(v)).to.equal(BigInt(v.expected));
+    }
+  });
+
+  it("SqrtPriceMath.getNextSqrtPriceFromOutput", async function () {
+    const { swapMath } = await loadFixture(deployFixture);
+    for (const v of vectors.getNextSqrtPriceFromOutput) {
+      const result = await swapMath.read.getNextSqrtPriceFromOutput([
+        BigInt(v.sqrtPX96),
+        BigInt(v.liquidity),
+        BigInt(v.amount),
+        v.zeroForOne,
+      ]);
+      expect(result, JSON.stringify(v)).to.equal(BigInt(v.expected));
+    }
+  });
+
+  it("SwapMath.computeSwapStep", async function () {
+    const { swapMath } = await loadFixture(deployFixture);
+    for (const v of vectors.computeSwapStep) {
+      const [sqrtRatioNextX96, amountIn, amountOut, feeAmount] =
+        await swapMath.read.computeSwapStep([
+          BigInt(v.sqrtRatioCurrentX96),
+          BigInt(v.sqrtRatioTargetX96),
+          BigInt(v.liquidity),
+          BigInt(v.amountRemaining),
+          v.feePips,
+        ]);
+      expect(sqrtRatioNextX96, JSON.stringify(v)).to.equal(
+        BigInt(v.sqrtRatioNextX96)
+      );
+      expect(amountIn, JSON.stringify(v)).to.equal(BigInt(v.amountIn));
+      expect(amountOut, JSON.stringify(v)).to.equal(BigInt(v.amountOut));
+      expect(feeAmount, JSON.stringify(v)).to.equal(BigInt(v.feeAmount));
+    }
+  });
+});
@@ -0,0 +1,29 @@
+-- Migration: Add block tracking for chain reorg detection
+-- Date: 2026-10-16
+-- Description: 添加 blocks 表记录已处理区块的哈希，并为 pools、positions 添加区块号字段，
+-- 用于发现 reorg 后回滚孤块写入的数据。迁移前写入的行这些字段为空，回滚时按"孤块之前创建"处理
+
+CREATE TABLE IF NOT EXISTS blocks (
+    network TEXT NOT NULL,
+    number NUMERIC NOT NULL,
+    hash TEXT NOT NULL,
+    parent_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (network, number)
+);
+
+ALTER TABLE pools
+ADD COLUMN IF NOT EXISTS created_block NUMERIC;
+
+ALTER TABLE positions
+ADD COLUMN IF NOT EXISTS created_block NUMERIC,
+ADD COLUMN IF NOT EXISTS updated_block NUMERIC;
+
+CREATE INDEX IF NOT EXISTS idx_swaps_block_number ON swaps(block_number);
+CREATE INDEX IF NOT EXISTS idx_liquidity_events_block_number ON liquidity_events(block_number);
+
+-- 添加注释
+COMMENT ON TABLE blocks IS '区块哈希表：记录最近已处理区块的哈希和父哈希，扫描器每轮检查哈希是否变化以发现 reorg';
+COMMENT ON COLUMN pools.created_block IS 'PoolCreated 事件所在区块号，reorg 回滚时删除该区块之后创建的池子；从链上补建的池子为空';
+COMMENT ON COLUMN positions.created_block IS '创建该持仓的事件所在区块号，reorg 回滚时删除该区块之后创建的持仓';
+COMMENT ON COLUMN positions.updated_block IS '最后一次更新该持仓的事件所在区块号，reorg 回滚时据此从链上恢复持仓状态';
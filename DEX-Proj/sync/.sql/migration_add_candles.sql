@@ -0,0 +1,28 @@
+-- Migration: Add candles table
+-- Date: 2026-10-16
+-- Description: 按池子聚合 1m/5m/1h/1d 的 OHLCV K 线，扫描器处理 Swap 时增量更新。
+-- 已有的 swaps 不会自动回填，需要从头重新扫描或在迁移后手动重放
+
+CREATE TABLE IF NOT EXISTS candles (
+    pool_address TEXT REFERENCES pools(address),
+    period TEXT NOT NULL,               -- 周期：1m、5m、1h、1d
+    bucket_start TIMESTAMPTZ NOT NULL,  -- K 线开始时间（UTC 对齐）
+    open NUMERIC NOT NULL,
+    high NUMERIC NOT NULL,
+    low NUMERIC NOT NULL,
+    close NUMERIC NOT NULL,
+    volume0 NUMERIC NOT NULL DEFAULT 0,
+    volume1 NUMERIC NOT NULL DEFAULT 0,
+    swap_count INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (pool_address, period, bucket_start)
+);
+
+COMMENT ON TABLE candles IS 'K 线表：按池子和周期聚合 swaps 的开高低收价格和成交量，reorg 回滚时从最早的孤块 swap 所在 K 线开始重建';
+COMMENT ON COLUMN candles.period IS 'K 线周期：1m、5m、1h、1d';
+COMMENT ON COLUMN candles.bucket_start IS 'K 线开始时间，按周期长度对齐到 UTC';
+COMMENT ON COLUMN candles.open IS '开盘价：1 token0 可兑换的 token1 数量（已按两个代币的精度调整）';
+COMMENT ON COLUMN candles.close IS '收盘价：该周期内最后一笔 swap 之后的价格';
+COMMENT ON COLUMN candles.volume0 IS 'token0 成交量：swap amount0 的绝对值之和（最小单位）';
+COMMENT ON COLUMN candles.volume1 IS 'token1 成交量：swap amount1 的绝对值之和（最小单位）';
+COMMENT ON COLUMN candles.swap_count IS '该周期内的 swap 笔数';
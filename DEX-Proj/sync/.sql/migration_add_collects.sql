@@ -0,0 +1,30 @@
+-- Migration: Add collects table and liquidity_events.position_id
+-- Date: 2026-10-16
+-- Description: 索引 Pool 的 Collect 事件，并记录 BURN 事件对应的 position，
+-- 用于计算每个 position 已领取的手续费（领取总额 - Burn 退出的本金）和尚未领取的手续费
+
+CREATE TABLE IF NOT EXISTS collects (
+    transaction_hash TEXT NOT NULL,
+    log_index INT NOT NULL,
+    pool_address TEXT REFERENCES pools(address),
+    owner TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    position_id NUMERIC,
+    amount0 NUMERIC NOT NULL,
+    amount1 NUMERIC NOT NULL,
+    block_number NUMERIC NOT NULL,
+    block_timestamp TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (transaction_hash, log_index)
+);
+
+ALTER TABLE liquidity_events
+ADD COLUMN IF NOT EXISTS position_id NUMERIC;
+
+CREATE INDEX IF NOT EXISTS idx_collects_position ON collects(position_id);
+CREATE INDEX IF NOT EXISTS idx_liquidity_events_position ON liquidity_events(position_id);
+
+-- 添加注释
+COMMENT ON TABLE collects IS '领取记录表：记录 LP 通过 collect 领取 tokensOwed 的历史，tokensOwed 包含手续费和 Burn 退出的本金';
+COMMENT ON COLUMN collects.position_id IS '对应的 position ID（NFT 从 PositionManager.collect 的 calldata 解析，虚拟 position 按 owner+pool+tick 计算），无法确定时为空';
+COMMENT ON COLUMN liquidity_events.position_id IS 'BURN 事件对应的 position ID（NFT 从 PositionManager.burn 的 calldata 解析，虚拟 position 按 owner+pool+tick 计算），无法确定时为空';
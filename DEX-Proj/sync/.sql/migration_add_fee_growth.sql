@@ -0,0 +1,16 @@
+-- Migration: Add fee growth columns to pools table
+-- Date: 2026-10-16
+-- Description: 记录池子的 feeGrowthGlobal0X128/feeGrowthGlobal1X128，
+-- 用于按 Pool._modifyPosition 的方式计算 position 尚未结算的手续费
+
+ALTER TABLE pools
+ADD COLUMN IF NOT EXISTS fee_growth_global0_x128 NUMERIC DEFAULT 0;
+
+ALTER TABLE pools
+ADD COLUMN IF NOT EXISTS fee_growth_global1_x128 NUMERIC DEFAULT 0;
+
+-- 添加注释
+COMMENT ON COLUMN pools.fee_growth_global0_x128 IS '每单位流动性累计的token0手续费（Q128格式），每次 Swap 后从 Pool.feeGrowthGlobal0X128 同步';
+COMMENT ON COLUMN pools.fee_growth_global1_x128 IS '每单位流动性累计的token1手续费（Q128格式），每次 Swap 后从 Pool.feeGrowthGlobal1X128 同步';
+COMMENT ON COLUMN ticks.fee_growth_outside0_x128 IS 'tick外部区域token0的手续费增长率（Q128格式），tick 初始化时按 Uniswap V3 约定取值：tick <= 当前tick 时为 fee_growth_global0_x128，否则为0';
+COMMENT ON COLUMN ticks.fee_growth_outside1_x128 IS 'tick外部区域token1的手续费增长率（Q128格式），tick 初始化时按 Uniswap V3 约定取值：tick <= 当前tick 时为 fee_growth_global1_x128，否则为0';
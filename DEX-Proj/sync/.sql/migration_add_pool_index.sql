@@ -0,0 +1,11 @@
+-- Migration: Add pool_index column to pools table
+-- Date: 2026-10-16
+-- Description: 添加 pool_index 字段用于存储池子在交易对下的序号（PoolCreated 事件中的 index）
+-- 已存在的池子可以通过 cmd/update_reserves 从链上 Factory.getPool 反查补全
+
+-- 添加 pool_index 字段
+ALTER TABLE pools 
+ADD COLUMN IF NOT EXISTS pool_index INT;
+
+-- 添加注释
+COMMENT ON COLUMN pools.pool_index IS '池子在该交易对下的序号（PoolCreated 事件中的 index，从0开始），对应 SwapRouter 的 indexPath';
@@ -0,0 +1,13 @@
+-- Migration: Add updated_block to pools table
+-- Date: 2026-10-16
+-- Description: 记录最后一次写入池子状态对应的区块号，backend 池子缓存按该字段增量刷新，
+-- 不再依赖 swaps/liquidity_events 推断哪些池子有变化（从链上刷新的池子状态也能被发现）。
+-- 迁移前写入的行该字段为空，下一次写入该池子时才会设置；backend 启动时全量加载，不受影响
+
+ALTER TABLE pools
+ADD COLUMN IF NOT EXISTS updated_block NUMERIC;
+
+CREATE INDEX IF NOT EXISTS idx_pools_updated_block ON pools(updated_block);
+
+-- 添加注释
+COMMENT ON COLUMN pools.updated_block IS '最后一次写入池子状态（pools/ticks）对应的区块号，backend 池子缓存据此增量刷新';
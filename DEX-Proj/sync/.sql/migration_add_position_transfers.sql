@@ -0,0 +1,24 @@
+-- Migration: Add position_transfers table
+-- Date: 2026-10-16
+-- Description: 记录 PositionManager 的 ERC721 Transfer 事件。扫描器在同一个事务中处理整个区块范围，
+-- 事件行（swaps、liquidity_events、position_transfers）已存在时跳过派生状态的累加，重复扫描不会重复计算
+
+CREATE TABLE IF NOT EXISTS position_transfers (
+    transaction_hash TEXT NOT NULL,
+    log_index INT NOT NULL,
+    token_id NUMERIC NOT NULL,
+    from_address TEXT NOT NULL,
+    to_address TEXT NOT NULL,
+    block_number NUMERIC NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (transaction_hash, log_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_position_transfers_token ON position_transfers(token_id);
+
+-- 添加注释
+COMMENT ON TABLE position_transfers IS 'NFT 转移记录表：记录 PositionManager 的 Transfer 事件（mint/burn/转移），用于保证重复扫描同一区块范围时不会重复更新持仓';
+COMMENT ON COLUMN position_transfers.token_id IS 'Position NFT 的 token ID';
+COMMENT ON COLUMN position_transfers.from_address IS '转出地址，mint 时为零地址';
+COMMENT ON COLUMN position_transfers.to_address IS '转入地址，burn 时为零地址';
+COMMENT ON COLUMN position_transfers.block_number IS '事件所在区块号';
@@ -15,6 +15,7 @@ CREATE TABLE IF NOT EXISTS pools (
     address TEXT PRIMARY KEY,
     token0 TEXT REFERENCES tokens(address),
     token1 TEXT REFERENCES tokens(address),
+    pool_index INT,
     fee INT NOT NULL,
     tick_lower INT NOT NULL,
     tick_upper INT NOT NULL,
@@ -23,6 +24,10 @@ CREATE TABLE IF NOT EXISTS pools (
     tick INT DEFAULT 0,
     reserve0 NUMERIC DEFAULT 0,
     reserve1 NUMERIC DEFAULT 0,
+    fee_growth_global0_x128 NUMERIC DEFAULT 0,
+    fee_growth_global1_x128 NUMERIC DEFAULT 0,
+    created_block NUMERIC,
+    updated_block NUMERIC,
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
@@ -40,6 +45,8 @@ CREATE TABLE IF NOT EXISTS positions (
     fee_growth_inside1_last_x128 NUMERIC DEFAULT 0,
     tokens_owed0 NUMERIC DEFAULT 0,
     tokens_owed1 NUMERIC DEFAULT 0,
+    created_block NUMERIC,
+    updated_block NUMERIC,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW()
 );
@@ -89,14 +96,71 @@ CREATE TABLE IF NOT EXISTS liquidity_events (
     tick_upper INT,
     block_number NUMERIC NOT NULL,
     block_timestamp TIMESTAMPTZ NOT NULL,
+    position_id NUMERIC,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     PRIMARY KEY (transaction_hash, log_index)
 );
 
+-- Collect events (LP 领取手续费和 Burn 退出的本金)
+CREATE TABLE IF NOT EXISTS collects (
+    transaction_hash TEXT NOT NULL,
+    log_index INT NOT NULL,
+    pool_address TEXT REFERENCES pools(address),
+    owner TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    position_id NUMERIC,
+    amount0 NUMERIC NOT NULL,
+    amount1 NUMERIC NOT NULL,
+    block_number NUMERIC NOT NULL,
+    block_timestamp TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (transaction_hash, log_index)
+);
+
+-- PositionManager NFT Transfer events (用于保证重复扫描时不会重复更新 positions)
+CREATE TABLE IF NOT EXISTS position_transfers (
+    transaction_hash TEXT NOT NULL,
+    log_index INT NOT NULL,
+    token_id NUMERIC NOT NULL,
+    from_address TEXT NOT NULL,
+    to_address TEXT NOT NULL,
+    block_number NUMERIC NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (transaction_hash, log_index)
+);
+
+-- Candles table: 由 swaps 聚合的 OHLCV K 线，扫描器处理 Swap 时增量更新
+CREATE TABLE IF NOT EXISTS candles (
+    pool_address TEXT REFERENCES pools(address),
+    period TEXT NOT NULL,               -- 周期：1m、5m、1h、1d
+    bucket_start TIMESTAMPTZ NOT NULL,  -- K 线开始时间（UTC 对齐）
+    open NUMERIC NOT NULL,
+    high NUMERIC NOT NULL,
+    low NUMERIC NOT NULL,
+    close NUMERIC NOT NULL,
+    volume0 NUMERIC NOT NULL DEFAULT 0,
+    volume1 NUMERIC NOT NULL DEFAULT 0,
+    swap_count INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (pool_address, period, bucket_start)
+);
+
+COMMENT ON TABLE candles IS 'K 线表：按池子和周期聚合 swaps 的开高低收价格和成交量，reorg 回滚时从最早的孤块 swap 所在 K 线开始重建';
+COMMENT ON COLUMN candles.period IS 'K 线周期：1m、5m、1h、1d';
+COMMENT ON COLUMN candles.bucket_start IS 'K 线开始时间，按周期长度对齐到 UTC';
+COMMENT ON COLUMN candles.open IS '开盘价：1 token0 可兑换的 token1 数量（已按两个代币的精度调整）';
+COMMENT ON COLUMN candles.close IS '收盘价：该周期内最后一笔 swap 之后的价格';
+COMMENT ON COLUMN candles.volume0 IS 'token0 成交量：swap amount0 的绝对值之和（最小单位）';
+COMMENT ON COLUMN candles.volume1 IS 'token1 成交量：swap amount1 的绝对值之和（最小单位）';
+COMMENT ON COLUMN candles.swap_count IS '该周期内的 swap 笔数';
+
 -- Indexes
 CREATE INDEX IF NOT EXISTS idx_swaps_pool_timestamp ON swaps(pool_address, block_timestamp DESC);
 CREATE INDEX IF NOT EXISTS idx_positions_owner ON positions(owner);
 CREATE INDEX IF NOT EXISTS idx_positions_pool ON positions(pool_address);
+CREATE INDEX IF NOT EXISTS idx_position_transfers_token ON position_transfers(token_id);
+CREATE INDEX IF NOT EXISTS idx_collects_position ON collects(position_id);
+CREATE INDEX IF NOT EXISTS idx_liquidity_events_position ON liquidity_events(position_id);
 
 -- Indexed status table: 记录各网络的扫描高度
 CREATE TABLE IF NOT EXISTS indexed_status (
@@ -110,6 +174,26 @@ COMMENT ON COLUMN indexed_status.network IS '网络标识，例如 mainnet、sep
 COMMENT ON COLUMN indexed_status.last_block IS '该网络已处理的最高区块号';
 COMMENT ON COLUMN indexed_status.updated_at IS '记录更新时间';
 
+-- Blocks table: 记录已处理区块的哈希，用于检测链重组（reorg）
+CREATE TABLE IF NOT EXISTS blocks (
+    network TEXT NOT NULL,              -- 网络标识，与 indexed_status.network 一致
+    number NUMERIC NOT NULL,            -- 区块号
+    hash TEXT NOT NULL,                 -- 区块哈希
+    parent_hash TEXT NOT NULL,          -- 父区块哈希
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (network, number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_swaps_block_number ON swaps(block_number);
+CREATE INDEX IF NOT EXISTS idx_liquidity_events_block_number ON liquidity_events(block_number);
+CREATE INDEX IF NOT EXISTS idx_pools_updated_block ON pools(updated_block);
+
+COMMENT ON TABLE blocks IS '区块哈希表：记录最近已处理区块的哈希和父哈希，扫描器每轮检查哈希是否变化以发现 reorg';
+COMMENT ON COLUMN blocks.network IS '网络标识，与 indexed_status.network 一致';
+COMMENT ON COLUMN blocks.number IS '区块号';
+COMMENT ON COLUMN blocks.hash IS '扫描时该区块的哈希';
+COMMENT ON COLUMN blocks.parent_hash IS '扫描时该区块的父区块哈希';
+
 -- ============================================
 -- Table Comments (业务注释说明)
 -- ============================================
@@ -128,6 +212,7 @@ COMMENT ON TABLE pools IS '流动性池表：存储交易对的流动性池信
 COMMENT ON COLUMN pools.address IS '流动性池合约地址，作为主键';
 COMMENT ON COLUMN pools.token0 IS '交易对中的第一个代币地址（按地址排序）';
 COMMENT ON COLUMN pools.token1 IS '交易对中的第二个代币地址（按地址排序）';
+COMMENT ON COLUMN pools.pool_index IS '池子在该交易对下的序号（PoolCreated 事件中的 index，从0开始），对应 SwapRouter 的 indexPath';
 COMMENT ON COLUMN pools.fee IS '手续费率，以基点为单位（如3000表示0.3%）';
 COMMENT ON COLUMN pools.tick_lower IS '价格区间下限对应的tick值';
 COMMENT ON COLUMN pools.tick_upper IS '价格区间上限对应的tick值';
@@ -136,6 +221,10 @@ COMMENT ON COLUMN pools.sqrt_price_x96 IS '当前价格的平方根，以Q96格
 COMMENT ON COLUMN pools.tick IS '当前价格对应的tick值';
 COMMENT ON COLUMN pools.reserve0 IS '池子中token0的余额（通过调用token0.balanceOf(pool)获取）';
 COMMENT ON COLUMN pools.reserve1 IS '池子中token1的余额（通过调用token1.balanceOf(pool)获取）';
+COMMENT ON COLUMN pools.fee_growth_global0_x128 IS '每单位流动性累计的token0手续费（Q128格式），每次 Swap 后从 Pool.feeGrowthGlobal0X128 同步';
+COMMENT ON COLUMN pools.fee_growth_global1_x128 IS '每单位流动性累计的token1手续费（Q128格式），每次 Swap 后从 Pool.feeGrowthGlobal1X128 同步';
+COMMENT ON COLUMN pools.created_block IS 'PoolCreated 事件所在区块号，reorg 回滚时删除该区块之后创建的池子；从链上补建的池子为空';
+COMMENT ON COLUMN pools.updated_block IS '最后一次写入池子状态（pools/ticks）对应的区块号，backend 池子缓存据此增量刷新';
 
 -- Positions table: 流动性持仓表（NFT）
 -- 存储用户通过PositionManager创建的流动性持仓，每个持仓对应一个NFT token ID
@@ -152,6 +241,8 @@ COMMENT ON COLUMN positions.fee_growth_inside0_last_x128 IS '上次更新时toke
 COMMENT ON COLUMN positions.fee_growth_inside1_last_x128 IS '上次更新时token1的手续费增长率（Q128格式），用于计算应得手续费';
 COMMENT ON COLUMN positions.tokens_owed0 IS '该持仓应得的token0手续费数量';
 COMMENT ON COLUMN positions.tokens_owed1 IS '该持仓应得的token1手续费数量';
+COMMENT ON COLUMN positions.created_block IS '创建该持仓的事件所在区块号，reorg 回滚时删除该区块之后创建的持仓';
+COMMENT ON COLUMN positions.updated_block IS '最后一次更新该持仓的事件所在区块号，reorg 回滚时据此从链上恢复持仓状态';
 
 -- Swaps table: 交换记录表
 -- 记录所有在DEX中发生的代币交换交易，用于交易历史查询和价格分析
@@ -176,8 +267,8 @@ COMMENT ON COLUMN ticks.pool_address IS '所属的流动性池地址，与tick_i
 COMMENT ON COLUMN ticks.tick_index IS '价格刻度索引值，每个tick对应一个价格点';
 COMMENT ON COLUMN ticks.liquidity_gross IS '该tick点的总流动性（包括所有经过此tick的持仓）';
 COMMENT ON COLUMN ticks.liquidity_net IS '该tick点的净流动性变化（向上为正，向下为负）';
-COMMENT ON COLUMN ticks.fee_growth_outside0_x128 IS 'tick外部区域token0的手续费增长率（Q128格式）';
-COMMENT ON COLUMN ticks.fee_growth_outside1_x128 IS 'tick外部区域token1的手续费增长率（Q128格式）';
+COMMENT ON COLUMN ticks.fee_growth_outside0_x128 IS 'tick外部区域token0的手续费增长率（Q128格式），tick 初始化时按 Uniswap V3 约定取值：tick <= 当前tick 时为 fee_growth_global0_x128，否则为0';
+COMMENT ON COLUMN ticks.fee_growth_outside1_x128 IS 'tick外部区域token1的手续费增长率（Q128格式），tick 初始化时按 Uniswap V3 约定取值：tick <= 当前tick 时为 fee_growth_global1_x128，否则为0';
 
 -- Liquidity events table: 流动性事件表
 -- 记录所有添加和移除流动性的历史事件，用于流动性变化分析和审计
@@ -194,3 +285,28 @@ COMMENT ON COLUMN liquidity_events.tick_lower IS '流动性价格区间下限对
 COMMENT ON COLUMN liquidity_events.tick_upper IS '流动性价格区间上限对应的tick值';
 COMMENT ON COLUMN liquidity_events.block_number IS '事件所在区块号';
 COMMENT ON COLUMN liquidity_events.block_timestamp IS '事件所在区块的时间戳';
+COMMENT ON COLUMN liquidity_events.position_id IS 'BURN 事件对应的 position ID（NFT 从 PositionManager.burn 的 calldata 解析，虚拟 position 按 owner+pool+tick 计算），无法确定时为空';
+
+-- Collects table: 领取记录表
+-- 记录 Pool 的 Collect 事件，LP 通过 collect 领取 tokensOwed（手续费 + Burn 退出的本金）
+COMMENT ON TABLE collects IS '领取记录表：记录 LP 通过 collect 领取 tokensOwed 的历史，tokensOwed 包含手续费和 Burn 退出的本金';
+COMMENT ON COLUMN collects.transaction_hash IS '交易哈希值，与log_index一起构成主键';
+COMMENT ON COLUMN collects.log_index IS '日志索引，用于区分同一交易中的多个事件';
+COMMENT ON COLUMN collects.pool_address IS '领取代币的池子地址';
+COMMENT ON COLUMN collects.owner IS '池子中的 position owner（NFT position 为 PositionManager 合约地址）';
+COMMENT ON COLUMN collects.recipient IS '接收代币的地址';
+COMMENT ON COLUMN collects.position_id IS '对应的 position ID（NFT 从 PositionManager.collect 的 calldata 解析，虚拟 position 按 owner+pool+tick 计算），无法确定时为空';
+COMMENT ON COLUMN collects.amount0 IS '实际领取的 token0 数量';
+COMMENT ON COLUMN collects.amount1 IS '实际领取的 token1 数量';
+COMMENT ON COLUMN collects.block_number IS '事件所在区块号';
+COMMENT ON COLUMN collects.block_timestamp IS '事件所在区块的时间戳';
+
+-- Position transfers table: NFT 转移记录表
+-- 记录 PositionManager 的 ERC721 Transfer 事件，扫描器先写入该表，已存在的事件不再更新 positions
+COMMENT ON TABLE position_transfers IS 'NFT 转移记录表：记录 PositionManager 的 Transfer 事件（mint/burn/转移），用于保证重复扫描同一区块范围时不会重复更新持仓';
+COMMENT ON COLUMN position_transfers.transaction_hash IS '交易哈希值，与log_index一起构成主键';
+COMMENT ON COLUMN position_transfers.log_index IS '日志索引，用于区分同一交易中的多个事件';
+COMMENT ON COLUMN position_transfers.token_id IS 'Position NFT 的 token ID';
+COMMENT ON COLUMN position_transfers.from_address IS '转出地址，mint 时为零地址';
+COMMENT ON COLUMN position_transfers.to_address IS '转入地址，burn 时为零地址';
+COMMENT ON COLUMN position_transfers.block_number IS '事件所在区块号';
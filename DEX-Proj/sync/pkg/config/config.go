@@ -9,12 +9,39 @@ type Config struct {
 		Name     string `yaml:"Name"`
 	} `yaml:"Database"`
 	RPC struct {
-		Url        string `yaml:"Url"`
+		Url string `yaml:"Url"`
+		// Urls 多个 RPC 节点，按延迟、错误率和区块高度自动选择和切换；配置后忽略 Url
+		Urls []string `yaml:"Urls"`
+		// WsUrl WebSocket 节点，订阅新区块头和事件日志，新区块到达后立即扫描；为空或节点不支持订阅时按 12 秒间隔轮询
+		WsUrl      string `yaml:"WsUrl"`
 		StartBlock int64  `yaml:"StartBlock"`
+		// Confirmations 确认深度：只扫描到 最新区块 - Confirmations，为 0 时扫描到最新区块
+		Confirmations uint64 `yaml:"Confirmations"`
+		// MaxRetries 所有节点都失败后按指数退避重试的轮数，默认 3
+		MaxRetries int `yaml:"MaxRetries"`
+		// MaxLagBlocks 节点落后最高节点超过该区块数时降低优先级，默认 5
+		MaxLagBlocks uint64 `yaml:"MaxLagBlocks"`
+		// StatusAddr 节点状态 HTTP 接口（GET /rpc/endpoints）的监听地址，例如 :9100，为空时不启动
+		StatusAddr string `yaml:"StatusAddr"`
 	} `yaml:"RPC"`
+	// Backfill 落后链头较多时并发拉取历史日志的参数，未配置的字段使用默认值
+	Backfill struct {
+		// Concurrency 同时请求 FilterLogs 的区块范围数量，默认 4
+		Concurrency int `yaml:"Concurrency"`
+		// InitialBlockRange 单次 FilterLogs 的初始区块数，默认 500
+		InitialBlockRange uint64 `yaml:"InitialBlockRange"`
+		// MinBlockRange / MaxBlockRange 自适应调整区块数的上下限，默认 10 / 5000
+		MinBlockRange uint64 `yaml:"MinBlockRange"`
+		MaxBlockRange uint64 `yaml:"MaxBlockRange"`
+		// TargetLatencyMs 单次 FilterLogs 的目标耗时（毫秒）：快于一半时扩大区块数，超过时缩小，默认 2000
+		TargetLatencyMs int64 `yaml:"TargetLatencyMs"`
+	} `yaml:"Backfill"`
 	Contracts struct {
 		PoolManager     string `yaml:"PoolManager"`
 		PositionManager string `yaml:"PositionManager"`
 		SwapRouter      string `yaml:"SwapRouter"`
+		// Multicall3 批量读取链上状态使用的 Multicall3 地址，默认 0xcA11bde05977b3631167028862bE2a173976CA11；
+		// 链上没有部署时改用 JSON-RPC 批量 eth_call
+		Multicall3 string `yaml:"Multicall3"`
 	} `yaml:"Contracts"`
 }
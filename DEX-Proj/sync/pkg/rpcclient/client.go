@@ -0,0 +1,474 @@
+// Package rpcclient 多个 RPC 节点的以太坊客户端：记录每个节点的延迟、错误率和区块高度，
+// 请求按健康评分选择节点，节点出错时透明地切换到下一个节点，所有节点都失败时按指数退避重试
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Options 客户端参数，零值字段使用默认值
+type Options struct {
+	// MaxRetries 所有节点都失败后按指数退避重试的轮数，默认 3
+	MaxRetries int
+	// MaxLagBlocks 节点区块高度落后最高节点超过该值时视为落后，排在正常节点之后，默认 5
+	MaxLagBlocks uint64
+	// RequestTimeout 单个节点的请求超时（调用方的 deadline 更早时以调用方为准），超时后切换节点，默认 30 秒
+	RequestTimeout time.Duration
+	// HeadPollInterval 轮询各节点区块高度的间隔，默认 12 秒
+	HeadPollInterval time.Duration
+}
+
+const (
+	defaultMaxRetries       = 3
+	defaultMaxLagBlocks     = 5
+	defaultRequestTimeout   = 30 * time.Second
+	defaultHeadPollInterval = 12 * time.Second
+
+	// baseBackoff / maxBackoff 所有节点都失败后重试的等待时间：0.5s、1s、2s ...，最长 30 秒
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+)
+
+// rateLimitMessages 各 RPC 服务商限流时返回的错误信息（小写），限流时切换到其他节点
+var rateLimitMessages = []string{
+	"rate limit",                   // rate limited / rate limit exceeded
+	"too many requests",            // HTTP 429
+	"request rate exceeded",        // Infura: project ID request rate exceeded
+	"compute units per second",     // Alchemy: exceeded its compute units per second capacity
+	"daily request count exceeded", // daily request count exceeded, request rate limited
+}
+
+// unavailableMessages 节点还没有同步到或没有保存请求的数据时返回的错误信息（小写），
+// 其他节点可能有这些数据，切换节点但不计为节点错误
+var unavailableMessages = []string{
+	"header not found",  // Geth: 区块还没有同步到
+	"unknown block",     // unknown block
+	"block not found",   // block not found
+	"missing trie node", // 非归档节点没有历史状态
+}
+
+// stateUnavailableMessages 节点没有历史状态（非归档节点）时返回的错误信息（小写）
+var stateUnavailableMessages = []string{
+	"missing trie node",            // Geth
+	"historical state",             // Geth path scheme: historical state not available
+	"state is not available",       // Nethermind
+	"state histories haven't been", // Geth: state histories haven't been fully indexed yet
+}
+
+// ErrBlockNotReached 所有节点已知的区块高度都低于请求的区块，节点同步到该区块后重试可能成功
+var ErrBlockNotReached = errors.New("no RPC endpoint has reached the requested block")
+
+// EndpointState 一个 RPC 节点的状态，由 Client.Endpoints 返回
+type EndpointState struct {
+	URL                 string     `json:"url"`
+	Status              string     `json:"status"`
+	Score               float64    `json:"score"`
+	LatencyMs           float64    `json:"latencyMs"`
+	ErrorRate           float64    `json:"errorRate"`
+	Requests            uint64     `json:"requests"`
+	Failures            uint64     `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	CooldownUntil       *time.Time `json:"cooldownUntil,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+	Head                uint64     `json:"head"`
+	Lag                 uint64     `json:"lag"`
+}
+
+// Client 多节点以太坊客户端，方法与 ethclient.Client 对应
+type Client struct {
+	endpoints []*endpoint
+	opts      Options
+
+	mu       sync.Mutex
+	bestHead uint64
+}
+
+// Dial 连接所有节点，查询一次各节点的区块高度，并在后台按 HeadPollInterval 持续轮询
+func Dial(urls []string, opts Options) (*Client, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no RPC endpoints configured")
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultMaxRetries
+	}
+	if opts.MaxLagBlocks == 0 {
+		opts.MaxLagBlocks = defaultMaxLagBlocks
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = defaultRequestTimeout
+	}
+	if opts.HeadPollInterval <= 0 {
+		opts.HeadPollInterval = defaultHeadPollInterval
+	}
+
+	c := &Client{opts: opts}
+	for i, u := range urls {
+		e, err := dialEndpoint(i, u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial RPC endpoint #%d %s: %v", i, RedactURL(u), err)
+		}
+		c.endpoints = append(c.endpoints, e)
+	}
+
+	c.pollHeads()
+	for _, st := range c.Endpoints() {
+		log.Printf("RPC endpoint %s: status=%s, head=%d", st.URL, st.Status, st.Head)
+	}
+	go func() {
+		ticker := time.NewTicker(opts.HeadPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.pollHeads()
+		}
+	}()
+	return c, nil
+}
+
+// Endpoints 返回所有节点当前的状态
+func (c *Client) Endpoints() []EndpointState {
+	best := c.best()
+	now := time.Now()
+	states := make([]EndpointState, len(c.endpoints))
+	for i, e := range c.endpoints {
+		states[i] = e.state(best, c.opts.MaxLagBlocks, now)
+	}
+	return states
+}
+
+func (c *Client) best() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bestHead
+}
+
+func (c *Client) observeHead(e *endpoint, head uint64) {
+	e.observeHead(head)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if head > c.bestHead {
+		c.bestHead = head
+	}
+}
+
+// pollHeads 并发查询各节点的区块高度，用于发现落后的节点；节点状态变化时输出日志
+func (c *Client) pollHeads() {
+	var wg sync.WaitGroup
+	for _, e := range c.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), c.opts.RequestTimeout)
+			defer cancel()
+			start := time.Now()
+			head, err := e.eth.BlockNumber(ctx)
+			if err != nil {
+				e.failure(err)
+				return
+			}
+			e.success(time.Since(start))
+			c.observeHead(e, head)
+		}()
+	}
+	wg.Wait()
+
+	best := c.best()
+	now := time.Now()
+	for _, e := range c.endpoints {
+		st := e.state(best, c.opts.MaxLagBlocks, now)
+		e.mu.Lock()
+		changed := st.Status != e.status
+		e.status = st.Status
+		e.mu.Unlock()
+		if changed {
+			log.Printf("⚠️  RPC endpoint %s is now %s (head=%d, lag=%d, lastError=%s)",
+				st.URL, st.Status, st.Head, st.Lag, st.LastError)
+		}
+	}
+}
+
+// candidates 按优先级排列节点：正常 < 落后 < 冷却中 < 区块高度低于 minBlock（无法提供该区块的数据），
+// 同一级别按评分排序；所有节点都会尝试，排在后面的只在前面的都失败时使用。
+// pinned 为 true 时（eth_getLogs、固定区块的批量请求）不使用区块高度低于 minBlock 的节点：
+// 落后节点对还没有同步到的区块返回空日志或空结果而不是错误，无法通过切换节点发现
+func (c *Client) candidates(minBlock uint64, pinned bool) []*endpoint {
+	best := c.best()
+	now := time.Now()
+
+	type candidate struct {
+		e     *endpoint
+		tier  int
+		score float64
+	}
+	list := make([]candidate, 0, len(c.endpoints))
+	for _, e := range c.endpoints {
+		st := e.state(best, c.opts.MaxLagBlocks, now)
+		if pinned && minBlock > 0 && st.Head < minBlock {
+			continue
+		}
+		tier := 0
+		switch {
+		case minBlock > 0 && st.Head < minBlock:
+			tier = 3
+		case st.Status == StatusCooldown:
+			tier = 2
+		case st.Status == StatusLagging:
+			tier = 1
+		}
+		list = append(list, candidate{e, tier, st.Score})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].tier != list[j].tier {
+			return list[i].tier < list[j].tier
+		}
+		return list[i].score < list[j].score
+	})
+
+	endpoints := make([]*endpoint, len(list))
+	for i, cand := range list {
+		endpoints[i] = cand.e
+	}
+	return endpoints
+}
+
+// isRetryable 判断错误是否由节点本身引起（网络错误、超时、HTTP 429/5xx、限流），换一个节点可能成功；
+// 合约 revert、参数错误、结果过多等 JSON-RPC 错误在所有节点上的结果相同，直接返回给调用方
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrBlockNotReached) {
+		return true
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= http.StatusInternalServerError
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range rateLimitMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	var rpcErr rpc.Error
+	return !errors.As(err, &rpcErr)
+}
+
+// isUnavailable 判断错误是否因为节点没有请求的数据
+func isUnavailable(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, m := range unavailableMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return IsStateUnavailable(err)
+}
+
+// IsStateUnavailable 判断错误是否因为节点没有请求区块的历史状态（非归档节点只保留最近约 128 个区块的状态）
+func IsStateUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range stateUnavailableMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// call 依次在候选节点上执行 fn，直到成功或返回与节点无关的错误；minBlock 为请求涉及的最高区块（0 表示最新区块），
+// pinned 见 candidates。每个节点的请求超时为 RequestTimeout 与调用方 deadline 中较早的一个。
+// 一轮所有节点都失败后按指数退避重试，最多 MaxRetries 轮；没有节点达到 minBlock 时先查询各节点的区块高度再重试，
+// 最终返回包装了 ErrBlockNotReached 的错误。调用方的 ctx 取消或超时时立即返回
+func (c *Client) call(ctx context.Context, method string, minBlock uint64, pinned bool, fn func(ctx context.Context, e *endpoint) error) error {
+	var lastErr error
+	var skipped error // 节点没有数据时的错误，其他节点都没有成功时返回
+	for attempt := 0; attempt <= c.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := maxBackoff
+			if shift := attempt - 1; shift < 6 {
+				backoff = min(baseBackoff<<shift, maxBackoff)
+			}
+			log.Printf("⚠️  RPC %s failed on all endpoints: %v, retrying in %s (%d/%d)",
+				method, lastErr, backoff, attempt, c.opts.MaxRetries)
+			select {
+			case <-ctx.Done():
+				return lastErr
+			case <-time.After(backoff):
+			}
+		}
+
+		candidates := c.candidates(minBlock, pinned)
+		if len(candidates) == 0 {
+			lastErr = fmt.Errorf("%w: block %d, best known head %d", ErrBlockNotReached, minBlock, c.best())
+			c.pollHeads()
+			continue
+		}
+		retryable := false
+		for _, e := range candidates {
+			callCtx, cancel := context.WithTimeout(ctx, c.opts.RequestTimeout)
+			start := time.Now()
+			err := fn(callCtx, e)
+			cancel()
+
+			switch {
+			case err == nil:
+				e.success(time.Since(start))
+				return nil
+			case ctx.Err() != nil:
+				// 调用方取消或超时，不切换节点；只是单个节点超过 RequestTimeout 时按节点错误切换
+				return err
+			case errors.Is(err, ethereum.NotFound) || isUnavailable(err):
+				// 交易、区块或历史状态可能还没有同步到该节点，尝试下一个节点，但不计为节点错误
+				e.success(time.Since(start))
+				skipped = err
+			case !isRetryable(err):
+				e.success(time.Since(start))
+				return err
+			default:
+				e.failure(err)
+				log.Printf("⚠️  RPC %s failed on %s: %v", method, e.name, err)
+				lastErr = err
+				retryable = true
+			}
+		}
+		if !retryable {
+			return skipped
+		}
+	}
+	if skipped != nil {
+		return skipped
+	}
+	return fmt.Errorf("RPC %s failed on all endpoints after %d retries: %w", method, c.opts.MaxRetries, lastErr)
+}
+
+// blockNumberOf 把区块号参数转换为 minBlock，nil 或负数（latest / pending 等标签）返回 0
+func blockNumberOf(number *big.Int) uint64 {
+	if number == nil || number.Sign() < 0 {
+		return 0
+	}
+	return number.Uint64()
+}
+
+// BlockNumber 返回最新区块号
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	var head uint64
+	err := c.call(ctx, "eth_blockNumber", 0, false, func(ctx context.Context, e *endpoint) error {
+		n, err := e.eth.BlockNumber(ctx)
+		if err == nil {
+			head = n
+			c.observeHead(e, n)
+		}
+		return err
+	})
+	return head, err
+}
+
+// HeaderByNumber 返回区块头，number 为 nil 时返回最新区块
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var header *types.Header
+	err := c.call(ctx, "eth_getBlockByNumber", blockNumberOf(number), false, func(ctx context.Context, e *endpoint) error {
+		h, err := e.eth.HeaderByNumber(ctx, number)
+		if err == nil {
+			header = h
+			if number == nil {
+				c.observeHead(e, h.Number.Uint64())
+			}
+		}
+		return err
+	})
+	return header, err
+}
+
+// FilterLogs 查询事件日志，只使用已知区块高度不低于 ToBlock 的节点，都不满足时返回 ErrBlockNotReached
+func (c *Client) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	err := c.call(ctx, "eth_getLogs", blockNumberOf(q.ToBlock), true, func(ctx context.Context, e *endpoint) error {
+		l, err := e.eth.FilterLogs(ctx, q)
+		if err == nil {
+			logs = l
+		}
+		return err
+	})
+	return logs, err
+}
+
+// CallContract 执行只读调用，blockNumber 为 nil 时使用最新区块
+func (c *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var result []byte
+	err := c.call(ctx, "eth_call", blockNumberOf(blockNumber), false, func(ctx context.Context, e *endpoint) error {
+		r, err := e.eth.CallContract(ctx, msg, blockNumber)
+		if err == nil {
+			result = r
+		}
+		return err
+	})
+	return result, err
+}
+
+// CodeAt 返回合约代码，blockNumber 为 nil 时使用最新区块
+func (c *Client) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	var code []byte
+	err := c.call(ctx, "eth_getCode", blockNumberOf(blockNumber), false, func(ctx context.Context, e *endpoint) error {
+		r, err := e.eth.CodeAt(ctx, account, blockNumber)
+		if err == nil {
+			code = r
+		}
+		return err
+	})
+	return code, err
+}
+
+// TransactionReceipt 返回交易收据，所有节点都没有该交易时返回 ethereum.NotFound
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	var receipt *types.Receipt
+	err := c.call(ctx, "eth_getTransactionReceipt", 0, false, func(ctx context.Context, e *endpoint) error {
+		r, err := e.eth.TransactionReceipt(ctx, txHash)
+		if err == nil {
+			receipt = r
+		}
+		return err
+	})
+	return receipt, err
+}
+
+// TransactionByHash 返回交易，所有节点都没有该交易时返回 ethereum.NotFound
+func (c *Client) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	var tx *types.Transaction
+	var isPending bool
+	err := c.call(ctx, "eth_getTransactionByHash", 0, false, func(ctx context.Context, e *endpoint) error {
+		t, pending, err := e.eth.TransactionByHash(ctx, txHash)
+		if err == nil {
+			tx, isPending = t, pending
+		}
+		return err
+	})
+	return tx, isPending, err
+}
+
+// BatchCallContext 在一个节点上发送 JSON-RPC 批量请求，minBlock 为批量请求涉及的最高区块（0 表示最新区块）；
+// minBlock 大于 0 时与 FilterLogs 相同，只使用已知区块高度不低于 minBlock 的节点，都不满足时返回 ErrBlockNotReached。
+// 只有整个请求失败时切换节点；单个请求的错误记录在 BatchElem.Error 中，与 rpc.Client 相同
+func (c *Client) BatchCallContext(ctx context.Context, minBlock uint64, b []rpc.BatchElem) error {
+	method := "batch"
+	if len(b) > 0 {
+		method = fmt.Sprintf("batch %s x%d", b[0].Method, len(b))
+	}
+	return c.call(ctx, method, minBlock, true, func(ctx context.Context, e *endpoint) error {
+		return e.rpc.BatchCallContext(ctx, b)
+	})
+}
@@ -0,0 +1,154 @@
+package rpcclient
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// ewmaAlpha 延迟和错误率指数移动平均的平滑系数
+	ewmaAlpha = 0.2
+	// baseCooldown / maxCooldown 节点连续失败后暂停使用的时间，按连续失败次数指数增长
+	baseCooldown = time.Second
+	maxCooldown  = time.Minute
+)
+
+// 节点状态
+const (
+	StatusHealthy  = "healthy"  // 正常
+	StatusLagging  = "lagging"  // 区块高度落后最高节点超过 MaxLagBlocks
+	StatusCooldown = "cooldown" // 连续失败，冷却结束前只在其他节点都不可用时使用
+)
+
+// endpoint 一个 RPC 节点及其统计数据
+type endpoint struct {
+	name string // 去掉路径（通常包含 API key）后的 URL，用于日志和状态接口
+	eth  *ethclient.Client
+	rpc  *rpc.Client
+
+	mu                  sync.Mutex
+	latency             time.Duration // 请求延迟的指数移动平均
+	errorRate           float64       // 失败率的指数移动平均
+	requests            uint64
+	failures            uint64
+	consecutiveFailures int
+	cooldownUntil       time.Time
+	lastError           string
+	lastErrorAt         time.Time
+	head                uint64 // 最近一次观察到的区块高度
+	status              string // 上一次轮询时的状态，状态变化时输出日志
+}
+
+func dialEndpoint(index int, rawURL string) (*endpoint, error) {
+	client, err := rpc.Dial(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint{
+		name:   fmt.Sprintf("#%d %s", index, RedactURL(rawURL)),
+		eth:    ethclient.NewClient(client),
+		rpc:    client,
+		status: StatusHealthy,
+	}, nil
+}
+
+// RedactURL 只保留 scheme 和 host，避免在日志和状态接口中暴露路径中的 API key
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "<invalid url>"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func ewma(prev, sample float64) float64 {
+	return prev*(1-ewmaAlpha) + sample*ewmaAlpha
+}
+
+// success 记录一次节点正常响应的请求（包括合约 revert 等 JSON-RPC 错误）
+func (e *endpoint) success(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests++
+	e.consecutiveFailures = 0
+	e.cooldownUntil = time.Time{}
+	e.errorRate = ewma(e.errorRate, 0)
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(ewma(float64(e.latency), float64(latency)))
+	}
+}
+
+// failure 记录一次节点本身导致的失败，连续失败时冷却时间按 1s、2s、4s ... 增长，最长 1 分钟
+func (e *endpoint) failure(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests++
+	e.failures++
+	e.consecutiveFailures++
+	e.errorRate = ewma(e.errorRate, 1)
+	e.lastError = err.Error()
+	e.lastErrorAt = time.Now()
+
+	cooldown := maxCooldown
+	if shift := e.consecutiveFailures - 1; shift < 6 {
+		cooldown = min(baseCooldown<<shift, maxCooldown)
+	}
+	e.cooldownUntil = time.Now().Add(cooldown)
+}
+
+// observeHead 记录节点返回的最新区块高度
+func (e *endpoint) observeHead(head uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if head > e.head {
+		e.head = head
+	}
+}
+
+// state 返回节点的状态快照，best 为所有节点中最高的区块高度
+func (e *endpoint) state(best, maxLag uint64, now time.Time) EndpointState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	st := EndpointState{
+		URL:                 e.name,
+		Status:              StatusHealthy,
+		LatencyMs:           float64(e.latency) / float64(time.Millisecond),
+		ErrorRate:           e.errorRate,
+		Requests:            e.requests,
+		Failures:            e.failures,
+		ConsecutiveFailures: e.consecutiveFailures,
+		LastError:           e.lastError,
+		Head:                e.head,
+	}
+	if best > e.head {
+		st.Lag = best - e.head
+	}
+	if !e.lastErrorAt.IsZero() {
+		at := e.lastErrorAt
+		st.LastErrorAt = &at
+	}
+	switch {
+	case now.Before(e.cooldownUntil):
+		until := e.cooldownUntil
+		st.CooldownUntil = &until
+		st.Status = StatusCooldown
+	case st.Lag > maxLag:
+		st.Status = StatusLagging
+	}
+
+	// 评分越小越优先：平均延迟按错误率放大，还没有请求过的节点按 1ms 计算，优先尝试
+	latency := st.LatencyMs
+	if latency == 0 {
+		latency = 1
+	}
+	st.Score = latency * (1 + 10*e.errorRate)
+	return st
+}
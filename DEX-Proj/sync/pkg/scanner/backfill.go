@@ -0,0 +1,262 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"meta-node-dex-sync/pkg/config"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// headScanRange 接近链头（maxReorgDepth 以内）时每轮扫描的区块数，这些区块需要逐个获取区块头检查 reorg
+const headScanRange = 10
+
+// 回填参数的默认值，对应 config.yaml 的 Backfill 未配置的字段
+const (
+	defaultBackfillConcurrency = 4
+	defaultInitialBlockRange   = 500
+	defaultMinBlockRange       = 10
+	defaultMaxBlockRange       = 5000
+	defaultTargetLatency       = 2 * time.Second
+
+	// filterLogsTimeout 单次 FilterLogs 的超时时间，超时按区块范围过大处理
+	filterLogsTimeout = 60 * time.Second
+)
+
+// rangeTooLargeMessages 各 RPC 服务商在 FilterLogs 结果过多或区块范围过大时返回的错误信息（小写）
+var rangeTooLargeMessages = []string{
+	"query returned more than",  // Infura / Geth: query returned more than 10000 results
+	"response size exceeded",    // Alchemy: Log response size exceeded
+	"block range",               // block range is too wide / exceed maximum block range
+	"range too large",           // range too large
+	"too many results",          // too many results
+	"limit exceeded",            // limit exceeded
+	"logs matched by query",     // QuickNode: ... logs matched by query exceeds limit
+	"max results",               // max results exceeded
+	"request entity too large",  // HTTP 413
+	"exceeds the range allowed", // exceeds the range allowed for your plan
+	"query timeout exceeded",    // query timeout exceeded
+	"context deadline exceeded", // filterLogsTimeout
+}
+
+// isRangeTooLarge 判断 FilterLogs 的错误是否可以通过缩小区块范围解决
+func isRangeTooLarge(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range rangeTooLargeMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeSizer 根据 FilterLogs 的耗时和错误自适应调整回填的区块范围，多个 worker 共享
+type rangeSizer struct {
+	mu      sync.Mutex
+	size    uint64
+	min     uint64
+	max     uint64
+	latency time.Duration // 目标耗时
+}
+
+// newRangeSizer 按 config.yaml 的 Backfill 创建，未配置的字段使用默认值
+func newRangeSizer(cfg config.Config) *rangeSizer {
+	r := &rangeSizer{
+		size:    cfg.Backfill.InitialBlockRange,
+		min:     cfg.Backfill.MinBlockRange,
+		max:     cfg.Backfill.MaxBlockRange,
+		latency: time.Duration(cfg.Backfill.TargetLatencyMs) * time.Millisecond,
+	}
+	if r.min == 0 {
+		r.min = defaultMinBlockRange
+	}
+	if r.max == 0 {
+		r.max = defaultMaxBlockRange
+	}
+	if r.max < r.min {
+		r.max = r.min
+	}
+	if r.size == 0 {
+		r.size = defaultInitialBlockRange
+	}
+	if r.latency <= 0 {
+		r.latency = defaultTargetLatency
+	}
+	r.size = r.clamp(r.size)
+	return r
+}
+
+func (r *rangeSizer) clamp(size uint64) uint64 {
+	if size < r.min {
+		return r.min
+	}
+	if size > r.max {
+		return r.max
+	}
+	return size
+}
+
+// current 返回下一个区块范围的区块数
+func (r *rangeSizer) current() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size
+}
+
+// shrink 请求 blocks 个区块结果过多或超时后，区块数不超过 blocks 的一半
+func (r *rangeSizer) shrink(blocks uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if blocks/2 < r.size {
+		r.size = r.clamp(blocks / 2)
+	}
+}
+
+// observe 记录一次成功的 FilterLogs：不小于当前区块数的请求快于目标耗时的一半时扩大 50%，
+// 超过目标耗时时减半；更小的请求（拆分后的半个范围、最后一个范围）不代表当前区块数的耗时，不调整
+func (r *rangeSizer) observe(blocks uint64, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if blocks < r.size {
+		return
+	}
+	switch {
+	case elapsed > r.latency:
+		r.size = r.clamp(r.size / 2)
+	case elapsed < r.latency/2:
+		r.size = r.clamp(r.size + r.size/2)
+	}
+}
+
+// backfillRange 一个区块范围的回填结果
+type backfillRange struct {
+	start, end uint64
+	logs       []types.Log
+	header     *types.Header // end 区块的区块头，写入 blocks 表作为下一个范围检查 reorg 的起点
+	err        error
+}
+
+// backfillConcurrency 同时拉取的区块范围数量
+func (s *Scanner) backfillConcurrency() int {
+	if s.Config.Backfill.Concurrency > 0 {
+		return s.Config.Backfill.Concurrency
+	}
+	return defaultBackfillConcurrency
+}
+
+// backfill 并发拉取 [s.Current, target] 的事件日志，并严格按区块顺序逐个范围写入数据库
+//
+// target 不超过 链头 - maxReorgDepth，这些区块不会再发生 reorg，因此只获取每个范围最后一个区块的区块头，
+// 不逐个检查父哈希。多个 worker 同时请求 FilterLogs，结果先缓存在内存中，等前面的范围都写入后再处理；
+// 已拉取和正在拉取的范围总数不超过 2 倍并发数。任一范围拉取或写入失败时返回错误，已写入的范围保留，
+// 下一轮从 s.Current 继续
+func (s *Scanner) backfill(target uint64) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	concurrency := s.backfillConcurrency()
+	// 容量覆盖所有可能在途的请求，提前返回时 worker 不会阻塞
+	results := make(chan *backfillRange, 2*concurrency)
+	ready := make(map[uint64]*backfillRange)
+	next := s.Current
+	inFlight := 0
+	started := time.Now()
+	from := s.Current
+
+	log.Printf("Backfilling blocks %d - %d (concurrency=%d, blockRange=%d)", s.Current, target, concurrency, s.sizer.current())
+
+	dispatch := func() {
+		for next <= target && inFlight < concurrency && inFlight+len(ready) < 2*concurrency {
+			end := next + s.sizer.current() - 1
+			if end > target {
+				end = target
+			}
+			r := &backfillRange{start: next, end: end}
+			inFlight++
+			go func() {
+				r.logs, r.header, r.err = s.fetchBackfillRange(ctx, r.start, r.end)
+				results <- r
+			}()
+			next = end + 1
+		}
+	}
+
+	dispatch()
+	for s.Current <= target {
+		r := <-results
+		inFlight--
+		if r.err != nil {
+			return fmt.Errorf("拉取区块 %d-%d 失败: %w", r.start, r.end, r.err)
+		}
+		ready[r.start] = r
+
+		// 按区块顺序写入已经就绪的范围
+		for {
+			r, ok := ready[s.Current]
+			if !ok {
+				break
+			}
+			delete(ready, s.Current)
+			if err := s.processRange(r.start, r.end, []*types.Header{r.header}, r.logs); err != nil {
+				return err
+			}
+			s.Current = r.end + 1
+		}
+
+		elapsed := time.Since(started)
+		log.Printf("Backfilled to block %d / %d (%.1f blocks/s, blockRange=%d)",
+			s.Current-1, target, float64(s.Current-from)/elapsed.Seconds(), s.sizer.current())
+		dispatch()
+	}
+	return nil
+}
+
+// fetchBackfillRange 拉取一个区块范围的事件日志，并用一次批量请求获取事件所在区块和最后一个区块的区块头放入缓存
+func (s *Scanner) fetchBackfillRange(ctx context.Context, start, end uint64) ([]types.Log, *types.Header, error) {
+	logs, err := s.fetchLogsAdaptive(ctx, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.prefetchHeaders(ctx, logs, end); err != nil {
+		return nil, nil, err
+	}
+	header, _ := s.headers.get(end)
+	return logs, header, nil
+}
+
+// fetchLogsAdaptive 请求 [start, end] 的日志，结果过多或超时时缩小区块数，并把范围拆成两半分别请求
+func (s *Scanner) fetchLogsAdaptive(ctx context.Context, start, end uint64) ([]types.Log, error) {
+	callCtx, cancel := context.WithTimeout(ctx, filterLogsTimeout)
+	begin := time.Now()
+	logs, err := s.filterRangeLogs(callCtx, start, end)
+	cancel()
+	if err == nil {
+		s.sizer.observe(end-start+1, time.Since(begin))
+		return logs, nil
+	}
+	if ctx.Err() != nil || end == start || !isRangeTooLarge(err) {
+		return nil, err
+	}
+
+	s.sizer.shrink(end - start + 1)
+	mid := start + (end-start)/2
+	log.Printf("FilterLogs %d-%d too large (%v), splitting at %d", start, end, err, mid)
+	left, err := s.fetchLogsAdaptive(ctx, start, mid)
+	if err != nil {
+		return nil, err
+	}
+	right, err := s.fetchLogsAdaptive(ctx, mid+1, end)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
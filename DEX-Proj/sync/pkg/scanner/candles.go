@@ -0,0 +1,194 @@
+package scanner
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// candleInterval K 线周期
+type candleInterval struct {
+	Name    string // 写入 candles.period 的名称
+	Seconds int64
+}
+
+// candleIntervals 扫描器维护的 K 线周期
+var candleIntervals = []candleInterval{
+	{Name: "1m", Seconds: 60},
+	{Name: "5m", Seconds: 5 * 60},
+	{Name: "1h", Seconds: 60 * 60},
+	{Name: "1d", Seconds: 24 * 60 * 60},
+}
+
+// bucketStart 返回时间所在 K 线的开始时间（UTC 对齐）
+func (iv candleInterval) bucketStart(ts time.Time) time.Time {
+	unix := ts.Unix()
+	return time.Unix(unix-unix%iv.Seconds, 0).UTC()
+}
+
+// candlePrice 把 sqrtPriceX96 换算为按精度调整后的价格：1 token0 可兑换的 token1 数量
+// price = (sqrtPriceX96 / 2^96)^2 * 10^(decimals0 - decimals1)，以科学计数法字符串写入 NUMERIC
+func candlePrice(sqrtPriceX96 *big.Int, decimals0, decimals1 int64) string {
+	const precision = 256
+	sqrtPrice := new(big.Float).SetPrec(precision).SetInt(sqrtPriceX96)
+	sqrtPrice.Quo(sqrtPrice, new(big.Float).SetPrec(precision).SetInt(new(big.Int).Lsh(big.NewInt(1), 96)))
+	price := new(big.Float).SetPrec(precision).Mul(sqrtPrice, sqrtPrice)
+
+	exp := decimals0 - decimals1
+	if exp < 0 {
+		exp = -exp
+	}
+	scale := new(big.Float).SetPrec(precision).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil))
+	if decimals0 >= decimals1 {
+		price.Mul(price, scale)
+	} else {
+		price.Quo(price, scale)
+	}
+	return price.Text('e', 30)
+}
+
+// applySwapToCandles 把一笔 Swap 累加到池子各周期的 K 线中
+// 同一 K 线内的 Swap 必须按 (block_number, log_index) 顺序调用：第一笔确定 open，最后一笔确定 close。
+func applySwapToCandles(db dbExecutor, poolAddr string, swap candleSwap) error {
+	decimals0, decimals1, err := poolTokenDecimals(db, poolAddr)
+	if err != nil {
+		return err
+	}
+	for _, iv := range candleIntervals {
+		if err := upsertCandle(db, poolAddr, iv, swap, decimals0, decimals1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poolTokenDecimals 查询池子两个代币的精度，未知时按 18 处理（与 ensureToken 的默认值一致）
+func poolTokenDecimals(db dbExecutor, poolAddr string) (int64, int64, error) {
+	var decimals0, decimals1 int64
+	err := db.QueryRow(`
+		SELECT COALESCE(t0.decimals, 18), COALESCE(t1.decimals, 18)
+		FROM pools p
+		LEFT JOIN tokens t0 ON t0.address = p.token0
+		LEFT JOIN tokens t1 ON t1.address = p.token1
+		WHERE p.address = $1
+	`, poolAddr).Scan(&decimals0, &decimals1)
+	if err != nil {
+		return 0, 0, fmt.Errorf("查询池子 %s 的代币精度失败: %w", poolAddr, err)
+	}
+	return decimals0, decimals1, nil
+}
+
+// upsertCandle 把一笔 Swap 合并进单个周期的 K 线，成交量为两个代币数量的绝对值（最小单位）
+func upsertCandle(db dbExecutor, poolAddr string, iv candleInterval, swap candleSwap, decimals0, decimals1 int64) error {
+	price := candlePrice(swap.sqrtPriceX96, decimals0, decimals1)
+	_, err := db.Exec(`
+		INSERT INTO candles (
+			pool_address, period, bucket_start, open, high, low, close,
+			volume0, volume1, swap_count
+		) VALUES ($1, $2, $3, $4, $4, $4, $4, $5, $6, 1)
+		ON CONFLICT (pool_address, period, bucket_start) DO UPDATE SET
+			high = GREATEST(candles.high, EXCLUDED.high),
+			low = LEAST(candles.low, EXCLUDED.low),
+			close = EXCLUDED.close,
+			volume0 = candles.volume0 + EXCLUDED.volume0,
+			volume1 = candles.volume1 + EXCLUDED.volume1,
+			swap_count = candles.swap_count + 1,
+			updated_at = NOW()
+	`, poolAddr, iv.Name, iv.bucketStart(swap.ts), price,
+		new(big.Int).Abs(swap.amount0).String(), new(big.Int).Abs(swap.amount1).String())
+	if err != nil {
+		return fmt.Errorf("更新池子 %s 的 %s K 线失败: %w", poolAddr, iv.Name, err)
+	}
+	return nil
+}
+
+// rebuildCandlesSince 删除池子从 since 所在 K 线开始的数据，并按剩余的 swaps 重新累加
+// reorg 回滚删除孤块中的 swaps 后调用，每个周期只重放它被删除的那部分 K 线
+func rebuildCandlesSince(db dbExecutor, poolAddr string, since time.Time) error {
+	decimals0, decimals1, err := poolTokenDecimals(db, poolAddr)
+	if err != nil {
+		return err
+	}
+	for _, iv := range candleIntervals {
+		start := iv.bucketStart(since)
+		if _, err := db.Exec(`
+			DELETE FROM candles WHERE pool_address = $1 AND period = $2 AND bucket_start >= $3
+		`, poolAddr, iv.Name, start); err != nil {
+			return fmt.Errorf("删除池子 %s 的 %s K 线失败: %w", poolAddr, iv.Name, err)
+		}
+
+		swaps, err := loadSwapsSince(db, poolAddr, start)
+		if err != nil {
+			return err
+		}
+		for _, swap := range swaps {
+			if err := upsertCandle(db, poolAddr, iv, swap, decimals0, decimals1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// candleSwap 重放 K 线所需的 Swap 字段
+type candleSwap struct {
+	ts           time.Time
+	sqrtPriceX96 *big.Int
+	amount0      *big.Int
+	amount1      *big.Int
+}
+
+// loadSwapsSince 按顺序读取池子从 start 开始的 swaps
+func loadSwapsSince(db dbExecutor, poolAddr string, start time.Time) ([]candleSwap, error) {
+	rows, err := db.Query(`
+		SELECT block_timestamp, sqrt_price_x96::text, amount0::text, amount1::text
+		FROM swaps
+		WHERE pool_address = $1 AND block_timestamp >= $2
+		ORDER BY block_number, log_index
+	`, poolAddr, start)
+	if err != nil {
+		return nil, fmt.Errorf("查询池子 %s 的 swaps 失败: %w", poolAddr, err)
+	}
+	defer rows.Close()
+
+	var swaps []candleSwap
+	for rows.Next() {
+		var swap candleSwap
+		var sqrtPrice, amount0, amount1 string
+		if err := rows.Scan(&swap.ts, &sqrtPrice, &amount0, &amount1); err != nil {
+			return nil, err
+		}
+		swap.sqrtPriceX96, _ = new(big.Int).SetString(sqrtPrice, 10)
+		swap.amount0, _ = new(big.Int).SetString(amount0, 10)
+		swap.amount1, _ = new(big.Int).SetString(amount1, 10)
+		if swap.sqrtPriceX96 == nil || swap.amount0 == nil || swap.amount1 == nil {
+			return nil, fmt.Errorf("池子 %s 的 swap 数据无法解析", poolAddr)
+		}
+		swaps = append(swaps, swap)
+	}
+	return swaps, rows.Err()
+}
+
+// queryOrphanSwapStarts 返回每个池子在 ancestor 之后最早一笔 swap 的时间，reorg 回滚从这里开始重建 K 线
+func queryOrphanSwapStarts(db dbExecutor, ancestor uint64) (map[string]time.Time, error) {
+	rows, err := db.Query(`
+		SELECT pool_address, MIN(block_timestamp) FROM swaps
+		WHERE block_number > $1
+		GROUP BY pool_address
+	`, ancestor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	starts := make(map[string]time.Time)
+	for rows.Next() {
+		var addr string
+		var ts time.Time
+		if err := rows.Scan(&addr, &ts); err != nil {
+			return nil, err
+		}
+		starts[addr] = ts
+	}
+	return starts, rows.Err()
+}
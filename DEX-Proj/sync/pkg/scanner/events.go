@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"math/big"
 	"time"
@@ -11,8 +12,8 @@ import (
 )
 
 // handlePoolCreated 处理 PoolCreated 事件
-// 当 PoolManager 创建新池子时触发
-func (s *Scanner) handlePoolCreated(vLog types.Log) {
+// 当 PoolManager 创建新池子时触发；池子记录使用 upsert，重复处理是安全的
+func (s *Scanner) handlePoolCreated(vLog types.Log) error {
 	// Event: PoolCreated(address token0, address token1, uint32 index, int24 tickLower, int24 tickUpper, uint24 fee, address pool)
 	// Non-indexed: all in Data
 
@@ -27,43 +28,44 @@ func (s *Scanner) handlePoolCreated(vLog types.Log) {
 
 	if len(vLog.Data) < 7*32 {
 		log.Printf("Invalid PoolCreated data length: %d", len(vLog.Data))
-		return
+		return nil
 	}
 
 	token0 := common.BytesToAddress(vLog.Data[0:32])
 	token1 := common.BytesToAddress(vLog.Data[32:64])
-	// index := new(big.Int).SetBytes(vLog.Data[64:96])
+	index := new(big.Int).SetBytes(vLog.Data[64:96]).Int64()              // uint32
 	tickLower := int32(new(big.Int).SetBytes(vLog.Data[96:128]).Int64())  // int24
 	tickUpper := int32(new(big.Int).SetBytes(vLog.Data[128:160]).Int64()) // int24
 	fee := new(big.Int).SetBytes(vLog.Data[160:192]).Int64()              // uint24
 	poolAddr := common.BytesToAddress(vLog.Data[192:224])
 
-	log.Printf("Found new pool: %s (Tokens: %s, %s)", poolAddr.Hex(), token0.Hex(), token1.Hex())
+	log.Printf("Found new pool: %s (Tokens: %s, %s, Index: %d)", poolAddr.Hex(), token0.Hex(), token1.Hex(), index)
 
 	// Ensure tokens exist before inserting pool to satisfy foreign key constraints
 	s.ensureToken(token0)
 	s.ensureToken(token1)
 
 	// Store in DB
-	_, err := s.DB.Exec(`
-		INSERT INTO pools (address, token0, token1, fee, tick_lower, tick_upper, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (address) DO NOTHING
-	`, poolAddr.Hex(), token0.Hex(), token1.Hex(), fee, tickLower, tickUpper, time.Now())
+	_, err := s.db().Exec(`
+		INSERT INTO pools (address, token0, token1, pool_index, fee, tick_lower, tick_upper, created_block, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (address) DO UPDATE SET pool_index = EXCLUDED.pool_index, created_block = EXCLUDED.created_block
+	`, poolAddr.Hex(), token0.Hex(), token1.Hex(), index, fee, tickLower, tickUpper, vLog.BlockNumber, time.Now())
 
 	if err != nil {
-		log.Printf("Error inserting pool: %v", err)
-	} else {
-		// Add to cache
-		s.Pools[poolAddr] = true
-		// 从链上查询并更新池子的完整状态（sqrt_price_x96, liquidity, tick, reserve0, reserve1）
-		s.updatePoolStateFromChain(poolAddr)
+		return fmt.Errorf("插入池子 %s 失败: %w", poolAddr.Hex(), err)
 	}
+
+	// Add to cache
+	s.Pools[poolAddr] = true
+	// 从链上查询并更新池子的完整状态（sqrt_price_x96, liquidity, tick, reserve0, reserve1）
+	s.updatePoolStateFromChain(poolAddr)
+	return nil
 }
 
 // handleSwap 处理 Swap 事件
-// 当用户在池子中交换代币时触发
-func (s *Scanner) handleSwap(vLog types.Log) {
+// 当用户在池子中交换代币时触发；swaps 中已有该事件时不再更新池子状态
+func (s *Scanner) handleSwap(vLog types.Log) error {
 	// Event: Swap(address indexed sender, address indexed recipient, int256 amount0, int256 amount1, uint160 sqrtPriceX96, uint128 liquidity, int24 tick)
 	// Topics: [Sig, sender, recipient]
 	// Data: amount0, amount1, sqrtPriceX96, liquidity, tick
@@ -72,7 +74,7 @@ func (s *Scanner) handleSwap(vLog types.Log) {
 	recipient := common.BytesToAddress(vLog.Topics[2].Bytes())
 
 	if len(vLog.Data) < 5*32 {
-		return
+		return nil
 	}
 
 	// Quick parse helper for signed 256
@@ -92,28 +94,13 @@ func (s *Scanner) handleSwap(vLog types.Log) {
 	liquidity := new(big.Int).SetBytes(vLog.Data[96:128])
 	tick := parseSigned(vLog.Data[128:160]) // int24 is small, but passed as 32 bytes
 
-	// Update Pool State
-	_, err := s.DB.Exec(`
-		UPDATE pools SET sqrt_price_x96 = $1, liquidity = $2, tick = $3
-		WHERE address = $4
-	`, sqrtPrice.String(), liquidity.String(), tick.Int64(), vLog.Address.Hex())
-	if err != nil {
-		log.Printf("Error updating pool state: %v", err)
-	}
-
-	// Update pool reserves (balance0 and balance1)
-	s.updatePoolReserves(vLog.Address)
-
 	// Insert Swap
-	header, err := s.Client.HeaderByNumber(context.Background(), big.NewInt(int64(vLog.BlockNumber)))
-	if err != nil || header == nil {
-		log.Printf("Error fetching block header for block %d: %v, using current time", vLog.BlockNumber, err)
-		// Use current time as fallback
-		header = &types.Header{Time: uint64(time.Now().Unix())}
+	ts, err := s.blockTime(vLog)
+	if err != nil {
+		return fmt.Errorf("获取区块 %d 的时间戳失败: %w", vLog.BlockNumber, err)
 	}
-	ts := time.Unix(int64(header.Time), 0)
 
-	_, err = s.DB.Exec(`
+	result, err := s.db().Exec(`
 		INSERT INTO swaps (
 			transaction_hash, log_index, pool_address, sender, recipient, 
 			amount0, amount1, sqrt_price_x96, liquidity, tick, 
@@ -126,19 +113,53 @@ func (s *Scanner) handleSwap(vLog types.Log) {
 		vLog.BlockNumber, ts,
 	)
 	if err != nil {
-		log.Printf("Error inserting swap: %v", err)
+		return fmt.Errorf("插入 swap 失败 (tx=%s, logIndex=%d): %w", vLog.TxHash.Hex(), vLog.Index, err)
 	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		log.Printf("Swap already processed (tx=%s, logIndex=%d), skipping pool state update", vLog.TxHash.Hex(), vLog.Index)
+		return nil
+	}
+
+	// Update Pool State
+	_, err = s.db().Exec(`
+		UPDATE pools SET sqrt_price_x96 = $1, liquidity = $2, tick = $3
+		WHERE address = $4
+	`, sqrtPrice.String(), liquidity.String(), tick.Int64(), vLog.Address.Hex())
+	if err != nil {
+		return fmt.Errorf("更新池子 %s 状态失败: %w", vLog.Address.Hex(), err)
+	}
+
+	// Swap 收取的手续费计入 feeGrowthGlobal，按事件所在区块同步
+	if err := s.updatePoolFeeGrowth(vLog.Address, vLog.BlockNumber); err != nil {
+		return err
+	}
+
+	// 增量更新各周期的 K 线
+	if err := applySwapToCandles(s.db(), vLog.Address.Hex(), candleSwap{
+		ts:           ts,
+		sqrtPriceX96: sqrtPrice,
+		amount0:      amt0,
+		amount1:      amt1,
+	}); err != nil {
+		return err
+	}
+
+	// Update pool reserves (balance0 and balance1)
+	s.updatePoolReserves(vLog.Address)
+
+	// 记录 updated_block 并通知 backend 池子价格和流动性已改变
+	return markPoolChanged(s.db(), vLog.Address.Hex(), vLog.BlockNumber)
 }
 
 // handleMint 处理 Mint 事件
-// 当用户添加流动性时触发
-func (s *Scanner) handleMint(vLog types.Log) {
+// 当用户添加流动性时触发；liquidity_events 中已有该事件时跳过流动性、储备、ticks 和 position 的累加，避免重复计算
+func (s *Scanner) handleMint(vLog types.Log) error {
 	// Event: Mint(address sender, address indexed owner, uint128 amount, uint256 amount0, uint256 amount1)
 	// Topics: [Sig, owner]
 	// Data: sender(32), amount(32), amount0(32), amount1(32)
 
 	if len(vLog.Data) < 4*32 {
-		return
+		return nil
 	}
 
 	owner := common.BytesToAddress(vLog.Topics[1].Bytes())
@@ -146,15 +167,13 @@ func (s *Scanner) handleMint(vLog types.Log) {
 	amount0 := new(big.Int).SetBytes(vLog.Data[64:96])
 	amount1 := new(big.Int).SetBytes(vLog.Data[96:128])
 
-	header, err := s.Client.HeaderByNumber(context.Background(), big.NewInt(int64(vLog.BlockNumber)))
-	if err != nil || header == nil {
-		log.Printf("Error fetching block header for block %d: %v, using current time", vLog.BlockNumber, err)
-		header = &types.Header{Time: uint64(time.Now().Unix())}
+	ts, err := s.blockTime(vLog)
+	if err != nil {
+		return fmt.Errorf("获取区块 %d 的时间戳失败: %w", vLog.BlockNumber, err)
 	}
-	ts := time.Unix(int64(header.Time), 0)
 
 	// 1. 插入流动性事件记录
-	_, err = s.DB.Exec(`
+	result, err := s.db().Exec(`
 		INSERT INTO liquidity_events (
 			transaction_hash, log_index, pool_address, type, owner, 
 			amount, amount0, amount1, block_number, block_timestamp
@@ -164,63 +183,70 @@ func (s *Scanner) handleMint(vLog types.Log) {
 		amount.String(), amount0.String(), amount1.String(), vLog.BlockNumber, ts)
 
 	if err != nil {
-		log.Printf("Error inserting mint: %v", err)
+		return fmt.Errorf("插入 mint 事件失败 (tx=%s, logIndex=%d): %w", vLog.TxHash.Hex(), vLog.Index, err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		log.Printf("Mint already processed (tx=%s, logIndex=%d), skipping derived updates", vLog.TxHash.Hex(), vLog.Index)
+		return nil
 	}
 
 	// 2. 更新 pools 表的流动性（使用累加方式）
-	_, err = s.DB.Exec(`
+	_, err = s.db().Exec(`
 		UPDATE pools 
 		SET liquidity = liquidity + $1
 		WHERE address = $2
 	`, amount.String(), vLog.Address.Hex())
 	if err != nil {
-		log.Printf("Error updating pool liquidity: %v", err)
+		return fmt.Errorf("更新池子 %s 流动性失败: %w", vLog.Address.Hex(), err)
 	}
 
 	// 3. 更新池子的 reserve0 和 reserve1（使用 Mint 事件中的 amount0 和 amount1）
 	// 这是"笨办法"：直接使用 Mint 事件中的代币数量来更新 reserve
-	_, err = s.DB.Exec(`
+	_, err = s.db().Exec(`
 		UPDATE pools 
 		SET reserve0 = reserve0 + $1, reserve1 = reserve1 + $2
 		WHERE address = $3
 	`, amount0.String(), amount1.String(), vLog.Address.Hex())
 	if err != nil {
-		log.Printf("Error updating pool reserves from Mint event: %v", err)
-	} else {
-		log.Printf("✅ Updated pool reserves from Mint: %s (reserve0 += %s, reserve1 += %s)",
-			vLog.Address.Hex(), amount0.String(), amount1.String())
+		return fmt.Errorf("根据 Mint 事件更新池子 %s 储备失败: %w", vLog.Address.Hex(), err)
 	}
+	log.Printf("✅ Updated pool reserves from Mint: %s (reserve0 += %s, reserve1 += %s)",
+		vLog.Address.Hex(), amount0.String(), amount1.String())
 	
 	// 4. 如果 balanceOf 可用，也尝试更新（作为验证）
 	s.updatePoolReserves(vLog.Address)
 
 	// 3. 更新 ticks 表的流动性
-	s.updateTicksFromMint(vLog.Address, amount)
+	if err := s.updateTicksFromMint(vLog.Address, amount); err != nil {
+		return err
+	}
+	if err := markPoolChanged(s.db(), vLog.Address.Hex(), vLog.BlockNumber); err != nil {
+		return err
+	}
 
 	// 4. 尝试从同一交易中查找 PositionManager 的 Transfer 事件来获取 position ID
 	positionID := s.findPositionIDFromTransaction(vLog.TxHash, vLog.BlockNumber)
 	if positionID != nil {
 		// 找到了 position ID，更新或创建 position 记录
 		log.Printf("Found position ID %s from Pool Mint event, updating position", positionID.String())
-		s.updatePositionFromMint(*positionID, owner, vLog.Address, amount, vLog.BlockNumber)
-	} else {
-		// 没有找到 position ID，说明可能是通过 TestLP 直接添加的流动性（没有 NFT）
-		// 但我们仍然可以创建一个 position 记录，使用 owner + pool + tick 的哈希作为 ID
-		log.Printf("No position ID found in transaction %s, creating position from Pool Mint event",
-			vLog.TxHash.Hex())
-		s.createPositionFromPoolMint(owner, vLog.Address, amount, vLog.BlockNumber)
+		return s.updatePositionFromMint(*positionID, owner, vLog.Address, amount, vLog.BlockNumber)
 	}
+	// 没有找到 position ID，说明可能是通过 TestLP 直接添加的流动性（没有 NFT）
+	// 但我们仍然可以创建一个 position 记录，使用 owner + pool + tick 的哈希作为 ID
+	log.Printf("No position ID found in transaction %s, creating position from Pool Mint event",
+		vLog.TxHash.Hex())
+	return s.createPositionFromPoolMint(owner, vLog.Address, amount, vLog.BlockNumber)
 }
 
 // handleBurn 处理 Burn 事件
-// 当用户移除流动性时触发
-func (s *Scanner) handleBurn(vLog types.Log) {
+// 当用户移除流动性时触发；liquidity_events 中已有该事件时跳过流动性、储备、ticks 和 position 的扣减
+func (s *Scanner) handleBurn(vLog types.Log) error {
 	// Event: Burn(address indexed owner, uint128 amount, uint256 amount0, uint256 amount1)
 	// Topics: [Sig, owner]
 	// Data: amount, amount0, amount1
 
 	if len(vLog.Data) < 3*32 {
-		return
+		return nil
 	}
 
 	owner := common.BytesToAddress(vLog.Topics[1].Bytes())
@@ -228,66 +254,141 @@ func (s *Scanner) handleBurn(vLog types.Log) {
 	amount0 := new(big.Int).SetBytes(vLog.Data[32:64])
 	amount1 := new(big.Int).SetBytes(vLog.Data[64:96])
 
-	header, err := s.Client.HeaderByNumber(context.Background(), big.NewInt(int64(vLog.BlockNumber)))
-	if err != nil || header == nil {
-		log.Printf("Error fetching block header for block %d: %v, using current time", vLog.BlockNumber, err)
-		header = &types.Header{Time: uint64(time.Now().Unix())}
+	ts, err := s.blockTime(vLog)
+	if err != nil {
+		return fmt.Errorf("获取区块 %d 的时间戳失败: %w", vLog.BlockNumber, err)
+	}
+
+	// 确定 Burn 对应的 position，用于区分 Collect 领取的本金和手续费
+	positionID, err := s.resolvePositionID(vLog.TxHash, owner, vLog.Address)
+	if err != nil {
+		return err
 	}
-	ts := time.Unix(int64(header.Time), 0)
 
 	// 1. 插入流动性事件记录
-	_, err = s.DB.Exec(`
+	result, err := s.db().Exec(`
 		INSERT INTO liquidity_events (
 			transaction_hash, log_index, pool_address, type, owner, 
-			amount, amount0, amount1, block_number, block_timestamp
-		) VALUES ($1, $2, $3, 'BURN', $4, $5, $6, $7, $8, $9)
+			amount, amount0, amount1, block_number, block_timestamp, position_id
+		) VALUES ($1, $2, $3, 'BURN', $4, $5, $6, $7, $8, $9, $10)
 		ON CONFLICT DO NOTHING
 	`, vLog.TxHash.Hex(), vLog.Index, vLog.Address.Hex(), owner.Hex(),
-		amount.String(), amount0.String(), amount1.String(), vLog.BlockNumber, ts)
+		amount.String(), amount0.String(), amount1.String(), vLog.BlockNumber, ts, nullableBigInt(positionID))
 
 	if err != nil {
-		log.Printf("Error inserting burn: %v", err)
+		return fmt.Errorf("插入 burn 事件失败 (tx=%s, logIndex=%d): %w", vLog.TxHash.Hex(), vLog.Index, err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		log.Printf("Burn already processed (tx=%s, logIndex=%d), skipping derived updates", vLog.TxHash.Hex(), vLog.Index)
+		return nil
 	}
 
 	// 2. 更新 pools 表的流动性（使用累减方式）
-	_, err = s.DB.Exec(`
+	_, err = s.db().Exec(`
 		UPDATE pools 
 		SET liquidity = GREATEST(0, liquidity - $1)
 		WHERE address = $2
 	`, amount.String(), vLog.Address.Hex())
 	if err != nil {
-		log.Printf("Error updating pool liquidity: %v", err)
+		return fmt.Errorf("更新池子 %s 流动性失败: %w", vLog.Address.Hex(), err)
 	}
 
 	// 3. 更新池子的 reserve0 和 reserve1（使用 Burn 事件中的 amount0 和 amount1）
 	// 这是"笨办法"：直接使用 Burn 事件中的代币数量来更新 reserve
-	_, err = s.DB.Exec(`
+	_, err = s.db().Exec(`
 		UPDATE pools 
 		SET reserve0 = GREATEST(0, reserve0 - $1), reserve1 = GREATEST(0, reserve1 - $2)
 		WHERE address = $3
 	`, amount0.String(), amount1.String(), vLog.Address.Hex())
 	if err != nil {
-		log.Printf("Error updating pool reserves from Burn event: %v", err)
-	} else {
-		log.Printf("✅ Updated pool reserves from Burn: %s (reserve0 -= %s, reserve1 -= %s)",
-			vLog.Address.Hex(), amount0.String(), amount1.String())
+		return fmt.Errorf("根据 Burn 事件更新池子 %s 储备失败: %w", vLog.Address.Hex(), err)
 	}
+	log.Printf("✅ Updated pool reserves from Burn: %s (reserve0 -= %s, reserve1 -= %s)",
+		vLog.Address.Hex(), amount0.String(), amount1.String())
 	
 	// 4. 如果 balanceOf 可用，也尝试更新（作为验证）
 	s.updatePoolReserves(vLog.Address)
 
 	// 3. 更新 ticks 表的流动性
-	s.updateTicksFromBurn(vLog.Address, amount)
+	if err := s.updateTicksFromBurn(vLog.Address, amount); err != nil {
+		return err
+	}
+	if err := markPoolChanged(s.db(), vLog.Address.Hex(), vLog.BlockNumber); err != nil {
+		return err
+	}
 
 	// 4. 尝试从同一交易中查找相关的 position 并更新
-	s.updatePositionFromBurn(owner, vLog.Address, amount, vLog.BlockNumber, vLog.TxHash)
+	if err := s.updatePositionFromBurn(positionID, owner, vLog.Address, amount, vLog.BlockNumber, vLog.TxHash); err != nil {
+		return err
+	}
+
+	// 5. Burn 退出的代币记入 tokensOwed，从链上同步
+	if positionID != nil {
+		return s.refreshPositionOwed(positionID, owner, vLog.Address, vLog.BlockNumber)
+	}
+	return nil
+}
+
+// handleCollect 处理 Collect 事件
+// 当 LP 从池子领取 tokensOwed（手续费 + Burn 退出的本金）时触发；collects 中已有该事件时不再更新 position
+func (s *Scanner) handleCollect(vLog types.Log) error {
+	// Event: Collect(address indexed owner, address recipient, uint128 amount0, uint128 amount1)
+	// Topics: [Sig, owner]
+	// Data: recipient, amount0, amount1
+
+	if len(vLog.Data) < 3*32 {
+		return nil
+	}
+
+	owner := common.BytesToAddress(vLog.Topics[1].Bytes())
+	recipient := common.BytesToAddress(vLog.Data[0:32])
+	amount0 := new(big.Int).SetBytes(vLog.Data[32:64])
+	amount1 := new(big.Int).SetBytes(vLog.Data[64:96])
+
+	ts, err := s.blockTime(vLog)
+	if err != nil {
+		return fmt.Errorf("获取区块 %d 的时间戳失败: %w", vLog.BlockNumber, err)
+	}
+
+	positionID, err := s.resolvePositionID(vLog.TxHash, owner, vLog.Address)
+	if err != nil {
+		return err
+	}
+
+	// 1. 插入领取记录
+	result, err := s.db().Exec(`
+		INSERT INTO collects (
+			transaction_hash, log_index, pool_address, owner, recipient, position_id,
+			amount0, amount1, block_number, block_timestamp
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (transaction_hash, log_index) DO NOTHING
+	`, vLog.TxHash.Hex(), vLog.Index, vLog.Address.Hex(), owner.Hex(), recipient.Hex(),
+		nullableBigInt(positionID), amount0.String(), amount1.String(), vLog.BlockNumber, ts)
+	if err != nil {
+		return fmt.Errorf("插入 collect 事件失败 (tx=%s, logIndex=%d): %w", vLog.TxHash.Hex(), vLog.Index, err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		log.Printf("Collect already processed (tx=%s, logIndex=%d), skipping position update", vLog.TxHash.Hex(), vLog.Index)
+		return nil
+	}
+
+	log.Printf("Collect: pool=%s, owner=%s, recipient=%s, amount0=%s, amount1=%s",
+		vLog.Address.Hex(), owner.Hex(), recipient.Hex(), amount0.String(), amount1.String())
+
+	// 2. 同步 position 剩余的 tokensOwed
+	if positionID == nil {
+		log.Printf("No position resolved for Collect in tx %s, position not updated", vLog.TxHash.Hex())
+		return nil
+	}
+	return s.refreshPositionOwed(positionID, owner, vLog.Address, vLog.BlockNumber)
 }
 
 // handlePositionTransfer 处理 PositionManager 的 ERC721 Transfer 事件
 // 当 from 是 0x0 时表示 mint（创建新 position），当 to 是 0x0 时表示 burn（销毁 position）
-func (s *Scanner) handlePositionTransfer(vLog types.Log) {
+// 事件先记录到 position_transfers，已处理过的 Transfer 不再更新 positions
+func (s *Scanner) handlePositionTransfer(vLog types.Log) error {
 	if len(vLog.Topics) < 4 {
-		return
+		return nil
 	}
 
 	// Transfer(from, to, tokenId)
@@ -295,6 +396,20 @@ func (s *Scanner) handlePositionTransfer(vLog types.Log) {
 	to := common.BytesToAddress(vLog.Topics[2].Bytes())
 	tokenID := new(big.Int).SetBytes(vLog.Topics[3].Bytes())
 
+	result, err := s.db().Exec(`
+		INSERT INTO position_transfers (
+			transaction_hash, log_index, token_id, from_address, to_address, block_number
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (transaction_hash, log_index) DO NOTHING
+	`, vLog.TxHash.Hex(), vLog.Index, tokenID.String(), from.Hex(), to.Hex(), vLog.BlockNumber)
+	if err != nil {
+		return fmt.Errorf("插入 position transfer 失败 (tx=%s, logIndex=%d): %w", vLog.TxHash.Hex(), vLog.Index, err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		log.Printf("Position transfer already processed (tx=%s, logIndex=%d), skipping", vLog.TxHash.Hex(), vLog.Index)
+		return nil
+	}
+
 	// Mint: from 是 0x0，表示创建新 position
 	if from == (common.Address{}) {
 		log.Printf("PositionManager minted NFT: tokenId=%s, owner=%s", tokenID.String(), to.Hex())
@@ -302,8 +417,7 @@ func (s *Scanner) handlePositionTransfer(vLog types.Log) {
 		// 尝试从同一交易中查找 Pool 的 Mint 事件，以获取 pool 地址和流动性信息
 		receipt, err := s.Client.TransactionReceipt(context.Background(), vLog.TxHash)
 		if err != nil {
-			log.Printf("Error fetching transaction receipt: %v", err)
-			return
+			return fmt.Errorf("获取交易 %s 的 receipt 失败: %w", vLog.TxHash.Hex(), err)
 		}
 
 		// 查找同一交易中的 Pool Mint 事件
@@ -339,23 +453,27 @@ func (s *Scanner) handlePositionTransfer(vLog types.Log) {
 		if found {
 			// 找到了对应的 Pool Mint 事件，更新 position
 			log.Printf("Updating position %s from Transfer event", tokenID.String())
-			s.updatePositionFromMint(*tokenID, owner, poolAddr, liquidity, vLog.BlockNumber)
+			return s.updatePositionFromMint(*tokenID, owner, poolAddr, liquidity, vLog.BlockNumber)
 		} else {
 			// 没有找到对应的 Pool Mint 事件，通过 RPC 查询 PositionManager 合约获取详细信息
 			log.Printf("No corresponding Pool Mint event found for position %s in tx %s, querying from contract",
 				tokenID.String(), vLog.TxHash.Hex())
 
-			positionInfo, err := s.queryPositionFromContract(tokenID, vLog.BlockNumber)
+			var positionInfo *PositionInfo
+			err := s.atBlockOrLatest(vLog.BlockNumber, func(block uint64) error {
+				var err error
+				positionInfo, err = s.queryPositionFromContract(tokenID, block)
+				return err
+			})
 			if err != nil {
-				log.Printf("Error querying position %s from contract: %v", tokenID.String(), err)
-				return
+				return fmt.Errorf("从合约查询 position %s 失败: %w", tokenID.String(), err)
 			}
 
 			// 使用查询到的信息创建 position 记录
 			if positionInfo != nil {
 				// 获取 pool 地址（需要通过 PoolManager 查询，这里先尝试从数据库查找）
 				var poolAddrFromDB string
-				err := s.DB.QueryRow(`
+				err := s.db().QueryRow(`
 					SELECT address FROM pools 
 					WHERE token0 = $1 AND token1 = $2
 					LIMIT 1
@@ -367,13 +485,13 @@ func (s *Scanner) handlePositionTransfer(vLog types.Log) {
 						poolAddr.Hex(), tokenID.String())
 
 					// 使用合约查询到的信息更新 position
-					result, err := s.DB.Exec(`
+					result, err := s.db().Exec(`
 						INSERT INTO positions (
 							id, owner, pool_address, token0, token1, 
 							tick_lower, tick_upper, liquidity, 
 							fee_growth_inside0_last_x128, fee_growth_inside1_last_x128,
-							tokens_owed0, tokens_owed1
-						) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+							tokens_owed0, tokens_owed1, created_block, updated_block
+						) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13)
 						ON CONFLICT (id) DO UPDATE SET
 							owner = $2,
 							liquidity = $8,
@@ -383,6 +501,7 @@ func (s *Scanner) handlePositionTransfer(vLog types.Log) {
 							fee_growth_inside1_last_x128 = $10,
 							tokens_owed0 = $11,
 							tokens_owed1 = $12,
+							updated_block = $13,
 							updated_at = NOW()
 					`, tokenID.String(), positionInfo.Owner.Hex(), poolAddr.Hex(),
 						positionInfo.Token0.Hex(), positionInfo.Token1.Hex(),
@@ -391,15 +510,15 @@ func (s *Scanner) handlePositionTransfer(vLog types.Log) {
 						positionInfo.FeeGrowthInside0LastX128.String(),
 						positionInfo.FeeGrowthInside1LastX128.String(),
 						positionInfo.TokensOwed0.String(),
-						positionInfo.TokensOwed1.String())
+						positionInfo.TokensOwed1.String(),
+						vLog.BlockNumber)
 
 					if err != nil {
-						log.Printf("Error upserting position from contract query: %v", err)
-					} else {
-						rowsAffected, _ := result.RowsAffected()
-						log.Printf("Successfully upserted position %s from contract query (rowsAffected=%d)",
-							tokenID.String(), rowsAffected)
+						return fmt.Errorf("根据合约数据写入 position %s 失败: %w", tokenID.String(), err)
 					}
+					rowsAffected, _ := result.RowsAffected()
+					log.Printf("Successfully upserted position %s from contract query (rowsAffected=%d)",
+						tokenID.String(), rowsAffected)
 				} else {
 					log.Printf("Could not find pool for position %s (token0=%s, token1=%s): %v",
 						tokenID.String(), positionInfo.Token0.Hex(), positionInfo.Token1.Hex(), err)
@@ -411,13 +530,13 @@ func (s *Scanner) handlePositionTransfer(vLog types.Log) {
 		log.Printf("PositionManager burned NFT: tokenId=%s", tokenID.String())
 
 		// 更新 position 的流动性为 0（如果还没有被更新）
-		_, err := s.DB.Exec(`
+		_, err := s.db().Exec(`
 			UPDATE positions 
-			SET liquidity = 0, updated_at = NOW()
+			SET liquidity = 0, updated_block = $2, updated_at = NOW()
 			WHERE id = $1
-		`, tokenID.String())
+		`, tokenID.String(), vLog.BlockNumber)
 		if err != nil {
-			log.Printf("Error updating position on burn: %v", err)
+			return fmt.Errorf("更新被销毁的 position %s 失败: %w", tokenID.String(), err)
 		}
 	} else {
 		// 普通的 Transfer（不是 mint/burn），可能是 position 的所有权转移
@@ -425,13 +544,14 @@ func (s *Scanner) handlePositionTransfer(vLog types.Log) {
 			tokenID.String(), from.Hex(), to.Hex())
 
 		// 更新 position 的 owner
-		_, err := s.DB.Exec(`
+		_, err := s.db().Exec(`
 			UPDATE positions 
-			SET owner = $1, updated_at = NOW()
+			SET owner = $1, updated_block = $3, updated_at = NOW()
 			WHERE id = $2
-		`, to.Hex(), tokenID.String())
+		`, to.Hex(), tokenID.String(), vLog.BlockNumber)
 		if err != nil {
-			log.Printf("Error updating position owner: %v", err)
+			return fmt.Errorf("更新 position %s 的 owner 失败: %w", tokenID.String(), err)
 		}
 	}
+	return nil
 }
@@ -0,0 +1,144 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxHeaderBatch 一个 JSON-RPC 批量请求中的区块头数量上限
+const maxHeaderBatch = 100
+
+// headerCache 按区块号缓存尚未写入数据库的区块范围的区块头，回填的 worker 和主循环共享
+// 区块范围提交后 pruneThrough 删除已写入的区块，reorg 回滚后 reset 清空
+type headerCache struct {
+	mu      sync.Mutex
+	headers map[uint64]*types.Header
+}
+
+func newHeaderCache() *headerCache {
+	return &headerCache{headers: make(map[uint64]*types.Header)}
+}
+
+func (c *headerCache) get(number uint64) (*types.Header, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header, ok := c.headers[number]
+	return header, ok
+}
+
+func (c *headerCache) put(headers []*types.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, header := range headers {
+		c.headers[header.Number.Uint64()] = header
+	}
+}
+
+// pruneThrough 删除 number 及之前的区块头
+func (c *headerCache) pruneThrough(number uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for n := range c.headers {
+		if n <= number {
+			delete(c.headers, n)
+		}
+	}
+}
+
+func (c *headerCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = make(map[uint64]*types.Header)
+}
+
+// headersByNumber 用 JSON-RPC 批量请求 eth_getBlockByNumber 获取区块头（超过 maxHeaderBatch 时分批），
+// 结果与 numbers 的顺序一致；任一区块获取失败或不存在时返回错误
+func (s *Scanner) headersByNumber(ctx context.Context, numbers []uint64) ([]*types.Header, error) {
+	headers := make([]*types.Header, len(numbers))
+	for from := 0; from < len(numbers); from += maxHeaderBatch {
+		to := from + maxHeaderBatch
+		if to > len(numbers) {
+			to = len(numbers)
+		}
+
+		batch := make([]rpc.BatchElem, 0, to-from)
+		for i := from; i < to; i++ {
+			batch = append(batch, rpc.BatchElem{
+				Method: "eth_getBlockByNumber",
+				Args:   []interface{}{hexutil.EncodeUint64(numbers[i]), false},
+				Result: &headers[i],
+			})
+		}
+		if err := s.Client.BatchCallContext(ctx, slices.Max(numbers[from:to]), batch); err != nil {
+			return nil, fmt.Errorf("批量获取区块 %d-%d 的区块头失败: %w", numbers[from], numbers[to-1], err)
+		}
+		for i, elem := range batch {
+			if elem.Error != nil {
+				return nil, fmt.Errorf("获取区块 %d 的区块头失败: %w", numbers[from+i], elem.Error)
+			}
+			if headers[from+i] == nil {
+				return nil, fmt.Errorf("区块 %d 不存在", numbers[from+i])
+			}
+		}
+	}
+	return headers, nil
+}
+
+// prefetchHeaders 收集日志所在的区块（以及 extra 中的区块），把缓存中没有的区块头合并为一个批量请求获取并缓存
+// 缓存或获取到的区块哈希与日志不一致时说明发生了 reorg，返回错误
+func (s *Scanner) prefetchHeaders(ctx context.Context, logs []types.Log, extra ...uint64) error {
+	seen := make(map[uint64]bool)
+	var missing []uint64
+	need := func(number uint64, hash *common.Hash) {
+		if seen[number] {
+			return
+		}
+		seen[number] = true
+		if header, ok := s.headers.get(number); ok && (hash == nil || header.Hash() == *hash) {
+			return
+		}
+		missing = append(missing, number)
+	}
+	for i := range logs {
+		need(logs[i].BlockNumber, &logs[i].BlockHash)
+	}
+	for _, number := range extra {
+		need(number, nil)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	headers, err := s.headersByNumber(ctx, missing)
+	if err != nil {
+		return err
+	}
+	s.headers.put(headers)
+
+	for _, vLog := range logs {
+		if header, _ := s.headers.get(vLog.BlockNumber); header.Hash() != vLog.BlockHash {
+			return fmt.Errorf("区块 %d 的日志哈希与区块头不一致，扫描过程中发生了 reorg", vLog.BlockNumber)
+		}
+	}
+	return nil
+}
+
+// blockTime 返回日志所在区块的时间戳：通常已由 prefetchHeaders 缓存，缓存中没有时单独获取
+// 无法获取时返回错误，整个区块范围回滚后重试，不写入错误的时间
+func (s *Scanner) blockTime(vLog types.Log) (time.Time, error) {
+	if err := s.prefetchHeaders(context.Background(), []types.Log{vLog}); err != nil {
+		return time.Time{}, err
+	}
+	header, _ := s.headers.get(vLog.BlockNumber)
+	return time.Unix(int64(header.Time), 0), nil
+}
@@ -0,0 +1,326 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// defaultMulticall3 Multicall3 在各条链上的统一部署地址，config.yaml 未配置 Contracts.Multicall3 时使用
+const defaultMulticall3 = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+// maxCallBatch 一次 aggregate3 或一个 JSON-RPC 批量请求中的调用数量上限
+const maxCallBatch = 100
+
+// Multicall3 ABI 定义（仅包含 aggregate3）
+var multicall3ABI = `[
+	{
+		"inputs": [
+			{
+				"components": [
+					{"name": "target", "type": "address"},
+					{"name": "allowFailure", "type": "bool"},
+					{"name": "callData", "type": "bytes"}
+				],
+				"name": "calls",
+				"type": "tuple[]"
+			}
+		],
+		"name": "aggregate3",
+		"outputs": [
+			{
+				"components": [
+					{"name": "success", "type": "bool"},
+					{"name": "returnData", "type": "bytes"}
+				],
+				"name": "returnData",
+				"type": "tuple[]"
+			}
+		],
+		"stateMutability": "payable",
+		"type": "function"
+	}
+]`
+
+// 批量读取使用的 ABI，启动时解析一次
+var (
+	parsedERC20ABI      = mustParseABI(erc20ABI)
+	parsedPoolABI       = mustParseABI(poolABI)
+	parsedFactoryABI    = mustParseABI(factoryABI)
+	parsedMulticall3ABI = mustParseABI(multicall3ABI)
+)
+
+// errCallReverted 调用 revert 且没有返回数据，通常说明合约没有这个函数（例如非标准 ERC20 的 balanceOf）
+var errCallReverted = errors.New("execution reverted")
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse ABI: %v", err))
+	}
+	return parsed
+}
+
+// multicall3Call / multicall3Result 对应 aggregate3 的 Call3 和 Result 结构体
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// contractCall 批量读取中的一个只读调用，batchCall 执行后填充 values 或 err
+type contractCall struct {
+	to     common.Address
+	abi    *abi.ABI
+	method string
+	data   []byte
+
+	values []interface{}
+	err    error
+}
+
+// newCall 创建一个只读调用，参数打包失败时记录在 err 中，batchCall 会跳过它
+func newCall(to common.Address, contractABI *abi.ABI, method string, args ...interface{}) *contractCall {
+	c := &contractCall{to: to, abi: contractABI, method: method}
+	c.data, c.err = contractABI.Pack(method, args...)
+	return c
+}
+
+// decode 按方法的 outputs 解码返回数据
+func (c *contractCall) decode(data []byte) {
+	values, err := c.abi.Unpack(c.method, data)
+	if err != nil {
+		c.err = fmt.Errorf("failed to unpack %s: %v", c.method, err)
+		return
+	}
+	c.values = values
+}
+
+// value 返回第 i 个输出，调用失败或没有结果时返回错误
+func (c *contractCall) value(i int) (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if len(c.values) <= i {
+		return nil, fmt.Errorf("%s: empty result", c.method)
+	}
+	return c.values[i], nil
+}
+
+func (c *contractCall) bigIntValue(i int) (*big.Int, error) {
+	v, err := c.value(i)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := v.(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("%s is not *big.Int, type=%T", c.method, v)
+	}
+	return n, nil
+}
+
+// intValue 把 uint8 / uint24 / int24 等小整数输出转换为 int64
+func (c *contractCall) intValue(i int) (int64, error) {
+	v, err := c.value(i)
+	if err != nil {
+		return 0, err
+	}
+	switch n := v.(type) {
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case *big.Int:
+		return n.Int64(), nil
+	default:
+		return 0, fmt.Errorf("unexpected %s type: %T", c.method, v)
+	}
+}
+
+func (c *contractCall) stringValue(i int) (string, error) {
+	v, err := c.value(i)
+	if err != nil {
+		return "", err
+	}
+	str, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s is not string, type=%T", c.method, v)
+	}
+	return str, nil
+}
+
+func (c *contractCall) addressValue(i int) (common.Address, error) {
+	v, err := c.value(i)
+	if err != nil {
+		return common.Address{}, err
+	}
+	addr, ok := v.(common.Address)
+	if !ok {
+		return common.Address{}, fmt.Errorf("%s is not address, type=%T", c.method, v)
+	}
+	return addr, nil
+}
+
+// isMissingFunction 判断调用失败是否因为合约没有这个函数：节点返回 function selector was not recognized，
+// 或 Multicall3 中的调用 revert 且没有返回数据
+func isMissingFunction(err error) bool {
+	return err != nil && (errors.Is(err, errCallReverted) || strings.Contains(err.Error(), "function selector was not recognized"))
+}
+
+// detectMulticall 检查 Multicall3 是否已部署，没有部署时返回 nil，batchCall 改用 JSON-RPC 批量 eth_call
+func (s *Scanner) detectMulticall() *common.Address {
+	address := s.Config.Contracts.Multicall3
+	if address == "" {
+		address = defaultMulticall3
+	}
+	multicall := common.HexToAddress(address)
+	code, err := s.Client.CodeAt(context.Background(), multicall, nil)
+	if err != nil {
+		log.Printf("⚠️  Failed to check Multicall3 at %s: %v, using JSON-RPC batch eth_call", multicall.Hex(), err)
+		return nil
+	}
+	if len(code) == 0 {
+		log.Printf("⚠️  Multicall3 not deployed at %s, using JSON-RPC batch eth_call", multicall.Hex())
+		return nil
+	}
+	log.Printf("Multicall3 address: %s", multicall.Hex())
+	return &multicall
+}
+
+// batchCall 在同一个区块高度上执行一批只读调用：每 maxCallBatch 个合并为一次 Multicall3.aggregate3，
+// 链上没有 Multicall3 或 aggregate3 失败（例如查询的区块早于 Multicall3 部署）时改用 JSON-RPC 批量 eth_call。
+// blockNumber 为 nil 时先查询最新区块号，所有调用固定在该区块上，返回实际使用的区块号；
+// 单个调用的失败记录在对应的 call.err 中，只有请求本身失败时返回错误
+func (s *Scanner) batchCall(ctx context.Context, blockNumber *big.Int, calls []*contractCall) (*big.Int, error) {
+	if blockNumber == nil {
+		latest, err := s.Client.BlockNumber(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("获取最新区块号失败: %w", err)
+		}
+		blockNumber = new(big.Int).SetUint64(latest)
+	}
+
+	pending := make([]*contractCall, 0, len(calls))
+	for _, c := range calls {
+		if c.err == nil {
+			pending = append(pending, c)
+		}
+	}
+
+	for from := 0; from < len(pending); from += maxCallBatch {
+		to := from + maxCallBatch
+		if to > len(pending) {
+			to = len(pending)
+		}
+		chunk := pending[from:to]
+
+		if s.multicall != nil {
+			err := s.aggregate3(ctx, blockNumber, chunk)
+			if err == nil {
+				continue
+			}
+			log.Printf("⚠️  Multicall3 aggregate3 failed at block %s: %v, falling back to JSON-RPC batch", blockNumber.String(), err)
+		}
+		if err := s.batchEthCall(ctx, blockNumber, chunk); err != nil {
+			return nil, err
+		}
+	}
+	return blockNumber, nil
+}
+
+// aggregate3 用一次 eth_call 执行 Multicall3.aggregate3，allowFailure 为 true，单个调用 revert 不影响其他调用
+func (s *Scanner) aggregate3(ctx context.Context, blockNumber *big.Int, calls []*contractCall) error {
+	req := make([]multicall3Call, len(calls))
+	for i, c := range calls {
+		req[i] = multicall3Call{Target: c.to, AllowFailure: true, CallData: c.data}
+	}
+	data, err := parsedMulticall3ABI.Pack("aggregate3", req)
+	if err != nil {
+		return fmt.Errorf("failed to pack aggregate3 call: %v", err)
+	}
+
+	output, err := s.Client.CallContract(ctx, ethereum.CallMsg{
+		To:   s.multicall,
+		Data: data,
+	}, blockNumber)
+	if err != nil {
+		return err
+	}
+
+	values, err := parsedMulticall3ABI.Unpack("aggregate3", output)
+	if err != nil || len(values) == 0 {
+		return fmt.Errorf("failed to unpack aggregate3: %v", err)
+	}
+	results := *abi.ConvertType(values[0], new([]multicall3Result)).(*[]multicall3Result)
+	if len(results) != len(calls) {
+		return fmt.Errorf("aggregate3 returned %d results for %d calls", len(results), len(calls))
+	}
+
+	for i, result := range results {
+		if !result.Success {
+			calls[i].err = revertError(result.ReturnData)
+			continue
+		}
+		calls[i].decode(result.ReturnData)
+	}
+	return nil
+}
+
+// batchEthCall 把每个调用作为一个 eth_call 放进同一个 JSON-RPC 批量请求
+func (s *Scanner) batchEthCall(ctx context.Context, blockNumber *big.Int, calls []*contractCall) error {
+	outputs := make([]hexutil.Bytes, len(calls))
+	batch := make([]rpc.BatchElem, len(calls))
+	for i, c := range calls {
+		batch[i] = rpc.BatchElem{
+			Method: "eth_call",
+			Args: []interface{}{
+				map[string]interface{}{"to": c.to, "data": hexutil.Bytes(c.data)},
+				hexutil.EncodeBig(blockNumber),
+			},
+			Result: &outputs[i],
+		}
+	}
+	if err := s.Client.BatchCallContext(ctx, blockNumber.Uint64(), batch); err != nil {
+		return fmt.Errorf("批量 eth_call 失败 (block=%s): %w", blockNumber.String(), err)
+	}
+
+	for i, elem := range batch {
+		if elem.Error != nil {
+			calls[i].err = elem.Error
+			continue
+		}
+		calls[i].decode(outputs[i])
+	}
+	return nil
+}
+
+// revertError 把 revert 的返回数据转换为错误，能解析出 Error(string) 时带上原因
+func revertError(data []byte) error {
+	if len(data) == 0 {
+		return errCallReverted
+	}
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return fmt.Errorf("execution reverted: %s", reason)
+	}
+	return fmt.Errorf("execution reverted: %s", hexutil.Encode(data))
+}
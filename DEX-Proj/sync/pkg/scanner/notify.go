@@ -0,0 +1,33 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PoolChangesChannel 池子状态变化的 Postgres NOTIFY 频道，backend 通过 LISTEN 该频道刷新缓存
+const PoolChangesChannel = "pool_changes"
+
+// poolChange NOTIFY 的 JSON 负载
+type poolChange struct {
+	Pool  string `json:"pool"`
+	Block uint64 `json:"block"` // 池子状态对应的区块高度
+}
+
+// markPoolChanged 记录 pools 或 ticks 中池子的状态已改变：把 pools.updated_block 设为 blockNumber 并发送通知。
+// 所有写入池子状态的路径最后都会调用它，backend 没有收到通知（未开启 LISTEN 或连接断开）时按 updated_block 增量刷新。
+// db 为事务时 NOTIFY 在事务提交后才会送达，回滚时不会送达，同一事务中相同的负载只送达一次；
+// 因此扫描区块范围时应通过 s.db() 发送，backend 收到通知时总能读到已提交的状态
+func markPoolChanged(db dbExecutor, poolAddr string, blockNumber uint64) error {
+	if _, err := db.Exec(`UPDATE pools SET updated_block = $1 WHERE address = $2`, blockNumber, poolAddr); err != nil {
+		return fmt.Errorf("更新池子 %s 的 updated_block 失败: %w", poolAddr, err)
+	}
+	payload, err := json.Marshal(poolChange{Pool: poolAddr, Block: blockNumber})
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(`SELECT pg_notify($1, $2)`, PoolChangesChannel, string(payload)); err != nil {
+		return fmt.Errorf("发送池子 %s 变化通知失败: %w", poolAddr, err)
+	}
+	return nil
+}
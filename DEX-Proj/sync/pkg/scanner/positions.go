@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
@@ -44,49 +45,159 @@ func (s *Scanner) findPositionIDFromTransaction(txHash common.Hash, blockNumber
 	return nil
 }
 
+// virtualPositionID 生成虚拟 position（没有 NFT）的 ID：使用 owner + pool + tick 的哈希值
+func virtualPositionID(owner common.Address, poolAddr common.Address, tickLower, tickUpper int) *big.Int {
+	// 转换为数字，确保唯一性
+	hashInput := fmt.Sprintf("%s:%s:%d:%d", owner.Hex(), poolAddr.Hex(), tickLower, tickUpper)
+	hash := crypto.Keccak256Hash([]byte(hashInput))
+	positionID := new(big.Int).SetBytes(hash.Bytes())
+	// 取前 64 位作为 ID（避免过大）
+	positionID.Mod(positionID, new(big.Int).Lsh(big.NewInt(1), 64))
+	return positionID
+}
+
+// PositionManager 中以 positionId 为第一个参数的方法选择器，用于从交易 calldata 中解析 position ID
+var (
+	selectorPositionBurn    = crypto.Keccak256([]byte("burn(uint256)"))[:4]
+	selectorPositionCollect = crypto.Keccak256([]byte("collect(uint256,address)"))[:4]
+)
+
+// resolvePositionID 确定 Pool 的 Burn/Collect 事件对应的 position ID
+// owner 为 PositionManager 时，从交易 calldata（PositionManager.burn/collect 的 positionId 参数）解析 NFT ID；
+// 否则是直接与池子交互的 LP（如 TestLP），使用虚拟 position ID。无法确定时返回 nil
+func (s *Scanner) resolvePositionID(txHash common.Hash, owner common.Address, poolAddr common.Address) (*big.Int, error) {
+	positionManagerAddr := common.HexToAddress(s.Config.Contracts.PositionManager)
+	if owner != positionManagerAddr {
+		var tickLower, tickUpper int
+		err := s.db().QueryRow(`
+			SELECT tick_lower, tick_upper FROM pools WHERE address = $1
+		`, poolAddr.Hex()).Scan(&tickLower, &tickUpper)
+		if err != nil {
+			return nil, fmt.Errorf("查询池子 %s 的 tick 范围失败: %w", poolAddr.Hex(), err)
+		}
+		return virtualPositionID(owner, poolAddr, tickLower, tickUpper), nil
+	}
+
+	tx, _, err := s.Client.TransactionByHash(context.Background(), txHash)
+	if err != nil {
+		return nil, fmt.Errorf("获取交易 %s 失败: %w", txHash.Hex(), err)
+	}
+	data := tx.Data()
+	if tx.To() == nil || *tx.To() != positionManagerAddr || len(data) < 36 {
+		// 通过其他合约（如 multicall）间接调用，无法从 calldata 解析
+		log.Printf("Cannot resolve position ID from tx %s: not a direct PositionManager call", txHash.Hex())
+		return nil, nil
+	}
+	if !bytes.Equal(data[:4], selectorPositionBurn) && !bytes.Equal(data[:4], selectorPositionCollect) {
+		log.Printf("Cannot resolve position ID from tx %s: unexpected selector %x", txHash.Hex(), data[:4])
+		return nil, nil
+	}
+	return new(big.Int).SetBytes(data[4:36]), nil
+}
+
+// nullableBigInt 把可能为 nil 的 *big.Int 转换为数据库参数，nil 写入 NULL
+func nullableBigInt(v *big.Int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return v.String()
+}
+
+// refreshPositionOwed 按事件所在区块从链上查询 position 的 tokensOwed 和 feeGrowthInside*LastX128 并写入 positions
+// NFT position 查询 PositionManager.positions(id)，虚拟 position 查询 Pool.positions(owner)；
+// 读取的是区块结束时的状态，节点没有该区块的历史状态时改用最新区块（见 atBlockOrLatest）
+func (s *Scanner) refreshPositionOwed(positionID *big.Int, owner common.Address, poolAddr common.Address, blockNumber uint64) error {
+	var tokensOwed0, tokensOwed1, feeGrowth0, feeGrowth1 *big.Int
+
+	if owner == common.HexToAddress(s.Config.Contracts.PositionManager) {
+		var info *PositionInfo
+		err := s.atBlockOrLatest(blockNumber, func(block uint64) error {
+			var err error
+			info, err = s.queryPositionFromContract(positionID, block)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("从合约查询 position %s 失败: %w", positionID.String(), err)
+		}
+		tokensOwed0, tokensOwed1 = info.TokensOwed0, info.TokensOwed1
+		feeGrowth0, feeGrowth1 = info.FeeGrowthInside0LastX128, info.FeeGrowthInside1LastX128
+	} else {
+		var position *PoolPosition
+		err := s.atBlockOrLatest(blockNumber, func(block uint64) error {
+			var err error
+			position, err = s.queryPoolPosition(poolAddr, owner, block)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		tokensOwed0, tokensOwed1 = position.TokensOwed0, position.TokensOwed1
+		feeGrowth0, feeGrowth1 = position.FeeGrowthInside0LastX128, position.FeeGrowthInside1LastX128
+	}
+	if tokensOwed0 == nil || tokensOwed1 == nil || feeGrowth0 == nil || feeGrowth1 == nil {
+		return fmt.Errorf("position %s 的链上数据不完整", positionID.String())
+	}
+
+	result, err := s.db().Exec(`
+		UPDATE positions SET
+			tokens_owed0 = $2,
+			tokens_owed1 = $3,
+			fee_growth_inside0_last_x128 = $4,
+			fee_growth_inside1_last_x128 = $5,
+			updated_block = $6,
+			updated_at = NOW()
+		WHERE id = $1
+	`, positionID.String(), tokensOwed0.String(), tokensOwed1.String(),
+		feeGrowth0.String(), feeGrowth1.String(), blockNumber)
+	if err != nil {
+		return fmt.Errorf("更新 position %s 的 tokens_owed 失败: %w", positionID.String(), err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		log.Printf("Position %s not found in database, tokens_owed not updated", positionID.String())
+		return nil
+	}
+	log.Printf("✅ Refreshed position %s at block %d: tokensOwed0=%s, tokensOwed1=%s",
+		positionID.String(), blockNumber, tokensOwed0.String(), tokensOwed1.String())
+	return nil
+}
+
 // createPositionFromPoolMint 从 Pool Mint 事件创建 position 记录（没有 NFT position ID 的情况）
 // 使用 owner + pool + tick 的哈希值作为 position ID
-func (s *Scanner) createPositionFromPoolMint(owner common.Address, poolAddr common.Address, liquidity *big.Int, blockNumber uint64) {
+func (s *Scanner) createPositionFromPoolMint(owner common.Address, poolAddr common.Address, liquidity *big.Int, blockNumber uint64) error {
 	// 查询 Pool 信息获取 token0、token1 和 tick 范围
 	var token0, token1 string
 	var tickLower, tickUpper int
-	err := s.DB.QueryRow(`
+	err := s.db().QueryRow(`
 		SELECT token0, token1, tick_lower, tick_upper FROM pools WHERE address = $1
 	`, poolAddr.Hex()).Scan(&token0, &token1, &tickLower, &tickUpper)
 	if err != nil {
-		log.Printf("Error querying pool info: %v", err)
-		return
+		return fmt.Errorf("查询池子 %s 信息失败: %w", poolAddr.Hex(), err)
 	}
 
-	// 生成 position ID：使用 owner + pool + tick 的哈希值
-	// 转换为数字，确保唯一性
-	hashInput := fmt.Sprintf("%s:%s:%d:%d", owner.Hex(), poolAddr.Hex(), tickLower, tickUpper)
-	hash := crypto.Keccak256Hash([]byte(hashInput))
-	positionID := new(big.Int).SetBytes(hash.Bytes())
-	// 取前 64 位作为 ID（避免过大）
-	positionID.Mod(positionID, new(big.Int).Lsh(big.NewInt(1), 64))
+	positionID := virtualPositionID(owner, poolAddr, tickLower, tickUpper)
 
 	// 创建或更新 position 记录
-	result, err := s.DB.Exec(`
+	result, err := s.db().Exec(`
 		INSERT INTO positions (
 			id, owner, pool_address, token0, token1, 
 			tick_lower, tick_upper, liquidity, 
 			fee_growth_inside0_last_x128, fee_growth_inside1_last_x128,
-			tokens_owed0, tokens_owed1
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 0, 0, 0, 0)
+			tokens_owed0, tokens_owed1, created_block, updated_block
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 0, 0, 0, 0, $9, $9)
 		ON CONFLICT (id) DO UPDATE SET
 			liquidity = positions.liquidity + $8,
+			updated_block = $9,
 			updated_at = NOW()
 	`, positionID.String(), owner.Hex(), poolAddr.Hex(), token0, token1,
-		tickLower, tickUpper, liquidity.String())
+		tickLower, tickUpper, liquidity.String(), blockNumber)
 
 	if err != nil {
-		log.Printf("Error upserting position (from Pool Mint): %v", err)
-	} else {
-		rowsAffected, _ := result.RowsAffected()
-		log.Printf("Successfully upserted position %s (owner=%s, pool=%s, liquidity=%s, rowsAffected=%d)",
-			positionID.String(), owner.Hex(), poolAddr.Hex(), liquidity.String(), rowsAffected)
+		return fmt.Errorf("写入 position %s 失败 (from Pool Mint): %w", positionID.String(), err)
 	}
+	rowsAffected, _ := result.RowsAffected()
+	log.Printf("Successfully upserted position %s (owner=%s, pool=%s, liquidity=%s, rowsAffected=%d)",
+		positionID.String(), owner.Hex(), poolAddr.Hex(), liquidity.String(), rowsAffected)
+	return nil
 }
 
 // queryPositionFromContract 通过 RPC 调用 PositionManager 合约查询 position 信息
@@ -193,7 +304,7 @@ func (s *Scanner) queryPositionFromContract(positionID *big.Int, blockNumber uin
 
 // updatePositionFromMint 更新或创建 position 记录（有 NFT position ID 的情况）
 // 通过 RPC 调用 PositionManager.positions(positionID) 获取准确的 tick 范围
-func (s *Scanner) updatePositionFromMint(positionID big.Int, owner common.Address, poolAddr common.Address, liquidity *big.Int, blockNumber uint64) {
+func (s *Scanner) updatePositionFromMint(positionID big.Int, owner common.Address, poolAddr common.Address, liquidity *big.Int, blockNumber uint64) error {
 	// 尝试通过 RPC 查询 PositionManager 获取 position 信息
 	positionInfo, err := s.queryPositionFromContract(&positionID, blockNumber)
 	if err != nil {
@@ -221,21 +332,19 @@ func (s *Scanner) updatePositionFromMint(positionID big.Int, owner common.Addres
 			positionID.String(), tickLower, tickUpper, liquidity.String())
 	} else {
 		// 回退：从数据库查询 Pool 信息
-		err := s.DB.QueryRow(`
+		err := s.db().QueryRow(`
 			SELECT token0, token1 FROM pools WHERE address = $1
 		`, poolAddr.Hex()).Scan(&token0, &token1)
 		if err != nil {
-			log.Printf("Error querying pool info: %v", err)
-			return
+			return fmt.Errorf("查询池子 %s 信息失败: %w", poolAddr.Hex(), err)
 		}
 
 		// 查询 Pool 的 tick_lower 和 tick_upper（这是池子的整体范围，不是 position 的范围）
-		err = s.DB.QueryRow(`
+		err = s.db().QueryRow(`
 			SELECT tick_lower, tick_upper FROM pools WHERE address = $1
 		`, poolAddr.Hex()).Scan(&tickLower, &tickUpper)
 		if err != nil {
-			log.Printf("Error querying pool ticks: %v", err)
-			return
+			return fmt.Errorf("查询池子 %s 的 tick 范围失败: %w", poolAddr.Hex(), err)
 		}
 		log.Printf("Using pool tick range for position %s: tickLower=%d, tickUpper=%d (fallback)",
 			positionID.String(), tickLower, tickUpper)
@@ -243,34 +352,56 @@ func (s *Scanner) updatePositionFromMint(positionID big.Int, owner common.Addres
 
 	// 尝试更新或插入 position
 	// 如果是新创建的 position，则插入；如果是增加流动性，则更新
-	result, err := s.DB.Exec(`
+	result, err := s.db().Exec(`
 		INSERT INTO positions (
 			id, owner, pool_address, token0, token1, 
 			tick_lower, tick_upper, liquidity, 
 			fee_growth_inside0_last_x128, fee_growth_inside1_last_x128,
-			tokens_owed0, tokens_owed1
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 0, 0, 0, 0)
+			tokens_owed0, tokens_owed1, created_block, updated_block
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 0, 0, 0, 0, $9, $9)
 		ON CONFLICT (id) DO UPDATE SET
 			liquidity = positions.liquidity + $8,
+			updated_block = $9,
 			updated_at = NOW()
 	`, positionID.String(), owner.Hex(), poolAddr.Hex(), token0, token1,
-		tickLower, tickUpper, liquidity.String())
+		tickLower, tickUpper, liquidity.String(), blockNumber)
 
 	if err != nil {
-		log.Printf("Error upserting position %s: %v", positionID.String(), err)
-	} else {
-		rowsAffected, _ := result.RowsAffected()
-		log.Printf("Successfully upserted position %s (owner=%s, pool=%s, liquidity=%s, rowsAffected=%d)",
-			positionID.String(), owner.Hex(), poolAddr.Hex(), liquidity.String(), rowsAffected)
+		return fmt.Errorf("写入 position %s 失败: %w", positionID.String(), err)
 	}
+	rowsAffected, _ := result.RowsAffected()
+	log.Printf("Successfully upserted position %s (owner=%s, pool=%s, liquidity=%s, rowsAffected=%d)",
+		positionID.String(), owner.Hex(), poolAddr.Hex(), liquidity.String(), rowsAffected)
+	return nil
 }
 
 // updatePositionFromBurn 更新 position 记录（减少流动性）
-func (s *Scanner) updatePositionFromBurn(owner common.Address, poolAddr common.Address, liquidity *big.Int, blockNumber uint64, txHash common.Hash) {
+// positionID 为 resolvePositionID 解析出的 position ID，为 nil 时按下面的方法查找
+func (s *Scanner) updatePositionFromBurn(positionID *big.Int, owner common.Address, poolAddr common.Address, liquidity *big.Int, blockNumber uint64, txHash common.Hash) error {
 	// 对于 Burn，owner 通常是 PositionManager 合约地址
 	// 我们需要找到该池子中属于某个 position 的记录
 	// 由于 Burn 事件没有 position ID，我们需要通过其他方式关联
 
+	// 方法0: 已从交易 calldata 或虚拟 position 规则确定了 position ID
+	if positionID != nil {
+		result, err := s.db().Exec(`
+			UPDATE positions 
+			SET liquidity = GREATEST(0, liquidity - $1),
+				updated_block = $4,
+				updated_at = NOW()
+			WHERE id = $2 AND pool_address = $3
+		`, liquidity.String(), positionID.String(), poolAddr.Hex(), blockNumber)
+		if err != nil {
+			return fmt.Errorf("扣减 position %s 的流动性失败: %w", positionID.String(), err)
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+			log.Printf("Successfully updated position %s: reduced liquidity by %s",
+				positionID.String(), liquidity.String())
+			return nil
+		}
+		log.Printf("Resolved position %s not found in pool %s, falling back to matching", positionID.String(), poolAddr.Hex())
+	}
+
 	positionManagerAddr := common.HexToAddress(s.Config.Contracts.PositionManager)
 
 	// 方法1: 尝试从同一交易中查找 PositionManager 的 Transfer 事件（burn，to = 0x0）
@@ -289,19 +420,19 @@ func (s *Scanner) updatePositionFromBurn(owner common.Address, poolAddr common.A
 							txHash.Hex(), positionID.String())
 
 						// 更新对应的 position
-						_, err := s.DB.Exec(`
+						_, err := s.db().Exec(`
 							UPDATE positions 
 							SET liquidity = GREATEST(0, liquidity - $1),
+								updated_block = $4,
 								updated_at = NOW()
 							WHERE id = $2 AND pool_address = $3
-						`, liquidity.String(), positionID.String(), poolAddr.Hex())
+						`, liquidity.String(), positionID.String(), poolAddr.Hex(), blockNumber)
 						if err != nil {
-							log.Printf("Error updating position %s on burn: %v", positionID.String(), err)
-						} else {
-							log.Printf("Successfully updated position %s: reduced liquidity by %s",
-								positionID.String(), liquidity.String())
+							return fmt.Errorf("扣减 position %s 的流动性失败: %w", positionID.String(), err)
 						}
-						return // 找到了 position ID，直接返回
+						log.Printf("Successfully updated position %s: reduced liquidity by %s",
+							positionID.String(), liquidity.String())
+						return nil // 找到了 position ID，直接返回
 					}
 				}
 			}
@@ -312,14 +443,13 @@ func (s *Scanner) updatePositionFromBurn(owner common.Address, poolAddr common.A
 	// 或者 NFT 还没有被销毁（因为 collect 还没调用）
 	// 查询数据库中该池子的所有 position，找到流动性匹配的进行更新
 	// 注意：这种方法不够精确，因为可能有多个 position 有相同的流动性
-	rows, err := s.DB.Query(`
+	rows, err := s.db().Query(`
 		SELECT id, liquidity FROM positions 
 		WHERE pool_address = $1 AND liquidity > 0
 		ORDER BY liquidity DESC
 	`, poolAddr.Hex())
 	if err != nil {
-		log.Printf("Error querying positions for pool %s: %v", poolAddr.Hex(), err)
-		return
+		return fmt.Errorf("查询池子 %s 的 positions 失败: %w", poolAddr.Hex(), err)
 	}
 	defer rows.Close()
 
@@ -346,26 +476,29 @@ func (s *Scanner) updatePositionFromBurn(owner common.Address, poolAddr common.A
 			break // 找到第一个匹配的
 		}
 	}
+	// 同一个事务中结果集未关闭时不能执行下一条语句
+	rows.Close()
 
 	if matchedPositionID != nil {
 		// 更新找到的 position
-		_, err := s.DB.Exec(`
+		_, err := s.db().Exec(`
 			UPDATE positions 
 			SET liquidity = GREATEST(0, liquidity - $1),
+				updated_block = $4,
 				updated_at = NOW()
 			WHERE id = $2 AND pool_address = $3
-		`, liquidity.String(), matchedPositionID.String(), poolAddr.Hex())
+		`, liquidity.String(), matchedPositionID.String(), poolAddr.Hex(), blockNumber)
 		if err != nil {
-			log.Printf("Error updating position %s on burn (matched by liquidity): %v",
+			return fmt.Errorf("扣减 position %s 的流动性失败 (matched by liquidity): %w",
 				matchedPositionID.String(), err)
-		} else {
-			log.Printf("Successfully updated position %s (matched by liquidity %s): reduced by %s",
-				matchedPositionID.String(), matchedLiquidity.String(), liquidity.String())
 		}
+		log.Printf("Successfully updated position %s (matched by liquidity %s): reduced by %s",
+			matchedPositionID.String(), matchedLiquidity.String(), liquidity.String())
 	} else {
 		// 如果找不到匹配的 position，可能是虚拟 position（没有 NFT）
 		// 或者流动性已经被其他事件更新了
 		log.Printf("No matching position found for burn: pool=%s, liquidity=%s, tx=%s",
 			poolAddr.Hex(), liquidity.String(), txHash.Hex())
 	}
+	return nil
 }
@@ -0,0 +1,488 @@
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// maxReorgDepth 回溯查找共同祖先的最大深度，blocks 表也只保留最近这么多个区块的哈希
+const maxReorgDepth = 128
+
+// getStoredBlockHash 查询 blocks 表中记录的区块哈希，没有记录时 ok 为 false
+func (s *Scanner) getStoredBlockHash(number uint64) (common.Hash, bool, error) {
+	var hash string
+	err := s.DB.QueryRow(
+		"SELECT hash FROM blocks WHERE network = $1 AND number = $2",
+		getNetworkFromURL(rpcURLs(s.Config)[0]), number,
+	).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return common.Hash{}, false, nil
+	}
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+	return common.HexToHash(hash), true, nil
+}
+
+// detectReorg 检查上一个已处理区块（s.Current - 1）在链上的哈希是否与记录一致
+// 没有记录时（首次启动或升级前扫描的区块）无法判断，视为没有 reorg
+func (s *Scanner) detectReorg() (bool, error) {
+	if s.Current == 0 {
+		return false, nil
+	}
+	last := s.Current - 1
+
+	stored, ok, err := s.getStoredBlockHash(last)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	header, err := s.Client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(last))
+	if err != nil {
+		return false, fmt.Errorf("获取区块 %d 的区块头失败: %w", last, err)
+	}
+	if header.Hash() == stored {
+		return false, nil
+	}
+
+	log.Printf("⚠️  Reorg detected at block %d: stored hash=%s, chain hash=%s",
+		last, stored.Hex(), header.Hash().Hex())
+	return true, nil
+}
+
+// fetchHeaders 获取 [start, end] 范围内的区块头，并检查父哈希是否连续
+// 第一个区块的父哈希与 blocks 表中 start - 1 的记录不一致时返回错误，下一轮由 detectReorg 处理
+func (s *Scanner) fetchHeaders(start, end uint64) ([]*types.Header, error) {
+	numbers := make([]uint64, 0, end-start+1)
+	for n := start; n <= end; n++ {
+		numbers = append(numbers, n)
+	}
+	headers, err := s.headersByNumber(context.Background(), numbers)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(headers); i++ {
+		if headers[i].ParentHash != headers[i-1].Hash() {
+			return nil, fmt.Errorf("区块 %d 的父哈希与区块 %d 不一致，扫描过程中发生了 reorg", numbers[i], numbers[i-1])
+		}
+	}
+
+	if start > 0 {
+		stored, ok, err := s.getStoredBlockHash(start - 1)
+		if err != nil {
+			return nil, err
+		}
+		if ok && headers[0].ParentHash != stored {
+			return nil, fmt.Errorf("区块 %d 的父哈希与已记录的区块 %d 哈希不一致，可能发生了 reorg", start, start-1)
+		}
+	}
+	// 事件的区块时间直接使用这批区块头
+	s.headers.put(headers)
+	return headers, nil
+}
+
+// saveBlockHashes 记录已处理区块的哈希，并清理超出 maxReorgDepth 的旧记录
+// 与区块范围的事件写入在同一个事务中执行
+func (s *Scanner) saveBlockHashes(headers []*types.Header) error {
+	if len(headers) == 0 {
+		return nil
+	}
+	network := getNetworkFromURL(rpcURLs(s.Config)[0])
+
+	for _, header := range headers {
+		_, err := s.db().Exec(
+			`INSERT INTO blocks (network, number, hash, parent_hash, created_at)
+			 VALUES ($1, $2, $3, $4, NOW())
+			 ON CONFLICT (network, number)
+			 DO UPDATE SET hash = $3, parent_hash = $4, created_at = NOW()`,
+			network, header.Number.Uint64(), header.Hash().Hex(), header.ParentHash.Hex(),
+		)
+		if err != nil {
+			return fmt.Errorf("记录区块 %d 的哈希失败: %w", header.Number.Uint64(), err)
+		}
+	}
+
+	last := headers[len(headers)-1].Number.Uint64()
+	if last > maxReorgDepth {
+		if _, err := s.db().Exec(
+			"DELETE FROM blocks WHERE network = $1 AND number < $2",
+			network, last-maxReorgDepth,
+		); err != nil {
+			return fmt.Errorf("清理旧区块哈希失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// findCommonAncestor 从 from 开始向前查找本地记录与链上哈希一致的最高区块
+func (s *Scanner) findCommonAncestor(from uint64) (uint64, error) {
+	for n := from; ; n-- {
+		stored, ok, err := s.getStoredBlockHash(n)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, fmt.Errorf("回溯到区块 %d 仍未找到共同祖先，超出了已记录的区块哈希范围", n)
+		}
+
+		header, err := s.Client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(n))
+		if err != nil {
+			return 0, fmt.Errorf("获取区块 %d 的区块头失败: %w", n, err)
+		}
+		if header.Hash() == stored {
+			return n, nil
+		}
+
+		if n == 0 || from-n >= maxReorgDepth {
+			return 0, fmt.Errorf("reorg 深度超过 %d 个区块，无法自动回滚", maxReorgDepth)
+		}
+	}
+}
+
+// handleReorg 找到共同祖先，回滚孤块写入的数据，并从共同祖先的下一个区块重新扫描
+func (s *Scanner) handleReorg() error {
+	ancestor, err := s.findCommonAncestor(s.Current - 1)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Rolling back from block %d to common ancestor %d", s.Current-1, ancestor)
+	if err := s.rollbackToBlock(ancestor); err != nil {
+		return fmt.Errorf("回滚到区块 %d 失败: %w", ancestor, err)
+	}
+
+	s.Current = ancestor + 1
+	s.headers.reset()
+	log.Printf("✅ Rollback completed, rescanning from block %d", s.Current)
+	return nil
+}
+
+// rollbackToBlock 删除 ancestor 之后的区块写入的数据，并把派生状态恢复到 ancestor
+//
+// swaps、liquidity_events 按 block_number 删除；ancestor 之后创建的 pools、positions 整行删除；
+// ticks 由剩余的 liquidity_events 重新累加；pools 的价格和流动性、ancestor 之后更新过的 NFT position
+// 按 ancestor 区块高度从链上重新查询，在同一个事务中写入。任何一步失败（包括链上查询）都回滚整个事务，
+// handleReorg 返回错误，下一轮重新检测并回滚
+func (s *Scanner) rollbackToBlock(ancestor uint64) error {
+	network := getNetworkFromURL(rpcURLs(s.Config)[0])
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// 恢复池子状态时 applyPoolState 等通过 db() 写入，走同一个事务
+	s.tx = tx
+	defer func() {
+		s.tx = nil
+	}()
+
+	// 1. 收集需要恢复状态的池子和 position（必须在删除之前查询）
+	affectedPools, err := queryStrings(tx, `
+		SELECT pool_address FROM swaps WHERE block_number > $1
+		UNION
+		SELECT pool_address FROM liquidity_events WHERE block_number > $1
+	`, ancestor)
+	if err != nil {
+		return fmt.Errorf("查询受影响的池子失败: %w", err)
+	}
+
+	orphanPools, err := queryStrings(tx, "SELECT address FROM pools WHERE created_block > $1", ancestor)
+	if err != nil {
+		return fmt.Errorf("查询孤块中创建的池子失败: %w", err)
+	}
+
+	stalePositions, err := queryStrings(tx, `
+		SELECT id::text FROM positions
+		WHERE updated_block > $1 AND (created_block IS NULL OR created_block <= $1)
+	`, ancestor)
+	if err != nil {
+		return fmt.Errorf("查询受影响的 position 失败: %w", err)
+	}
+
+	candlesSince, err := queryOrphanSwapStarts(tx, ancestor)
+	if err != nil {
+		return fmt.Errorf("查询受影响的 K 线失败: %w", err)
+	}
+
+	// 2. 删除孤块中的事件记录
+	result, err := tx.Exec("DELETE FROM swaps WHERE block_number > $1", ancestor)
+	if err != nil {
+		return fmt.Errorf("删除 swaps 失败: %w", err)
+	}
+	swapsDeleted, _ := result.RowsAffected()
+
+	result, err = tx.Exec("DELETE FROM liquidity_events WHERE block_number > $1", ancestor)
+	if err != nil {
+		return fmt.Errorf("删除 liquidity_events 失败: %w", err)
+	}
+	eventsDeleted, _ := result.RowsAffected()
+
+	if _, err := tx.Exec("DELETE FROM position_transfers WHERE block_number > $1", ancestor); err != nil {
+		return fmt.Errorf("删除 position_transfers 失败: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM collects WHERE block_number > $1", ancestor); err != nil {
+		return fmt.Errorf("删除 collects 失败: %w", err)
+	}
+
+	// 3. 删除孤块中创建的 position 和池子（先删除引用池子的行）
+	result, err = tx.Exec(`
+		DELETE FROM positions
+		WHERE created_block > $1
+		   OR pool_address IN (SELECT address FROM pools WHERE created_block > $1)
+	`, ancestor)
+	if err != nil {
+		return fmt.Errorf("删除 positions 失败: %w", err)
+	}
+	positionsDeleted, _ := result.RowsAffected()
+
+	for _, stmt := range []string{
+		"DELETE FROM ticks WHERE pool_address IN (SELECT address FROM pools WHERE created_block > $1)",
+		"DELETE FROM candles WHERE pool_address IN (SELECT address FROM pools WHERE created_block > $1)",
+		"DELETE FROM pools WHERE created_block > $1",
+	} {
+		if _, err := tx.Exec(stmt, ancestor); err != nil {
+			return fmt.Errorf("删除孤块中创建的池子失败: %w", err)
+		}
+	}
+	// 通知 backend 从缓存中移除这些池子（随事务提交送达）
+	for _, addr := range orphanPools {
+		if err := markPoolChanged(tx, addr, ancestor); err != nil {
+			return err
+		}
+	}
+
+	// 4. 用剩余的 liquidity_events 重建受影响池子的 ticks，用剩余的 swaps 重建 K 线
+	orphanSet := make(map[string]bool, len(orphanPools))
+	for _, addr := range orphanPools {
+		orphanSet[addr] = true
+	}
+	var poolsToRefresh []string
+	for _, addr := range affectedPools {
+		if orphanSet[addr] {
+			continue
+		}
+		if err := rebuildTicksFromEvents(tx, addr); err != nil {
+			return fmt.Errorf("重建池子 %s 的 ticks 失败: %w", addr, err)
+		}
+		if since, ok := candlesSince[addr]; ok {
+			if err := rebuildCandlesSince(tx, addr, since); err != nil {
+				return fmt.Errorf("重建池子 %s 的 K 线失败: %w", addr, err)
+			}
+		}
+		poolsToRefresh = append(poolsToRefresh, addr)
+	}
+
+	// 5. 从链上读取 ancestor 时的池子和 position 状态，在同一个事务中写入（applyPoolState 同时发送池子变化通知）
+	if err := s.restorePoolStates(poolsToRefresh, ancestor); err != nil {
+		return err
+	}
+	for _, id := range stalePositions {
+		if err := s.restorePositionAtBlock(id, ancestor); err != nil {
+			return err
+		}
+	}
+
+	// 6. 删除孤块的哈希记录并回退扫描高度
+	if _, err := tx.Exec("DELETE FROM blocks WHERE network = $1 AND number > $2", network, ancestor); err != nil {
+		return fmt.Errorf("删除孤块哈希失败: %w", err)
+	}
+	if _, err := tx.Exec(
+		"UPDATE indexed_status SET last_block = $2, updated_at = NOW() WHERE network = $1",
+		network, ancestor,
+	); err != nil {
+		return fmt.Errorf("回退 indexed_status 失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Rolled back to block %d: deleted %d swaps, %d liquidity events, %d positions, %d pools, restored %d pools and %d positions",
+		ancestor, swapsDeleted, eventsDeleted, positionsDeleted, len(orphanPools), len(poolsToRefresh), len(stalePositions))
+
+	for _, addr := range orphanPools {
+		delete(s.Pools, common.HexToAddress(addr))
+	}
+	return nil
+}
+
+// restorePoolStates 按 ancestor 区块高度批量读取池子状态并写入当前事务
+// 批量请求失败或池子的 slot0、liquidity、feeGrowthGlobal 读取失败时返回错误，不写入部分恢复的状态
+func (s *Scanner) restorePoolStates(addrs []string, ancestor uint64) error {
+	poolAddrs := make([]common.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		poolAddrs = append(poolAddrs, common.HexToAddress(addr))
+	}
+	pools, pinned, err := s.readPoolStates(poolAddrs, new(big.Int).SetUint64(ancestor))
+	if err != nil {
+		return fmt.Errorf("读取区块 %d 的池子状态失败: %w", ancestor, err)
+	}
+	for _, p := range pools {
+		for _, call := range []*contractCall{p.slot0, p.liquidity, p.feeGrowth0, p.feeGrowth1} {
+			if call.err != nil {
+				return fmt.Errorf("读取池子 %s 在区块 %d 的 %s 失败: %w", p.pool.Hex(), ancestor, call.method, call.err)
+			}
+		}
+	}
+	for _, p := range pools {
+		s.applyPoolState(p, pinned.Uint64())
+	}
+	return nil
+}
+
+// rebuildTicksFromEvents 按 liquidity_events 中剩余的 MINT/BURN 重新计算池子边界 tick 的流动性
+// 与 updateTicksFromMint 一致，池子的全部流动性都在 tick_lower 到 tick_upper 之间
+func rebuildTicksFromEvents(tx *sql.Tx, poolAddr string) error {
+	var tickLower, tickUpper int
+	var netLiquidity string
+	err := tx.QueryRow(`
+		SELECT p.tick_lower, p.tick_upper,
+			GREATEST(0, COALESCE(SUM(CASE WHEN e.type = 'MINT' THEN e.amount ELSE -e.amount END), 0))::text
+		FROM pools p
+		LEFT JOIN liquidity_events e ON e.pool_address = p.address
+		WHERE p.address = $1
+		GROUP BY p.tick_lower, p.tick_upper
+	`, poolAddr).Scan(&tickLower, &tickUpper, &netLiquidity)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if netLiquidity == "0" {
+		_, err = tx.Exec("DELETE FROM ticks WHERE pool_address = $1", poolAddr)
+		return err
+	}
+
+	// 只覆盖流动性，保留 tick 初始化时记录的 fee_growth_outside（见 upsertTick）
+	_, err = tx.Exec(`
+		INSERT INTO ticks (
+			pool_address, tick_index, liquidity_gross, liquidity_net,
+			fee_growth_outside0_x128, fee_growth_outside1_x128
+		) VALUES ($1, $2, $4, $4, 0, 0), ($1, $3, $4, -$4::numeric, 0, 0)
+		ON CONFLICT (pool_address, tick_index) DO UPDATE SET
+			liquidity_gross = EXCLUDED.liquidity_gross,
+			liquidity_net = EXCLUDED.liquidity_net,
+			updated_at = NOW()
+	`, poolAddr, tickLower, tickUpper, netLiquidity)
+	return err
+}
+
+// restorePositionAtBlock 按指定区块高度从链上查询 position 并覆盖数据库记录（写入当前事务）：
+// NFT position 查询 PositionManager.positions(id)，虚拟 position（通过 TestLP 添加、没有 NFT）查询 Pool.positions(owner)。
+// 查询或写入失败时返回错误，回滚事务整体失败
+func (s *Scanner) restorePositionAtBlock(id string, blockNumber uint64) error {
+	positionID, ok := new(big.Int).SetString(id, 10)
+	if !ok {
+		return nil
+	}
+
+	var owner string
+	var poolAddr sql.NullString
+	var tickLower, tickUpper int
+	err := s.db().QueryRow(`
+		SELECT owner, pool_address, tick_lower, tick_upper FROM positions WHERE id = $1
+	`, id).Scan(&owner, &poolAddr, &tickLower, &tickUpper)
+	if err != nil {
+		return fmt.Errorf("查询 position %s 失败: %w", id, err)
+	}
+	if poolAddr.Valid && virtualPositionID(common.HexToAddress(owner), common.HexToAddress(poolAddr.String), tickLower, tickUpper).Cmp(positionID) == 0 {
+		return s.restoreVirtualPositionAtBlock(id, common.HexToAddress(owner), common.HexToAddress(poolAddr.String), blockNumber)
+	}
+
+	if s.Config.Contracts.PositionManager == "" {
+		log.Printf("⚠️  PositionManager address not configured, cannot restore position %s", id)
+		return nil
+	}
+
+	info, err := s.queryPositionFromContract(positionID, blockNumber)
+	if err != nil {
+		return fmt.Errorf("查询 position %s 在区块 %d 的状态失败: %w", id, blockNumber, err)
+	}
+	if info == nil || info.Owner == (common.Address{}) || info.Liquidity == nil {
+		log.Printf("⚠️  Cannot restore position %s at block %d from contract", id, blockNumber)
+		return nil
+	}
+
+	_, err = s.db().Exec(`
+		UPDATE positions SET
+			owner = $2,
+			liquidity = $3,
+			fee_growth_inside0_last_x128 = $4,
+			fee_growth_inside1_last_x128 = $5,
+			tokens_owed0 = $6,
+			tokens_owed1 = $7,
+			updated_block = $8,
+			updated_at = NOW()
+		WHERE id = $1
+	`, id, info.Owner.Hex(), info.Liquidity.String(),
+		info.FeeGrowthInside0LastX128.String(), info.FeeGrowthInside1LastX128.String(),
+		info.TokensOwed0.String(), info.TokensOwed1.String(), blockNumber)
+	if err != nil {
+		return fmt.Errorf("恢复 position %s 到区块 %d 失败: %w", id, blockNumber, err)
+	}
+	log.Printf("✅ Restored position %s to block %d (owner=%s, liquidity=%s)",
+		id, blockNumber, info.Owner.Hex(), info.Liquidity.String())
+	return nil
+}
+
+// restoreVirtualPositionAtBlock 按指定区块高度读取 Pool.positions(owner)，覆盖虚拟 position 的流动性、手续费增长和 tokensOwed
+func (s *Scanner) restoreVirtualPositionAtBlock(id string, owner, poolAddr common.Address, blockNumber uint64) error {
+	position, err := s.queryPoolPosition(poolAddr, owner, blockNumber)
+	if err != nil {
+		return fmt.Errorf("查询虚拟 position %s 在区块 %d 的状态失败: %w", id, blockNumber, err)
+	}
+	if position.Liquidity == nil || position.FeeGrowthInside0LastX128 == nil || position.FeeGrowthInside1LastX128 == nil ||
+		position.TokensOwed0 == nil || position.TokensOwed1 == nil {
+		return fmt.Errorf("虚拟 position %s 在区块 %d 的链上数据不完整", id, blockNumber)
+	}
+
+	_, err = s.db().Exec(`
+		UPDATE positions SET
+			liquidity = $2,
+			fee_growth_inside0_last_x128 = $3,
+			fee_growth_inside1_last_x128 = $4,
+			tokens_owed0 = $5,
+			tokens_owed1 = $6,
+			updated_block = $7,
+			updated_at = NOW()
+		WHERE id = $1
+	`, id, position.Liquidity.String(),
+		position.FeeGrowthInside0LastX128.String(), position.FeeGrowthInside1LastX128.String(),
+		position.TokensOwed0.String(), position.TokensOwed1.String(), blockNumber)
+	if err != nil {
+		return fmt.Errorf("恢复虚拟 position %s 到区块 %d 失败: %w", id, blockNumber, err)
+	}
+	log.Printf("✅ Restored virtual position %s to block %d (owner=%s, liquidity=%s)",
+		id, blockNumber, owner.Hex(), position.Liquidity.String())
+	return nil
+}
+
+// queryStrings 执行只返回一列文本的查询
+func queryStrings(tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v sql.NullString
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		if v.Valid {
+			values = append(values, v.String)
+		}
+	}
+	return values, rows.Err()
+}
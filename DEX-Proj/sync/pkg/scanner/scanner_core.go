@@ -7,20 +7,24 @@ import (
 	"log"
 	"math/big"
 	"meta-node-dex-sync/pkg/config"
+	"meta-node-dex-sync/pkg/rpcclient"
 	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
 // NewScanner 创建并初始化 Scanner 实例
 func NewScanner(config config.Config, db *sql.DB) (*Scanner, error) {
-	client, err := ethclient.Dial(config.RPC.Url)
+	client, err := rpcclient.Dial(rpcURLs(config), rpcclient.Options{
+		MaxRetries:   config.RPC.MaxRetries,
+		MaxLagBlocks: config.RPC.MaxLagBlocks,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to infura: %v", err)
+		return nil, fmt.Errorf("failed to connect to RPC: %v", err)
 	}
 
 	// 解析 PositionManager ABI（用于查询 positions mapping）
@@ -60,6 +64,11 @@ func NewScanner(config config.Config, db *sql.DB) (*Scanner, error) {
 		Pools:              make(map[common.Address]bool),
 		Current:            uint64(config.RPC.StartBlock),
 		positionManagerABI: positionManagerABI,
+		sizer:              newRangeSizer(config),
+		headers:            newHeaderCache(),
+	}
+	if config.RPC.WsUrl != "" {
+		scanner.live = newLiveFeed(config.RPC.WsUrl)
 	}
 
 	// Log event signatures for debugging
@@ -68,27 +77,19 @@ func NewScanner(config config.Config, db *sql.DB) (*Scanner, error) {
 	log.Printf("  Swap: %s", SigSwap.Hex())
 	log.Printf("  Mint: %s", SigMint.Hex())
 	log.Printf("  Burn: %s", SigBurn.Hex())
+	log.Printf("  Collect: %s", SigCollect.Hex())
 	log.Printf("  Transfer: %s", SigTransfer.Hex())
 	log.Printf("PoolManager address: %s", config.Contracts.PoolManager)
+	scanner.multicall = scanner.detectMulticall()
 
 	// Load existing pools from DB
-	rows, err := db.Query("SELECT address FROM pools")
-	if err != nil {
-		return nil, fmt.Errorf("failed to load pools: %v", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var addr string
-		if err := rows.Scan(&addr); err != nil {
-			continue
-		}
-		scanner.Pools[common.HexToAddress(addr)] = true
+	if err := scanner.loadPools(); err != nil {
+		return nil, err
 	}
 	log.Printf("Loaded %d pools from database", len(scanner.Pools))
 
 	// 从 indexed_status 表查询扫描高度
-	network := getNetworkFromURL(config.RPC.Url)
+	network := getNetworkFromURL(rpcURLs(config)[0])
 	var lastBlock sql.NullInt64
 	err = db.QueryRow("SELECT last_block FROM indexed_status WHERE network = $1", network).Scan(&lastBlock)
 	if err == nil && lastBlock.Valid {
@@ -106,6 +107,14 @@ func NewScanner(config config.Config, db *sql.DB) (*Scanner, error) {
 
 // Run 启动扫描器的主循环
 func (s *Scanner) Run() {
+	if addr := s.Config.RPC.StatusAddr; addr != "" {
+		go s.serveStatus(addr)
+	}
+	// 订阅新区块头和日志，新区块到达时立即扫描；订阅不可用时按 12 秒间隔轮询
+	if s.live != nil {
+		go s.runLiveFeed()
+	}
+
 	ticker := time.NewTicker(12 * time.Second)
 	defer ticker.Stop()
 
@@ -117,51 +126,200 @@ func (s *Scanner) Run() {
 			continue
 		}
 
+		// 每轮先检查上一个已处理区块的哈希，发现 reorg 时回滚到共同祖先后重新扫描
+		reorged, err := s.detectReorg()
+		if err != nil {
+			log.Printf("Failed to check for reorg: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		if reorged {
+			if err := s.handleReorg(); err != nil {
+				log.Printf("Failed to handle reorg: %v", err)
+				time.Sleep(5 * time.Second)
+			}
+			continue
+		}
+
+		// 只扫描到 最新区块 - Confirmations
 		latestBlock := header.Number.Uint64()
+		if latestBlock < s.Config.RPC.Confirmations {
+			latestBlock = 0
+		} else {
+			latestBlock -= s.Config.RPC.Confirmations
+		}
 		if s.Current > latestBlock {
-			log.Printf("Synced to head (%d). Waiting for new blocks...", latestBlock)
-			<-ticker.C
+			log.Printf("Synced to head (%d, confirmations=%d). Waiting for new blocks...",
+				latestBlock, s.Config.RPC.Confirmations)
+			s.waitForBlock(ticker)
+			continue
+		}
+
+		// 落后链头超过 maxReorgDepth 时，先并发回填不会再发生 reorg 的历史区块
+		if head := header.Number.Uint64(); head > maxReorgDepth && s.Current+maxReorgDepth < head {
+			target := head - maxReorgDepth
+			if target > latestBlock {
+				target = latestBlock
+			}
+			if err := s.backfill(target); err != nil {
+				log.Printf("Error backfilling: %v", err)
+				time.Sleep(5 * time.Second)
+			}
 			continue
 		}
 
 		// Sync in chunks
-		end := s.Current + 10
+		end := s.Current + headScanRange
 		if end > latestBlock {
 			end = latestBlock
 		}
 
 		log.Printf("Scanning range %d - %d", s.Current, end)
-		if err := s.scanRange(s.Current, end); err != nil {
+		headers, err := s.fetchHeaders(s.Current, end)
+		if err != nil {
+			log.Printf("Error fetching block headers: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		logs, err := s.rangeLogs(context.Background(), s.Current, end, headers)
+		if err != nil {
+			log.Printf("Error fetching logs: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		if err := s.processRange(s.Current, end, headers, logs); err != nil {
 			log.Printf("Error scanning range: %v", err)
 			time.Sleep(5 * time.Second)
 			continue
 		}
 
 		s.Current = end + 1
-		// 更新 indexed_status 表
-		if err := s.updateIndexedStatus(end); err != nil {
-			log.Printf("Failed to update indexed_status: %v", err)
+	}
+}
+
+// processRange 在一个数据库事务中处理区块范围：事件写入、区块哈希和 indexed_status 一起提交
+// 任何一步失败都会回滚整个范围，下一轮从同一个区块重新扫描；事件处理函数会跳过已存在的事件行，重复处理是安全的
+// headers 为范围内已获取的区块头（回填时只有最后一个区块），日志所在区块的哈希与区块头不一致时说明扫描过程中发生了 reorg，整个范围都不处理
+func (s *Scanner) processRange(start, end uint64, headers []*types.Header, logs []types.Log) error {
+	if err := checkLogs(start, end, headers, logs); err != nil {
+		return err
+	}
+	// 一次批量请求获取范围内所有事件所在区块的区块头，事件处理时从缓存读取区块时间
+	if err := s.prefetchHeaders(context.Background(), logs); err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	s.tx = tx
+	defer func() {
+		s.tx = nil
+	}()
+
+	if err := s.scanRange(start, end, logs); err != nil {
+		s.rollbackRange(tx)
+		return err
+	}
+
+	// 记录已处理区块的哈希，用于下一轮检查 reorg
+	if err := s.saveBlockHashes(headers); err != nil {
+		s.rollbackRange(tx)
+		return fmt.Errorf("记录区块哈希失败: %w", err)
+	}
+
+	// 更新 indexed_status 表
+	if err := s.updateIndexedStatus(end); err != nil {
+		s.rollbackRange(tx)
+		return fmt.Errorf("更新 indexed_status 失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.rollbackRange(nil)
+		return fmt.Errorf("提交区块范围 %d-%d 失败: %w", start, end, err)
+	}
+	s.headers.pruneThrough(end)
+	if s.live != nil {
+		s.live.pruneThrough(end)
+	}
+	return nil
+}
+
+// rollbackRange 回滚区块范围的事务，并从数据库重新加载池子缓存（事务中新增的池子已经不存在）
+func (s *Scanner) rollbackRange(tx *sql.Tx) {
+	if tx != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Printf("Failed to rollback range transaction: %v", err)
+		}
+	}
+	s.tx = nil
+	if err := s.loadPools(); err != nil {
+		log.Printf("Failed to reload pool cache: %v", err)
+	}
+}
+
+// loadPools 从数据库加载已知池子到缓存
+func (s *Scanner) loadPools() error {
+	rows, err := s.DB.Query("SELECT address FROM pools")
+	if err != nil {
+		return fmt.Errorf("failed to load pools: %v", err)
+	}
+	defer rows.Close()
+
+	pools := make(map[common.Address]bool)
+	for rows.Next() {
+		var addr string
+		if err := rows.Scan(&addr); err != nil {
+			continue
 		}
+		pools[common.HexToAddress(addr)] = true
 	}
+	s.Pools = pools
+	return rows.Err()
 }
 
-// scanRange 扫描指定区块范围内的事件
-func (s *Scanner) scanRange(start, end uint64) error {
+// filterRangeLogs 获取指定区块范围内扫描器关心的所有事件日志
+func (s *Scanner) filterRangeLogs(ctx context.Context, start, end uint64) ([]types.Log, error) {
 	query := ethereum.FilterQuery{
 		FromBlock: big.NewInt(int64(start)),
 		ToBlock:   big.NewInt(int64(end)),
 	}
 
 	// 使用 Topics 过滤事件签名（高效的方式）
-	query.Topics = [][]common.Hash{
-		{SigPoolCreated, SigSwap, SigMint, SigBurn, SigTransfer},
+	query.Topics = logTopics()
+
+	return s.Client.FilterLogs(ctx, query)
+}
+
+// logTopics 扫描器关心的事件签名，FilterLogs 和日志订阅使用同一组过滤条件
+func logTopics() [][]common.Hash {
+	return [][]common.Hash{
+		{SigPoolCreated, SigSwap, SigMint, SigBurn, SigCollect, SigTransfer},
 	}
+}
 
-	logs, err := s.Client.FilterLogs(context.Background(), query)
-	if err != nil {
-		return err
+// checkLogs 检查日志都在 [start, end] 范围内，且所在区块的哈希与已获取的区块头一致
+func checkLogs(start, end uint64, headers []*types.Header, logs []types.Log) error {
+	hashes := make(map[uint64]common.Hash, len(headers))
+	for _, header := range headers {
+		hashes[header.Number.Uint64()] = header.Hash()
 	}
+	for _, vLog := range logs {
+		if vLog.Removed || vLog.BlockNumber < start || vLog.BlockNumber > end {
+			return fmt.Errorf("区块 %d 的日志已被移除或不在范围 %d-%d 内，扫描过程中发生了 reorg", vLog.BlockNumber, start, end)
+		}
+		if hash, ok := hashes[vLog.BlockNumber]; ok && vLog.BlockHash != hash {
+			return fmt.Errorf("区块 %d 的日志哈希与区块头不一致，扫描过程中发生了 reorg", vLog.BlockNumber)
+		}
+	}
+	return nil
+}
 
+// scanRange 按顺序处理指定区块范围内的事件日志
+func (s *Scanner) scanRange(start, end uint64, logs []types.Log) error {
 	log.Printf("Found %d logs in range %d-%d", len(logs), start, end)
 
 	// 统计各种事件类型
@@ -180,7 +338,9 @@ func (s *Scanner) scanRange(start, end uint64) error {
 			// Check if emitted by PoolManager (but also accept from any address for flexibility)
 			expectedAddr := common.HexToAddress(s.Config.Contracts.PoolManager)
 			if vLog.Address == expectedAddr || s.Config.Contracts.PoolManager == "" {
-				s.handlePoolCreated(vLog)
+				if err := s.handlePoolCreated(vLog); err != nil {
+					return err
+				}
 				eventCount++
 			} else {
 				// Still handle it, might be from a different deployment
@@ -198,12 +358,17 @@ func (s *Scanner) scanRange(start, end uint64) error {
 			}
 			// Verify pool exists in DB before processing
 			var exists bool
-			err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM pools WHERE address = $1)", vLog.Address.Hex()).Scan(&exists)
-			if err != nil || !exists {
+			err := s.db().QueryRow("SELECT EXISTS(SELECT 1 FROM pools WHERE address = $1)", vLog.Address.Hex()).Scan(&exists)
+			if err != nil {
+				return fmt.Errorf("检查池子 %s 是否存在失败: %w", vLog.Address.Hex(), err)
+			}
+			if !exists {
 				log.Printf("⚠️  Pool %s does not exist in database, skipping Swap event", vLog.Address.Hex())
 				continue
 			}
-			s.handleSwap(vLog)
+			if err := s.handleSwap(vLog); err != nil {
+				return err
+			}
 			eventCount++
 		case SigMint:
 			// If pool is unknown, try to add it
@@ -216,12 +381,17 @@ func (s *Scanner) scanRange(start, end uint64) error {
 			}
 			// Verify pool exists in DB before processing
 			var exists bool
-			err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM pools WHERE address = $1)", vLog.Address.Hex()).Scan(&exists)
-			if err != nil || !exists {
+			err := s.db().QueryRow("SELECT EXISTS(SELECT 1 FROM pools WHERE address = $1)", vLog.Address.Hex()).Scan(&exists)
+			if err != nil {
+				return fmt.Errorf("检查池子 %s 是否存在失败: %w", vLog.Address.Hex(), err)
+			}
+			if !exists {
 				log.Printf("⚠️  Pool %s does not exist in database, skipping Mint event", vLog.Address.Hex())
 				continue
 			}
-			s.handleMint(vLog)
+			if err := s.handleMint(vLog); err != nil {
+				return err
+			}
 			eventCount++
 		case SigBurn:
 			// If pool is unknown, try to add it
@@ -234,12 +404,28 @@ func (s *Scanner) scanRange(start, end uint64) error {
 			}
 			// Verify pool exists in DB before processing
 			var exists bool
-			err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM pools WHERE address = $1)", vLog.Address.Hex()).Scan(&exists)
-			if err != nil || !exists {
+			err := s.db().QueryRow("SELECT EXISTS(SELECT 1 FROM pools WHERE address = $1)", vLog.Address.Hex()).Scan(&exists)
+			if err != nil {
+				return fmt.Errorf("检查池子 %s 是否存在失败: %w", vLog.Address.Hex(), err)
+			}
+			if !exists {
 				log.Printf("⚠️  Pool %s does not exist in database, skipping Burn event", vLog.Address.Hex())
 				continue
 			}
-			s.handleBurn(vLog)
+			if err := s.handleBurn(vLog); err != nil {
+				return err
+			}
+			eventCount++
+		case SigCollect:
+			if !s.Pools[vLog.Address] {
+				if !s.ensurePoolExists(vLog.Address) {
+					log.Printf("⚠️  Skipping Collect event for unknown pool: %s", vLog.Address.Hex())
+					continue
+				}
+			}
+			if err := s.handleCollect(vLog); err != nil {
+				return err
+			}
 			eventCount++
 		case SigTransfer:
 			// Handle PositionManager NFT Transfer events (mint/burn)
@@ -247,7 +433,9 @@ func (s *Scanner) scanRange(start, end uint64) error {
 				transferCount++
 				log.Printf("Found PositionManager Transfer event: tx=%s, block=%d",
 					vLog.TxHash.Hex(), vLog.BlockNumber)
-				s.handlePositionTransfer(vLog)
+				if err := s.handlePositionTransfer(vLog); err != nil {
+					return err
+				}
 				eventCount++
 			}
 		}
@@ -264,6 +452,15 @@ func (s *Scanner) scanRange(start, end uint64) error {
 	return nil
 }
 
+// rpcURLs 返回配置的 RPC 节点：配置了 RPC.Urls 时使用 Urls，否则只使用 RPC.Url
+// 网络标识按第一个节点推断，所有节点应属于同一个网络
+func rpcURLs(cfg config.Config) []string {
+	if len(cfg.RPC.Urls) > 0 {
+		return cfg.RPC.Urls
+	}
+	return []string{cfg.RPC.Url}
+}
+
 // getNetworkFromURL 从 RPC URL 推断网络标识
 func getNetworkFromURL(url string) string {
 	urlLower := strings.ToLower(url)
@@ -286,8 +483,8 @@ func getNetworkFromURL(url string) string {
 
 // updateIndexedStatus 更新 indexed_status 表中的扫描高度
 func (s *Scanner) updateIndexedStatus(blockNumber uint64) error {
-	network := getNetworkFromURL(s.Config.RPC.Url)
-	_, err := s.DB.Exec(
+	network := getNetworkFromURL(rpcURLs(s.Config)[0])
+	_, err := s.db().Exec(
 		`INSERT INTO indexed_status (network, last_block, updated_at) 
 		 VALUES ($1, $2, NOW()) 
 		 ON CONFLICT (network) 
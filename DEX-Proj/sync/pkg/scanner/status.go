@@ -0,0 +1,37 @@
+package scanner
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"meta-node-dex-sync/pkg/rpcclient"
+)
+
+// endpointsResponse GET /rpc/endpoints 的响应
+type endpointsResponse struct {
+	Endpoints []rpcclient.EndpointState `json:"endpoints"`
+	// Subscription WebSocket 日志订阅的状态，未配置 RPC.WsUrl 时省略
+	Subscription *subscriptionState `json:"subscription,omitempty"`
+}
+
+// serveStatus 在 addr 上提供 RPC 节点状态接口 GET /rpc/endpoints，返回每个节点的状态、评分、延迟、错误率和区块高度，以及日志订阅的状态
+func (s *Scanner) serveStatus(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /rpc/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		resp := endpointsResponse{Endpoints: s.Client.Endpoints()}
+		if s.live != nil {
+			st := s.live.state()
+			resp.Subscription = &st
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("Error encoding RPC endpoint status: %v", err)
+		}
+	})
+
+	log.Printf("RPC status server listening on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("⚠️  RPC status server stopped: %v", err)
+	}
+}
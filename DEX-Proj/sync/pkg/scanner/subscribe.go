@@ -0,0 +1,323 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"meta-node-dex-sync/pkg/rpcclient"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// baseResubscribeDelay / maxResubscribeDelay 订阅断开后重新连接的等待时间：5s、10s、20s ...，最长 1 分钟
+	baseResubscribeDelay = 5 * time.Second
+	maxResubscribeDelay  = time.Minute
+	// headTimeout 超过该时间没有收到新区块头时认为连接已失效，重新订阅
+	headTimeout = 2 * time.Minute
+)
+
+// liveFeed 通过 WebSocket 订阅新区块头和事件日志：新区块头唤醒主循环，订阅到的日志按区块缓存，
+// 扫描这些区块时代替 FilterLogs。订阅建立之前和断开期间的区块不在缓存中，扫描时用 FilterLogs 补齐
+type liveFeed struct {
+	url   string
+	heads chan struct{} // 收到新区块头时发送，容量为 1，主循环等待新区块时读取
+
+	mu     sync.Mutex
+	active bool
+	since  uint64                 // 订阅建立后第一个完整缓存日志的区块
+	head   uint64                 // 订阅收到的最新区块号
+	logs   map[uint64][]types.Log // 按区块号缓存的日志
+}
+
+func newLiveFeed(url string) *liveFeed {
+	return &liveFeed{url: url, heads: make(chan struct{}, 1)}
+}
+
+// subscriptionState 订阅状态，由状态接口返回
+type subscriptionState struct {
+	URL    string `json:"url"`
+	Active bool   `json:"active"`
+	Since  uint64 `json:"since,omitempty"`
+	Head   uint64 `json:"head,omitempty"`
+}
+
+func (f *liveFeed) state() subscriptionState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := subscriptionState{URL: rpcclient.RedactURL(f.url), Active: f.active}
+	if f.active {
+		st.Since, st.Head = f.since, f.head
+	}
+	return st
+}
+
+// activate 订阅建立后调用，latest 之后区块的日志都会通过订阅收到
+func (f *liveFeed) activate(latest uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active = true
+	f.since = latest + 1
+	f.head = latest
+	f.logs = make(map[uint64][]types.Log)
+}
+
+// deactivate 订阅断开后调用，丢弃缓存的日志，主循环回到轮询
+func (f *liveFeed) deactivate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active = false
+	f.logs = nil
+}
+
+// observeHead 记录新区块并唤醒主循环，同时丢弃超出 maxReorgDepth 的旧日志
+func (f *liveFeed) observeHead(number uint64) {
+	f.mu.Lock()
+	if number > f.head {
+		f.head = number
+	}
+	for n := range f.logs {
+		if n+maxReorgDepth < f.head {
+			delete(f.logs, n)
+		}
+	}
+	f.mu.Unlock()
+
+	select {
+	case f.heads <- struct{}{}:
+	default:
+	}
+}
+
+// addLog 缓存订阅收到的日志；reorg 时节点重新发送 removed 为 true 的日志，从缓存中删除
+func (f *liveFeed) addLog(vLog types.Log) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.active || vLog.BlockNumber < f.since {
+		return
+	}
+
+	kept := f.logs[vLog.BlockNumber][:0]
+	for _, l := range f.logs[vLog.BlockNumber] {
+		if l.BlockHash != vLog.BlockHash || l.Index != vLog.Index {
+			kept = append(kept, l)
+		}
+	}
+	if !vLog.Removed {
+		kept = append(kept, vLog)
+	}
+	f.logs[vLog.BlockNumber] = kept
+}
+
+// cachedLogs 返回 [start, end] 中由订阅完整缓存的区块 [from, to] 的日志，from 之前的区块（订阅建立之前或断开期间）
+// 和 to 之后的区块需要 FilterLogs 补齐。newHeads 和 logs 是两个订阅，区块 N 的日志可能在区块头 N+1 之后才转发到，
+// 因此用区块头的 Bloom 检查缓存：Bloom 中没有关心的事件时缓存必须为空；有事件时缓存不能为空，
+// 且订阅收到的最新区块的前一个区块（只收到一个后续区块头）不使用缓存。
+// 只使用早于订阅收到的最新区块的区块；日志的区块哈希与 headers 不一致（reorg）或与 Bloom 矛盾时不使用缓存
+func (f *liveFeed) cachedLogs(start, end uint64, headers []*types.Header) ([]types.Log, uint64, uint64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.active || end >= f.head {
+		return nil, 0, 0, false
+	}
+	from := max(start, f.since)
+	if from > end {
+		return nil, 0, 0, false
+	}
+
+	byNumber := make(map[uint64]*types.Header, len(headers))
+	for _, header := range headers {
+		byNumber[header.Number.Uint64()] = header
+	}
+	var logs []types.Log
+	to := end
+	for n := from; n <= end; n++ {
+		header, ok := byNumber[n]
+		if !ok {
+			return nil, 0, 0, false
+		}
+		cached := f.logs[n]
+		expected := bloomHasTopics(header.Bloom)
+		if !expected && len(cached) > 0 {
+			return nil, 0, 0, false
+		}
+		if expected && (len(cached) == 0 || n+1 >= f.head) {
+			// 日志可能还没有全部转发到，从这个区块开始用 FilterLogs
+			if n == from {
+				return nil, 0, 0, false
+			}
+			to = n - 1
+			break
+		}
+		hash := header.Hash()
+		for _, l := range cached {
+			if l.BlockHash != hash {
+				return nil, 0, 0, false
+			}
+			logs = append(logs, l)
+		}
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+	return logs, from, to, true
+}
+
+// bloomHasTopics 判断区块的 Bloom 是否可能包含扫描器关心的事件（Bloom 可能误报，不会漏报）
+func bloomHasTopics(bloom types.Bloom) bool {
+	for _, topic := range logTopics()[0] {
+		if types.BloomLookup(bloom, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneThrough 删除 number 及之前的区块的日志
+func (f *liveFeed) pruneThrough(number uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for n := range f.logs {
+		if n <= number {
+			delete(f.logs, n)
+		}
+	}
+}
+
+// runLiveFeed 保持订阅，断开后按指数退避重新订阅；断开期间主循环按轮询间隔扫描。
+// 节点不支持订阅（例如 HTTP 地址）时只输出日志，之后一直使用轮询
+func (s *Scanner) runLiveFeed() {
+	delay := baseResubscribeDelay
+	for {
+		started := time.Now()
+		err := s.subscribe()
+		s.live.deactivate()
+		if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+			log.Printf("⚠️  %s does not support subscriptions, using polling", rpcclient.RedactURL(s.live.url))
+			return
+		}
+		// 订阅保持过一段时间后断开，从最短的等待时间开始重连
+		if time.Since(started) > maxResubscribeDelay {
+			delay = baseResubscribeDelay
+		}
+		log.Printf("⚠️  Subscription lost: %v, falling back to polling, resubscribing in %s", err, delay)
+		time.Sleep(delay)
+		delay = min(delay*2, maxResubscribeDelay)
+	}
+}
+
+// subscribe 建立 newHeads 和 logs 订阅并处理通知，直到订阅断开或长时间没有新区块
+func (s *Scanner) subscribe() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := ethclient.DialContext(ctx, s.live.url)
+	if err != nil {
+		return fmt.Errorf("连接 %s 失败: %w", rpcclient.RedactURL(s.live.url), err)
+	}
+	defer client.Close()
+
+	heads := make(chan *types.Header, 16)
+	headSub, err := client.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return fmt.Errorf("订阅 newHeads 失败: %w", err)
+	}
+	defer headSub.Unsubscribe()
+
+	logs := make(chan types.Log, 256)
+	logSub, err := client.SubscribeFilterLogs(ctx, ethereum.FilterQuery{Topics: logTopics()}, logs)
+	if err != nil {
+		return fmt.Errorf("订阅 logs 失败: %w", err)
+	}
+	defer logSub.Unsubscribe()
+
+	// 两个订阅都建立后再查询最新区块，之后的区块的日志都会通过订阅收到
+	latest, err := client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("获取最新区块号失败: %w", err)
+	}
+	s.live.activate(latest)
+	log.Printf("✅ Subscribed to new heads and logs via %s, caching logs from block %d", rpcclient.RedactURL(s.live.url), latest+1)
+
+	timer := time.NewTimer(headTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case err := <-headSub.Err():
+			return fmt.Errorf("newHeads 订阅断开: %w", err)
+		case err := <-logSub.Err():
+			return fmt.Errorf("logs 订阅断开: %w", err)
+		case <-timer.C:
+			return fmt.Errorf("%s 内没有收到新区块", headTimeout)
+		case header := <-heads:
+			// 节点在区块头 N+1 之前发送区块 N 的日志，先取出已经转发到的日志再唤醒主循环
+			drainLogs(s.live, logs)
+			s.live.observeHead(header.Number.Uint64())
+			timer.Reset(headTimeout)
+		case vLog := <-logs:
+			s.live.addLog(vLog)
+		}
+	}
+}
+
+// drainLogs 不阻塞地把 logs 中已经到达的日志加入缓存
+func drainLogs(f *liveFeed, logs <-chan types.Log) {
+	for {
+		select {
+		case vLog := <-logs:
+			f.addLog(vLog)
+		default:
+			return
+		}
+	}
+}
+
+// rangeLogs 获取链头扫描范围的日志：订阅已缓存的区块直接使用缓存，其余区块（订阅建立之前、断开期间或日志可能不完整的最新区块）用 FilterLogs 补齐
+func (s *Scanner) rangeLogs(ctx context.Context, start, end uint64, headers []*types.Header) ([]types.Log, error) {
+	if s.live == nil {
+		return s.filterRangeLogs(ctx, start, end)
+	}
+	cached, from, to, ok := s.live.cachedLogs(start, end, headers)
+	if !ok {
+		return s.filterRangeLogs(ctx, start, end)
+	}
+	var logs []types.Log
+	if from > start {
+		before, err := s.filterRangeLogs(ctx, start, from-1)
+		if err != nil {
+			return nil, err
+		}
+		logs = before
+	}
+	logs = append(logs, cached...)
+	if to < end {
+		after, err := s.filterRangeLogs(ctx, to+1, end)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, after...)
+	}
+	return logs, nil
+}
+
+// waitForBlock 等待新区块：订阅可用时收到新区块头立即返回，轮询间隔作为兜底
+func (s *Scanner) waitForBlock(ticker *time.Ticker) {
+	if s.live == nil {
+		<-ticker.C
+		return
+	}
+	select {
+	case <-ticker.C:
+	case <-s.live.heads:
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"database/sql"
 	"math/big"
 	"meta-node-dex-sync/pkg/config"
+	"meta-node-dex-sync/pkg/rpcclient"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 // PositionInfo 表示 PositionManager 合约中的 PositionInfo 结构体
@@ -30,13 +31,40 @@ type PositionInfo struct {
 
 // Scanner handles the blockchain scanning logic
 type Scanner struct {
-	Client  *ethclient.Client
+	Client  *rpcclient.Client
 	DB      *sql.DB
 	Config  config.Config
 	Pools   map[common.Address]bool // Cache of known pools
 	Current uint64                  // Current scan block
 	// PositionManager ABI for querying positions
 	positionManagerABI abi.ABI
+	// tx 当前区块范围的数据库事务，扫描期间所有写入都通过 db() 走这个事务
+	tx *sql.Tx
+	// sizer 回填历史区块时自适应调整 FilterLogs 的区块范围
+	sizer *rangeSizer
+	// headers 待写入区块的区块头缓存，事件的区块时间从这里读取
+	headers *headerCache
+	// multicall Multicall3 合约地址，链上没有部署时为 nil，批量读取改用 JSON-RPC 批量 eth_call
+	multicall *common.Address
+	// live WebSocket 日志订阅，未配置 RPC.WsUrl 时为 nil
+	live *liveFeed
+	// stateWarning 节点没有历史状态、改用最新区块时只输出一次警告
+	stateWarning sync.Once
+}
+
+// dbExecutor 是 *sql.DB 和 *sql.Tx 共有的查询方法
+type dbExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// db 返回当前使用的数据库执行器：扫描区块范围时为该范围的事务，否则为 s.DB
+func (s *Scanner) db() dbExecutor {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.DB
 }
 
 // Event Signatures - 所有事件签名的定义
@@ -54,6 +82,9 @@ var (
 	// Pool: Burn(address indexed owner, uint128 amount, uint256 amount0, uint256 amount1)
 	SigBurn = crypto.Keccak256Hash([]byte("Burn(address,uint128,uint256,uint256)"))
 
+	// Pool: Collect(address indexed owner, address recipient, uint128 amount0, uint128 amount1)
+	SigCollect = crypto.Keccak256Hash([]byte("Collect(address,address,uint128,uint128)"))
+
 	// ERC721 Transfer: Transfer(address indexed from, address indexed to, uint256 indexed tokenId)
 	SigTransfer = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
 )
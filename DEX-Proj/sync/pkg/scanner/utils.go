@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"meta-node-dex-sync/pkg/rpcclient"
+	"slices"
 	"strings"
 	"time"
 
@@ -48,111 +50,70 @@ var erc20ABI = `[
 
 // ensureToken 确保代币记录存在于数据库中，从 ERC20 合约读取 symbol、name 和 decimals
 func (s *Scanner) ensureToken(addr common.Address) {
+	s.ensureTokens(nil, addr)
+}
+
+// ensureTokens 为数据库中还不存在的代币批量读取 symbol、name 和 decimals 并插入，所有读取在同一个批量请求中、
+// 固定在 blockNumber 上（nil 表示最新区块）；读取失败的字段使用默认值 UNK / Unknown / 18
+func (s *Scanner) ensureTokens(blockNumber *big.Int, addrs ...common.Address) {
 	// 先检查数据库中是否已存在
-	var exists bool
-	err := s.DB.QueryRow(`
-		SELECT EXISTS(SELECT 1 FROM tokens WHERE address = $1)
-	`, addr.Hex()).Scan(&exists)
-	if err != nil {
-		log.Printf("Error checking token existence: %v", err)
-		return
+	var missing []common.Address
+	for _, addr := range addrs {
+		var exists bool
+		err := s.db().QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM tokens WHERE address = $1)
+		`, addr.Hex()).Scan(&exists)
+		if err != nil {
+			log.Printf("Error checking token existence: %v", err)
+			continue
+		}
+		if !exists && !slices.Contains(missing, addr) {
+			missing = append(missing, addr)
+		}
 	}
-	if exists {
-		// 代币已存在，跳过
+	if len(missing) == 0 {
+		// 代币都已存在，跳过
 		return
 	}
 
-	// 从 ERC20 合约读取信息
-	symbol := "UNK"
-	name := "Unknown"
-	decimals := int64(18)
-
-	parsedABI, err := abi.JSON(strings.NewReader(erc20ABI))
-	if err != nil {
-		log.Printf("Error parsing ERC20 ABI: %v", err)
-		// 使用默认值插入
-		s.insertToken(addr, symbol, name, decimals)
-		return
+	// 从 ERC20 合约读取信息，每个代币 3 个调用
+	calls := make([]*contractCall, 0, 3*len(missing))
+	for _, addr := range missing {
+		calls = append(calls,
+			newCall(addr, &parsedERC20ABI, "symbol"),
+			newCall(addr, &parsedERC20ABI, "name"),
+			newCall(addr, &parsedERC20ABI, "decimals"),
+		)
+	}
+	if _, err := s.batchCall(context.Background(), blockNumber, calls); err != nil {
+		log.Printf("Error reading ERC20 metadata for %d tokens: %v, using defaults", len(missing), err)
 	}
 
-	ctx := context.Background()
+	for i, addr := range missing {
+		symbol := "UNK"
+		name := "Unknown"
+		decimals := int64(18)
 
-	// 调用 symbol()
-	if symbolMethod, ok := parsedABI.Methods["symbol"]; ok {
-		data, err := parsedABI.Pack("symbol")
-		if err == nil {
-			result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-				To:   &addr,
-				Data: data,
-			}, nil)
-			if err == nil {
-				unpacked, err := symbolMethod.Outputs.Unpack(result)
-				if err == nil && len(unpacked) > 0 {
-					if s, ok := unpacked[0].(string); ok {
-						symbol = s
-					}
-				}
-			}
+		if v, err := calls[3*i].stringValue(0); err == nil {
+			symbol = v
 		}
-	}
-
-	// 调用 name()
-	if nameMethod, ok := parsedABI.Methods["name"]; ok {
-		data, err := parsedABI.Pack("name")
-		if err == nil {
-			result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-				To:   &addr,
-				Data: data,
-			}, nil)
-			if err == nil {
-				unpacked, err := nameMethod.Outputs.Unpack(result)
-				if err == nil && len(unpacked) > 0 {
-					if n, ok := unpacked[0].(string); ok {
-						name = n
-					}
-				}
-			}
+		if v, err := calls[3*i+1].stringValue(0); err == nil {
+			name = v
 		}
-	}
-
-	// 调用 decimals()
-	if decimalsMethod, ok := parsedABI.Methods["decimals"]; ok {
-		data, err := parsedABI.Pack("decimals")
-		if err == nil {
-			result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-				To:   &addr,
-				Data: data,
-			}, nil)
-			if err == nil {
-				unpacked, err := decimalsMethod.Outputs.Unpack(result)
-				if err == nil && len(unpacked) > 0 {
-					// decimals 返回 uint8
-					switch v := unpacked[0].(type) {
-					case uint8:
-						decimals = int64(v)
-					case uint16:
-						decimals = int64(v)
-					case uint32:
-						decimals = int64(v)
-					case uint64:
-						decimals = int64(v)
-					case *big.Int:
-						decimals = v.Int64()
-					default:
-						log.Printf("Unexpected decimals type for token %s: %T", addr.Hex(), v)
-					}
-				}
-			}
+		if v, err := calls[3*i+2].intValue(0); err == nil {
+			decimals = v
+		} else if calls[3*i+2].err == nil && len(calls[3*i+2].values) > 0 {
+			log.Printf("Unexpected decimals type for token %s: %v", addr.Hex(), err)
 		}
-	}
 
-	// 插入数据库
-	s.insertToken(addr, symbol, name, decimals)
+		// 插入数据库
+		s.insertToken(addr, symbol, name, decimals)
+	}
 }
 
 // insertToken 将代币信息插入数据库
 func (s *Scanner) insertToken(addr common.Address, symbol, name string, decimals int64) {
-	_, err := s.DB.Exec(`
+	_, err := s.db().Exec(`
 		INSERT INTO tokens (address, symbol, name, decimals)
 		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (address) DO NOTHING
@@ -168,7 +129,7 @@ func (s *Scanner) insertToken(addr common.Address, symbol, name string, decimals
 func (s *Scanner) ensurePoolExists(poolAddr common.Address) bool {
 	// Check if pool exists in DB
 	var exists bool
-	err := s.DB.QueryRow(`
+	err := s.db().QueryRow(`
 		SELECT EXISTS(SELECT 1 FROM pools WHERE address = $1)
 	`, poolAddr.Hex()).Scan(&exists)
 
@@ -198,66 +159,70 @@ func (s *Scanner) ensurePoolExists(poolAddr common.Address) bool {
 // updateTicksFromMint 从 Mint 事件更新 ticks 表的流动性
 // 注意：在这个简化实现中，所有流动性都在池子的 tickLower 到 tickUpper 之间
 // 所以我们需要更新这两个边界 tick 的流动性
-func (s *Scanner) updateTicksFromMint(poolAddr common.Address, liquidity *big.Int) {
+func (s *Scanner) updateTicksFromMint(poolAddr common.Address, liquidity *big.Int) error {
 	// 查询池子的 tick_lower 和 tick_upper
 	var tickLower, tickUpper int
-	err := s.DB.QueryRow(`
+	err := s.db().QueryRow(`
 		SELECT tick_lower, tick_upper FROM pools WHERE address = $1
 	`, poolAddr.Hex()).Scan(&tickLower, &tickUpper)
 	if err != nil {
-		log.Printf("Error querying pool ticks for update: %v", err)
-		return
+		return fmt.Errorf("查询池子 %s 的 tick 范围失败: %w", poolAddr.Hex(), err)
 	}
 
 	// 更新 tick_lower 的流动性
 	// liquidity_gross: 总流动性（累加）
 	// liquidity_net: 净流动性变化（向上为正，这里在 tickLower 处，价格向上移动时流动性增加）
-	_, err = s.DB.Exec(`
-		INSERT INTO ticks (
-			pool_address, tick_index, liquidity_gross, liquidity_net,
-			fee_growth_outside0_x128, fee_growth_outside1_x128
-		) VALUES ($1, $2, $3, $4, 0, 0)
-		ON CONFLICT (pool_address, tick_index) DO UPDATE SET
-			liquidity_gross = ticks.liquidity_gross + $3,
-			liquidity_net = ticks.liquidity_net + $4,
-			updated_at = NOW()
-	`, poolAddr.Hex(), tickLower, liquidity.String(), liquidity.String())
-	if err != nil {
-		log.Printf("Error updating tick_lower: %v", err)
+	if err := s.upsertTick(poolAddr, tickLower, liquidity, liquidity); err != nil {
+		return fmt.Errorf("更新池子 %s 的 tick_lower 失败: %w", poolAddr.Hex(), err)
 	}
 
 	// 更新 tick_upper 的流动性
 	// 在 tickUpper 处，价格向上移动时流动性减少（所以 liquidity_net 为负）
 	liquidityNeg := new(big.Int).Neg(liquidity)
-	_, err = s.DB.Exec(`
+	if err := s.upsertTick(poolAddr, tickUpper, liquidity, liquidityNeg); err != nil {
+		return fmt.Errorf("更新池子 %s 的 tick_upper 失败: %w", poolAddr.Hex(), err)
+	}
+	return nil
+}
+
+// upsertTick 累加 tick 的流动性；tick 新建或 liquidity_gross 为0（重新初始化）时设置 fee_growth_outside
+// 与 Uniswap V3 的 Tick.update 约定一致：tick <= 当前 tick 时假设此前的手续费都产生在 tick 下方，取池子的 feeGrowthGlobal，否则为0。
+// MetaNodeSwap 的池子只在固定区间内做市，Pool.swap 不会跨越边界 tick，因此初始化之后 fee_growth_outside 不再变化
+func (s *Scanner) upsertTick(poolAddr common.Address, tickIndex int, liquidityGross, liquidityNet *big.Int) error {
+	_, err := s.db().Exec(`
 		INSERT INTO ticks (
 			pool_address, tick_index, liquidity_gross, liquidity_net,
 			fee_growth_outside0_x128, fee_growth_outside1_x128
-		) VALUES ($1, $2, $3, $4, 0, 0)
+		)
+		SELECT $1, $2, $3, $4,
+			CASE WHEN $2 <= p.tick THEN p.fee_growth_global0_x128 ELSE 0 END,
+			CASE WHEN $2 <= p.tick THEN p.fee_growth_global1_x128 ELSE 0 END
+		FROM pools p WHERE p.address = $1
 		ON CONFLICT (pool_address, tick_index) DO UPDATE SET
 			liquidity_gross = ticks.liquidity_gross + $3,
 			liquidity_net = ticks.liquidity_net + $4,
+			fee_growth_outside0_x128 = CASE WHEN ticks.liquidity_gross = 0
+				THEN EXCLUDED.fee_growth_outside0_x128 ELSE ticks.fee_growth_outside0_x128 END,
+			fee_growth_outside1_x128 = CASE WHEN ticks.liquidity_gross = 0
+				THEN EXCLUDED.fee_growth_outside1_x128 ELSE ticks.fee_growth_outside1_x128 END,
 			updated_at = NOW()
-	`, poolAddr.Hex(), tickUpper, liquidity.String(), liquidityNeg.String())
-	if err != nil {
-		log.Printf("Error updating tick_upper: %v", err)
-	}
+	`, poolAddr.Hex(), tickIndex, liquidityGross.String(), liquidityNet.String())
+	return err
 }
 
 // updateTicksFromBurn 从 Burn 事件更新 ticks 表的流动性
-func (s *Scanner) updateTicksFromBurn(poolAddr common.Address, liquidity *big.Int) {
+func (s *Scanner) updateTicksFromBurn(poolAddr common.Address, liquidity *big.Int) error {
 	// 查询池子的 tick_lower 和 tick_upper
 	var tickLower, tickUpper int
-	err := s.DB.QueryRow(`
+	err := s.db().QueryRow(`
 		SELECT tick_lower, tick_upper FROM pools WHERE address = $1
 	`, poolAddr.Hex()).Scan(&tickLower, &tickUpper)
 	if err != nil {
-		log.Printf("Error querying pool ticks for update: %v", err)
-		return
+		return fmt.Errorf("查询池子 %s 的 tick 范围失败: %w", poolAddr.Hex(), err)
 	}
 
 	// 更新 tick_lower 的流动性（减少）
-	_, err = s.DB.Exec(`
+	_, err = s.db().Exec(`
 		UPDATE ticks SET
 			liquidity_gross = GREATEST(0, liquidity_gross - $1),
 			liquidity_net = liquidity_net - $1,
@@ -265,11 +230,11 @@ func (s *Scanner) updateTicksFromBurn(poolAddr common.Address, liquidity *big.In
 		WHERE pool_address = $2 AND tick_index = $3
 	`, liquidity.String(), poolAddr.Hex(), tickLower)
 	if err != nil {
-		log.Printf("Error updating tick_lower on burn: %v", err)
+		return fmt.Errorf("扣减池子 %s 的 tick_lower 流动性失败: %w", poolAddr.Hex(), err)
 	}
 
 	// 更新 tick_upper 的流动性（减少，liquidity_net 增加，因为负值减少）
-	_, err = s.DB.Exec(`
+	_, err = s.db().Exec(`
 		UPDATE ticks SET
 			liquidity_gross = GREATEST(0, liquidity_gross - $1),
 			liquidity_net = liquidity_net + $1,
@@ -277,8 +242,9 @@ func (s *Scanner) updateTicksFromBurn(poolAddr common.Address, liquidity *big.In
 		WHERE pool_address = $2 AND tick_index = $3
 	`, liquidity.String(), poolAddr.Hex(), tickUpper)
 	if err != nil {
-		log.Printf("Error updating tick_upper on burn: %v", err)
+		return fmt.Errorf("扣减池子 %s 的 tick_upper 流动性失败: %w", poolAddr.Hex(), err)
 	}
+	return nil
 }
 
 // getPoolLiquidity 查询 Pool 合约的当前流动性
@@ -300,128 +266,52 @@ func (s *Scanner) checkContractExists(addr common.Address) bool {
 }
 
 // updatePoolReserves 更新池子的 reserve0 和 reserve1
-// 通过调用 token0 和 token1 的 balanceOf(poolAddress) 获取余额
+// 通过一个批量请求调用 token0 和 token1 的 balanceOf(poolAddress)，两个余额来自同一个区块
 func (s *Scanner) updatePoolReserves(poolAddr common.Address) {
-	log.Printf("[updatePoolReserves] Starting to update reserves for pool: %s", poolAddr.Hex())
-	
-	// 查询池子的 token0 和 token1 地址
-	var token0Addr, token1Addr string
-	err := s.DB.QueryRow(`
-		SELECT token0, token1 FROM pools WHERE address = $1
-	`, poolAddr.Hex()).Scan(&token0Addr, &token1Addr)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("⚠️  Pool %s does not exist in database, skipping reserve update", poolAddr.Hex())
-		} else {
-			log.Printf("❌ Error querying pool tokens for reserve update (pool=%s): %v", poolAddr.Hex(), err)
-		}
-		return
-	}
-
-	token0 := common.HexToAddress(token0Addr)
-	token1 := common.HexToAddress(token1Addr)
-
-	log.Printf("[updatePoolReserves] Pool %s: token0=%s, token1=%s", poolAddr.Hex(), token0.Hex(), token1.Hex())
-
-	// 解析 ERC20 ABI
-	parsedABI, err := abi.JSON(strings.NewReader(erc20ABI))
-	if err != nil {
-		log.Printf("Error parsing ERC20 ABI for reserve update: %v", err)
-		return
-	}
-
-	ctx := context.Background()
-	var reserve0, reserve1 *big.Int
-	var err0, err1 error
-
-	// 获取 token0 余额
-	balanceMethod, ok := parsedABI.Methods["balanceOf"]
+	token0, token1, ok := s.poolTokens(poolAddr)
 	if !ok {
-		log.Printf("balanceOf method not found in ERC20 ABI")
 		return
 	}
 
-	data0, err := parsedABI.Pack("balanceOf", poolAddr)
-	if err != nil {
-		log.Printf("Error packing balanceOf call for token0 (pool=%s, token=%s): %v", poolAddr.Hex(), token0.Hex(), err)
+	calls := []*contractCall{
+		newCall(token0, &parsedERC20ABI, "balanceOf", poolAddr),
+		newCall(token1, &parsedERC20ABI, "balanceOf", poolAddr),
+	}
+	if _, err := s.batchCall(context.Background(), nil, calls); err != nil {
+		log.Printf("❌ Error calling balanceOf for pool %s: %v", poolAddr.Hex(), err)
 		return
 	}
+	s.applyPoolReserves(poolAddr, token0, token1, calls[0], calls[1])
+}
 
-	log.Printf("[updatePoolReserves] Calling balanceOf for token0: pool=%s, token=%s", poolAddr.Hex(), token0.Hex())
-	result0, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-		To:   &token0,
-		Data: data0,
-	}, nil)
+// poolTokens 从数据库查询池子的 token0 和 token1，池子不存在或查询失败时返回 false
+func (s *Scanner) poolTokens(poolAddr common.Address) (common.Address, common.Address, bool) {
+	var token0Addr, token1Addr string
+	err := s.db().QueryRow(`
+		SELECT token0, token1 FROM pools WHERE address = $1
+	`, poolAddr.Hex()).Scan(&token0Addr, &token1Addr)
 	if err != nil {
-		// 检查是否是 "function selector was not recognized" 错误
-		errStr := err.Error()
-		if strings.Contains(errStr, "function selector was not recognized") {
-			// 非标准 ERC20，这是预期的，不记录为错误
-			// log.Printf("⚠️  Token0 is not a standard ERC20 contract (pool=%s, token=%s)", poolAddr.Hex(), token0.Hex())
-		} else {
-			log.Printf("❌ Error calling balanceOf for token0 (pool=%s, token=%s): %v", poolAddr.Hex(), token0.Hex(), err)
-		}
-		err0 = err
-	} else {
-		log.Printf("[updatePoolReserves] Token0 balanceOf call succeeded, unpacking result...")
-		unpacked, err := balanceMethod.Outputs.Unpack(result0)
-		if err != nil {
-			log.Printf("❌ Error unpacking balanceOf result for token0 (pool=%s, token=%s): %v", poolAddr.Hex(), token0.Hex(), err)
-			err0 = err
-		} else if len(unpacked) > 0 {
-			if balance, ok := unpacked[0].(*big.Int); ok {
-				reserve0 = balance
-				log.Printf("[updatePoolReserves] Token0 balance: %s", balance.String())
-			} else {
-				log.Printf("❌ Error: balanceOf result for token0 is not *big.Int (pool=%s, token=%s, type=%T)", poolAddr.Hex(), token0.Hex(), unpacked[0])
-				err0 = fmt.Errorf("unexpected type")
-			}
+		if err == sql.ErrNoRows {
+			log.Printf("⚠️  Pool %s does not exist in database, skipping reserve update", poolAddr.Hex())
 		} else {
-			log.Printf("❌ Error: balanceOf result for token0 is empty (pool=%s, token=%s)", poolAddr.Hex(), token0.Hex())
-			err0 = fmt.Errorf("empty result")
+			log.Printf("❌ Error querying pool tokens for reserve update (pool=%s): %v", poolAddr.Hex(), err)
 		}
+		return common.Address{}, common.Address{}, false
 	}
+	return common.HexToAddress(token0Addr), common.HexToAddress(token1Addr), true
+}
 
-	// 获取 token1 余额
-	data1, err := parsedABI.Pack("balanceOf", poolAddr)
-	if err != nil {
-		log.Printf("Error packing balanceOf call for token1 (pool=%s, token=%s): %v", poolAddr.Hex(), token1.Hex(), err)
-		return
+// applyPoolReserves 把两个 balanceOf 调用的结果写入 reserve0/reserve1：只有一个成功时只更新这一个，
+// 两个都因为代币没有 balanceOf 而失败时从 Mint/Burn 事件累加计算
+func (s *Scanner) applyPoolReserves(poolAddr, token0, token1 common.Address, balance0, balance1 *contractCall) {
+	reserve0, err0 := balance0.bigIntValue(0)
+	if err0 != nil && !isMissingFunction(err0) {
+		// 非标准 ERC20 是预期的，不记录为错误
+		log.Printf("❌ Error calling balanceOf for token0 (pool=%s, token=%s): %v", poolAddr.Hex(), token0.Hex(), err0)
 	}
-
-	log.Printf("[updatePoolReserves] Calling balanceOf for token1: pool=%s, token=%s", poolAddr.Hex(), token1.Hex())
-	result1, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-		To:   &token1,
-		Data: data1,
-	}, nil)
-	if err != nil {
-		// 检查是否是 "function selector was not recognized" 错误
-		errStr := err.Error()
-		if strings.Contains(errStr, "function selector was not recognized") {
-			// 非标准 ERC20，这是预期的，不记录为错误
-			// log.Printf("⚠️  Token1 is not a standard ERC20 contract (pool=%s, token=%s)", poolAddr.Hex(), token1.Hex())
-		} else {
-			log.Printf("❌ Error calling balanceOf for token1 (pool=%s, token=%s): %v", poolAddr.Hex(), token1.Hex(), err)
-		}
-		err1 = err
-	} else {
-		log.Printf("[updatePoolReserves] Token1 balanceOf call succeeded, unpacking result...")
-		unpacked, err := balanceMethod.Outputs.Unpack(result1)
-		if err != nil {
-			log.Printf("❌ Error unpacking balanceOf result for token1 (pool=%s, token=%s): %v", poolAddr.Hex(), token1.Hex(), err)
-			err1 = err
-		} else if len(unpacked) > 0 {
-			if balance, ok := unpacked[0].(*big.Int); ok {
-				reserve1 = balance
-				log.Printf("[updatePoolReserves] Token1 balance: %s", balance.String())
-			} else {
-				log.Printf("❌ Error: balanceOf result for token1 is not *big.Int (pool=%s, token=%s, type=%T)", poolAddr.Hex(), token1.Hex(), unpacked[0])
-				err1 = fmt.Errorf("unexpected type")
-			}
-		} else {
-			log.Printf("❌ Error: balanceOf result for token1 is empty (pool=%s, token=%s)", poolAddr.Hex(), token1.Hex())
-			err1 = fmt.Errorf("empty result")
-		}
+	reserve1, err1 := balance1.bigIntValue(0)
+	if err1 != nil && !isMissingFunction(err1) {
+		log.Printf("❌ Error calling balanceOf for token1 (pool=%s, token=%s): %v", poolAddr.Hex(), token1.Hex(), err1)
 	}
 
 	// 更新数据库（即使只有一个成功也更新，另一个设为0或保持原值）
@@ -431,7 +321,7 @@ func (s *Scanner) updatePoolReserves(poolAddr common.Address) {
 	if reserve0 != nil && reserve1 != nil {
 		log.Printf("[updatePoolReserves] Executing UPDATE: reserve0=%s, reserve1=%s, pool=%s", 
 			reserve0.String(), reserve1.String(), poolAddr.Hex())
-		result, err := s.DB.Exec(`
+		result, err := s.db().Exec(`
 			UPDATE pools SET reserve0 = $1, reserve1 = $2
 			WHERE address = $3
 		`, reserve0.String(), reserve1.String(), poolAddr.Hex())
@@ -449,7 +339,7 @@ func (s *Scanner) updatePoolReserves(poolAddr common.Address) {
 		// 只有 reserve0 成功，只更新 reserve0
 		log.Printf("[updatePoolReserves] Executing UPDATE reserve0 only: reserve0=%s, pool=%s", 
 			reserve0.String(), poolAddr.Hex())
-		result, err := s.DB.Exec(`
+		result, err := s.db().Exec(`
 			UPDATE pools SET reserve0 = $1
 			WHERE address = $2
 		`, reserve0.String(), poolAddr.Hex())
@@ -467,7 +357,7 @@ func (s *Scanner) updatePoolReserves(poolAddr common.Address) {
 		// 只有 reserve1 成功，只更新 reserve1
 		log.Printf("[updatePoolReserves] Executing UPDATE reserve1 only: reserve1=%s, pool=%s", 
 			reserve1.String(), poolAddr.Hex())
-		result, err := s.DB.Exec(`
+		result, err := s.db().Exec(`
 			UPDATE pools SET reserve1 = $1
 			WHERE address = $2
 		`, reserve1.String(), poolAddr.Hex())
@@ -492,9 +382,8 @@ func (s *Scanner) updatePoolReserves(poolAddr common.Address) {
 			err1Str = err1.Error()
 		}
 
-		// 检查是否都是 "function selector was not recognized" 错误
-		isNonStandardERC20 := (err0 != nil && strings.Contains(err0Str, "function selector was not recognized")) &&
-			(err1 != nil && strings.Contains(err1Str, "function selector was not recognized"))
+		// 检查是否都是代币没有 balanceOf 导致的错误
+		isNonStandardERC20 := isMissingFunction(err0) && isMissingFunction(err1)
 
 		if isNonStandardERC20 {
 			// 这是预期的：代币不是标准 ERC20，无法通过 balanceOf 获取余额
@@ -510,7 +399,7 @@ func (s *Scanner) updatePoolReserves(poolAddr common.Address) {
 				// 使用从事件中计算的值更新数据库
 				log.Printf("   ✅ Calculated reserves from events: reserve0=%s, reserve1=%s",
 					fallbackReserve0.String(), fallbackReserve1.String())
-				_, err = s.DB.Exec(`
+				_, err := s.db().Exec(`
 					UPDATE pools SET reserve0 = $1, reserve1 = $2
 					WHERE address = $3
 				`, fallbackReserve0.String(), fallbackReserve1.String(), poolAddr.Hex())
@@ -529,7 +418,7 @@ func (s *Scanner) updatePoolReserves(poolAddr common.Address) {
 			log.Printf("   Token1 (%s): reserve1=%v, error=%v", token1.Hex(), reserve1, err1Str)
 			
 			// 即使两个都失败，也尝试将数据库中的值设为 0（如果当前是 NULL）
-			_, err = s.DB.Exec(`
+			_, err := s.db().Exec(`
 				UPDATE pools 
 				SET reserve0 = COALESCE(reserve0, '0'), reserve1 = COALESCE(reserve1, '0')
 				WHERE address = $1 AND (reserve0 IS NULL OR reserve1 IS NULL)
@@ -546,7 +435,7 @@ func (s *Scanner) updatePoolReserves(poolAddr common.Address) {
 func (s *Scanner) calculateReservesFromEvents(poolAddr common.Address) (*big.Int, *big.Int) {
 	// 查询所有 Mint 事件，累加 amount0 和 amount1
 	var totalMint0, totalMint1 sql.NullString
-	err := s.DB.QueryRow(`
+	err := s.db().QueryRow(`
 		SELECT 
 			COALESCE(SUM(amount0::numeric), 0) as total0,
 			COALESCE(SUM(amount1::numeric), 0) as total1
@@ -561,7 +450,7 @@ func (s *Scanner) calculateReservesFromEvents(poolAddr common.Address) (*big.Int
 	
 	// 查询所有 Burn 事件，累加 amount0 和 amount1
 	var totalBurn0, totalBurn1 sql.NullString
-	err = s.DB.QueryRow(`
+	err = s.db().QueryRow(`
 		SELECT 
 			COALESCE(SUM(amount0::numeric), 0) as total0,
 			COALESCE(SUM(amount1::numeric), 0) as total1
@@ -619,14 +508,14 @@ func (s *Scanner) UpdateAllPoolReserves() error {
 
 	// 先查询总数
 	var total int
-	err := s.DB.QueryRow("SELECT COUNT(*) FROM pools").Scan(&total)
+	err := s.db().QueryRow("SELECT COUNT(*) FROM pools").Scan(&total)
 	if err != nil {
 		log.Printf("Warning: failed to get total pool count: %v", err)
 		total = 0
 	}
 	log.Printf("Found %d pools to update", total)
 
-	rows, err := s.DB.Query("SELECT address FROM pools")
+	rows, err := s.db().Query("SELECT address FROM pools")
 	if err != nil {
 		return fmt.Errorf("failed to query pools: %v", err)
 	}
@@ -659,49 +548,59 @@ func (s *Scanner) UpdateAllPoolReserves() error {
 }
 
 // UpdateAllPoolStates 更新所有池子的完整状态（包括 sqrt_price_x96, tick, liquidity, reserve0, reserve1）
-// 用于手动修复历史数据或初始化
+// 用于手动修复历史数据或初始化；所有池子固定在同一个区块上批量读取
 func (s *Scanner) UpdateAllPoolStates() error {
 	log.Println("Starting to update full state for all pools...")
 
-	// 先查询总数
-	var total int
-	err := s.DB.QueryRow("SELECT COUNT(*) FROM pools").Scan(&total)
-	if err != nil {
-		log.Printf("Warning: failed to get total pool count: %v", err)
-		total = 0
-	}
-	log.Printf("Found %d pools to update", total)
-
-	rows, err := s.DB.Query("SELECT address FROM pools")
+	rows, err := s.db().Query("SELECT address, token0, token1, pool_index FROM pools")
 	if err != nil {
 		return fmt.Errorf("failed to query pools: %v", err)
 	}
-	defer rows.Close()
 
-	count := 0
-	successCount := 0
+	type poolRow struct {
+		addr, token0, token1 common.Address
+		poolIndex            sql.NullInt64
+	}
+	var pools []poolRow
 	for rows.Next() {
-		var addr string
-		if err := rows.Scan(&addr); err != nil {
+		var addr, token0, token1 string
+		var poolIndex sql.NullInt64
+		if err := rows.Scan(&addr, &token0, &token1, &poolIndex); err != nil {
 			log.Printf("Error scanning pool address: %v", err)
 			continue
 		}
+		pools = append(pools, poolRow{common.HexToAddress(addr), common.HexToAddress(token0), common.HexToAddress(token1), poolIndex})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query pools: %v", err)
+	}
+	log.Printf("Found %d pools to update", len(pools))
 
-		count++
-		if total > 0 {
-			log.Printf("Updating full state for pool %d/%d: %s", count, total, addr)
-		} else {
-			log.Printf("Updating full state for pool %d: %s", count, addr)
+	latest, err := s.Client.BlockNumber(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get latest block number: %v", err)
+	}
+	blockNumber := new(big.Int).SetUint64(latest)
+	log.Printf("Reading pool states at block %d", latest)
+
+	addrs := make([]common.Address, 0, len(pools))
+	for _, pool := range pools {
+		// 补全迁移前创建的池子缺失的 pool_index
+		if !pool.poolIndex.Valid {
+			poolIndex := s.queryPoolIndexFromChain(pool.token0, pool.token1, pool.addr, blockNumber)
+			if poolIndex.Valid {
+				if _, err := s.db().Exec("UPDATE pools SET pool_index = $1 WHERE address = $2", poolIndex.Int64, pool.addr.Hex()); err != nil {
+					log.Printf("Error updating pool_index for pool %s: %v", pool.addr.Hex(), err)
+				}
+			}
 		}
-		
-		s.updatePoolStateFromChain(common.HexToAddress(addr))
-		successCount++
-
-		// 添加小延迟避免 RPC 限流
-		time.Sleep(200 * time.Millisecond)
+		addrs = append(addrs, pool.addr)
 	}
 
-	log.Printf("✅ Completed updating pool states: %d/%d pools processed successfully", successCount, count)
+	s.updatePoolStatesFromChainAt(addrs, blockNumber)
+
+	log.Printf("✅ Completed updating pool states: %d pools processed at block %d", len(addrs), latest)
 	return nil
 }
 
@@ -763,113 +662,337 @@ var poolABI = `[
 		"name": "tickUpper",
 		"outputs": [{"name": "", "type": "int24"}],
 		"type": "function"
+	},
+	{
+		"constant": true,
+		"inputs": [{"name": "", "type": "address"}],
+		"name": "positions",
+		"outputs": [
+			{"name": "liquidity", "type": "uint128"},
+			{"name": "feeGrowthInside0LastX128", "type": "uint256"},
+			{"name": "feeGrowthInside1LastX128", "type": "uint256"},
+			{"name": "tokensOwed0", "type": "uint128"},
+			{"name": "tokensOwed1", "type": "uint128"}
+		],
+		"type": "function"
+	},
+	{
+		"constant": true,
+		"inputs": [],
+		"name": "feeGrowthGlobal0X128",
+		"outputs": [{"name": "", "type": "uint256"}],
+		"type": "function"
+	},
+	{
+		"constant": true,
+		"inputs": [],
+		"name": "feeGrowthGlobal1X128",
+		"outputs": [{"name": "", "type": "uint256"}],
+		"type": "function"
 	}
 ]`
 
-// updatePoolStateFromChain 从链上查询并更新池子的完整状态
-// 包括 sqrt_price_x96, tick, liquidity, reserve0, reserve1
-func (s *Scanner) updatePoolStateFromChain(poolAddr common.Address) {
-	// 1. 查询 slot0 获取 sqrtPriceX96 和 tick
+// PoolPosition 表示 Pool 合约中按 owner 记录的 Position 结构体
+type PoolPosition struct {
+	Liquidity                *big.Int
+	FeeGrowthInside0LastX128 *big.Int
+	FeeGrowthInside1LastX128 *big.Int
+	TokensOwed0              *big.Int
+	TokensOwed1              *big.Int
+}
+
+// queryPoolPosition 按指定区块查询 Pool.positions(owner)，blockNumber 为 0 时查询最新区块
+func (s *Scanner) queryPoolPosition(poolAddr common.Address, owner common.Address, blockNumber uint64) (*PoolPosition, error) {
 	parsedABI, err := abi.JSON(strings.NewReader(poolABI))
 	if err != nil {
-		log.Printf("Error parsing Pool ABI for pool %s: %v", poolAddr.Hex(), err)
-		// 如果 ABI 解析失败，至少尝试更新 reserves
-		s.updatePoolReserves(poolAddr)
-		return
+		return nil, fmt.Errorf("failed to parse Pool ABI: %v", err)
 	}
 
-	ctx := context.Background()
+	data, err := parsedABI.Pack("positions", owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack positions call: %v", err)
+	}
 
-	// 查询 slot0
-	slot0Method, ok := parsedABI.Methods["slot0"]
-	if !ok {
-		log.Printf("slot0 method not found in Pool ABI for pool %s", poolAddr.Hex())
-		s.updatePoolReserves(poolAddr)
-		return
+	var block *big.Int
+	if blockNumber > 0 {
+		block = new(big.Int).SetUint64(blockNumber)
+	}
+	result, err := s.Client.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &poolAddr,
+		Data: data,
+	}, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Pool.positions: %v", err)
 	}
 
-	data, err := parsedABI.Pack("slot0")
+	values, err := parsedABI.Methods["positions"].Outputs.Unpack(result)
+	if err != nil || len(values) < 5 {
+		return nil, fmt.Errorf("failed to unpack Pool.positions: %v", err)
+	}
+
+	position := &PoolPosition{}
+	position.Liquidity, _ = values[0].(*big.Int)
+	position.FeeGrowthInside0LastX128, _ = values[1].(*big.Int)
+	position.FeeGrowthInside1LastX128, _ = values[2].(*big.Int)
+	position.TokensOwed0, _ = values[3].(*big.Int)
+	position.TokensOwed1, _ = values[4].(*big.Int)
+	return position, nil
+}
+
+// queryFeeGrowthGlobal 按指定区块查询 Pool.feeGrowthGlobal0X128 和 feeGrowthGlobal1X128，blockNumber 为 nil 时查询最新区块
+// 两个值在同一个批量请求中读取
+func (s *Scanner) queryFeeGrowthGlobal(poolAddr common.Address, blockNumber *big.Int) (*big.Int, *big.Int, error) {
+	calls := []*contractCall{
+		newCall(poolAddr, &parsedPoolABI, "feeGrowthGlobal0X128"),
+		newCall(poolAddr, &parsedPoolABI, "feeGrowthGlobal1X128"),
+	}
+	if _, err := s.batchCall(context.Background(), blockNumber, calls); err != nil {
+		return nil, nil, err
+	}
+	return feeGrowthValues(calls[0], calls[1])
+}
+
+// feeGrowthValues 从 feeGrowthGlobal0X128 / feeGrowthGlobal1X128 调用中取出结果
+func feeGrowthValues(call0, call1 *contractCall) (*big.Int, *big.Int, error) {
+	feeGrowth0, err := call0.bigIntValue(0)
 	if err != nil {
-		log.Printf("Error packing slot0 call for pool %s: %v", poolAddr.Hex(), err)
-		s.updatePoolReserves(poolAddr)
-		return
+		return nil, nil, fmt.Errorf("failed to call Pool.feeGrowthGlobal0X128: %v", err)
 	}
+	feeGrowth1, err := call1.bigIntValue(0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to call Pool.feeGrowthGlobal1X128: %v", err)
+	}
+	return feeGrowth0, feeGrowth1, nil
+}
 
-	result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-		To:   &poolAddr,
-		Data: data,
-	}, nil)
+// updatePoolFeeGrowth 从链上同步池子的 fee_growth_global0_x128/fee_growth_global1_x128
+// 只有 Swap 会改变 feeGrowthGlobal，因此在每个 Swap 之后按事件所在区块查询。
+// eth_call 读取的是区块结束时的状态：同一区块内有多个 Swap 时，每个 Swap 之后写入的都是该区块最后的值，
+// 不是逐笔 Swap 之后的 feeGrowthGlobal。节点没有该区块的历史状态时改用最新区块（见 atBlockOrLatest）
+func (s *Scanner) updatePoolFeeGrowth(poolAddr common.Address, blockNumber uint64) error {
+	var feeGrowth0, feeGrowth1 *big.Int
+	err := s.atBlockOrLatest(blockNumber, func(block uint64) error {
+		var number *big.Int
+		if block > 0 {
+			number = new(big.Int).SetUint64(block)
+		}
+		var err error
+		feeGrowth0, feeGrowth1, err = s.queryFeeGrowthGlobal(poolAddr, number)
+		return err
+	})
 	if err != nil {
-		log.Printf("Error calling slot0 for pool %s: %v", poolAddr.Hex(), err)
-		// 如果 slot0 调用失败，至少尝试更新 reserves
-		s.updatePoolReserves(poolAddr)
-		return
+		return fmt.Errorf("查询池子 %s 的 feeGrowthGlobal 失败: %w", poolAddr.Hex(), err)
 	}
+	return s.writePoolFeeGrowth(poolAddr, feeGrowth0, feeGrowth1)
+}
 
-	unpacked, err := slot0Method.Outputs.Unpack(result)
-	if err != nil || len(unpacked) < 2 {
-		log.Printf("Error unpacking slot0 result for pool %s: %v", poolAddr.Hex(), err)
-		s.updatePoolReserves(poolAddr)
-		return
+// atBlockOrLatest 按事件所在区块执行链上查询 query，节点没有该区块的历史状态时（非归档节点只保留最近约 128 个区块，
+// 回填的历史区块都早于链头 - 128）改用最新区块（block 为 0）重新查询，只输出一次警告，不让整个区块范围失败。
+// 改用最新区块时写入的是当前状态，不是事件发生时的状态；需要逐区块精确的历史值时应使用归档节点
+func (s *Scanner) atBlockOrLatest(blockNumber uint64, query func(block uint64) error) error {
+	err := query(blockNumber)
+	if blockNumber == 0 || !rpcclient.IsStateUnavailable(err) {
+		return err
 	}
+	s.stateWarning.Do(func() {
+		log.Printf("⚠️  RPC node has no state for block %d (non-archive node?): %v. "+
+			"Historical fee growth and tokensOwed reads fall back to the latest block; use an archive node for exact per-block values",
+			blockNumber, err)
+	})
+	return query(0)
+}
 
-	var sqrtPriceX96 *big.Int
-	var tick int64
+// writePoolFeeGrowth 把 feeGrowthGlobal 写入 pools 表
+func (s *Scanner) writePoolFeeGrowth(poolAddr common.Address, feeGrowth0, feeGrowth1 *big.Int) error {
+	_, err := s.db().Exec(`
+		UPDATE pools SET fee_growth_global0_x128 = $1, fee_growth_global1_x128 = $2
+		WHERE address = $3
+	`, feeGrowth0.String(), feeGrowth1.String(), poolAddr.Hex())
+	if err != nil {
+		return fmt.Errorf("更新池子 %s 的 feeGrowthGlobal 失败: %w", poolAddr.Hex(), err)
+	}
+	return nil
+}
 
-	if price, ok := unpacked[0].(*big.Int); ok {
-		sqrtPriceX96 = price
-	} else {
-		log.Printf("Error: slot0 sqrtPriceX96 is not *big.Int for pool %s", poolAddr.Hex())
-		s.updatePoolReserves(poolAddr)
-		return
+// Factory ABI 定义（用于通过 getPool 反查池子的 index）
+var factoryABI = `[
+	{
+		"constant": true,
+		"inputs": [
+			{"name": "tokenA", "type": "address"},
+			{"name": "tokenB", "type": "address"},
+			{"name": "index", "type": "uint32"}
+		],
+		"name": "getPool",
+		"outputs": [{"name": "", "type": "address"}],
+		"type": "function"
 	}
+]`
 
-	if t, ok := unpacked[1].(int32); ok {
-		tick = int64(t)
-	} else if t, ok := unpacked[1].(*big.Int); ok {
-		tick = t.Int64()
-	} else {
-		log.Printf("Error: slot0 tick is not int32 or *big.Int for pool %s, type=%T", poolAddr.Hex(), unpacked[1])
-		s.updatePoolReserves(poolAddr)
-		return
+// maxPoolIndexLookup 反查 pool index 时最多尝试的 index 数量
+const maxPoolIndexLookup = 256
+
+// poolIndexBatch 反查 pool index 时每个批量请求查询的 index 数量
+const poolIndexBatch = 16
+
+// queryPoolIndexFromChain 通过 PoolManager(Factory).getPool(token0, token1, index) 反查池子的 index
+// 每次批量查询 poolIndexBatch 个 index，按顺序找到该池子地址为止；遇到零地址说明该交易对已没有更多池子。
+// blockNumber 为 nil 时查询最新区块
+func (s *Scanner) queryPoolIndexFromChain(token0, token1, poolAddr common.Address, blockNumber *big.Int) sql.NullInt64 {
+	if s.Config.Contracts.PoolManager == "" {
+		return sql.NullInt64{}
 	}
 
-	// 2. 查询 liquidity
-	liquidityUpdated := false
-	liquidityMethod, ok := parsedABI.Methods["liquidity"]
-	if ok {
-		data, err := parsedABI.Pack("liquidity")
-		if err == nil {
-			result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-				To:   &poolAddr,
-				Data: data,
-			}, nil)
-			if err == nil {
-				unpacked, err := liquidityMethod.Outputs.Unpack(result)
-				if err == nil && len(unpacked) > 0 {
-					if liq, ok := unpacked[0].(*big.Int); ok {
-						// 更新数据库：包括 sqrt_price_x96, tick, liquidity
-						_, err = s.DB.Exec(`
-							UPDATE pools 
-							SET sqrt_price_x96 = $1, tick = $2, liquidity = $3
-							WHERE address = $4
-						`, sqrtPriceX96.String(), tick, liq.String(), poolAddr.Hex())
-						if err != nil {
-							log.Printf("Error updating pool state from chain (pool=%s): %v", poolAddr.Hex(), err)
-						} else {
-							log.Printf("✅ Updated pool state from chain: %s (sqrtPriceX96=%s, tick=%d, liquidity=%s)",
-								poolAddr.Hex(), sqrtPriceX96.String(), tick, liq.String())
-							liquidityUpdated = true
-						}
-					}
-				}
+	factoryAddr := common.HexToAddress(s.Config.Contracts.PoolManager)
+	ctx := context.Background()
+
+	for from := uint32(0); from < maxPoolIndexLookup; from += poolIndexBatch {
+		calls := make([]*contractCall, 0, poolIndexBatch)
+		for i := from; i < from+poolIndexBatch; i++ {
+			calls = append(calls, newCall(factoryAddr, &parsedFactoryABI, "getPool", token0, token1, i))
+		}
+		pinned, err := s.batchCall(ctx, blockNumber, calls)
+		if err != nil {
+			log.Printf("Error calling getPool(%s, %s, %d-%d): %v", token0.Hex(), token1.Hex(), from, from+poolIndexBatch-1, err)
+			return sql.NullInt64{}
+		}
+		// 后续批次固定在同一个区块上
+		blockNumber = pinned
+
+		for i, call := range calls {
+			addr, err := call.addressValue(0)
+			if err != nil {
+				log.Printf("Error calling getPool(%s, %s, %d): %v", token0.Hex(), token1.Hex(), from+uint32(i), err)
+				return sql.NullInt64{}
+			}
+			if addr == (common.Address{}) {
+				log.Printf("Pool index not found for pool %s", poolAddr.Hex())
+				return sql.NullInt64{}
 			}
+			if addr == poolAddr {
+				return sql.NullInt64{Int64: int64(from) + int64(i), Valid: true}
+			}
+		}
+	}
+
+	log.Printf("Pool index not found for pool %s", poolAddr.Hex())
+	return sql.NullInt64{}
+}
+
+// updatePoolStateFromChain 从链上查询并更新池子的完整状态
+// 包括 sqrt_price_x96, tick, liquidity, fee_growth_global0_x128, fee_growth_global1_x128, reserve0, reserve1
+func (s *Scanner) updatePoolStateFromChain(poolAddr common.Address) {
+	s.updatePoolStateFromChainAt(poolAddr, nil)
+}
+
+// updatePoolStateFromChainAt 按指定区块高度查询池子的完整状态并更新，blockNumber 为 nil 时查询最新区块
+// reorg 回滚时用于把池子状态恢复到共同祖先区块
+func (s *Scanner) updatePoolStateFromChainAt(poolAddr common.Address, blockNumber *big.Int) {
+	s.updatePoolStatesFromChainAt([]common.Address{poolAddr}, blockNumber)
+}
+
+// poolStateCalls 读取一个池子完整状态的调用：slot0、liquidity、feeGrowthGlobal0/1 和两个代币的 balanceOf
+type poolStateCalls struct {
+	pool, token0, token1   common.Address
+	slot0, liquidity       *contractCall
+	feeGrowth0, feeGrowth1 *contractCall
+	balance0, balance1     *contractCall
+}
+
+func newPoolStateCalls(pool, token0, token1 common.Address) *poolStateCalls {
+	return &poolStateCalls{
+		pool:       pool,
+		token0:     token0,
+		token1:     token1,
+		slot0:      newCall(pool, &parsedPoolABI, "slot0"),
+		liquidity:  newCall(pool, &parsedPoolABI, "liquidity"),
+		feeGrowth0: newCall(pool, &parsedPoolABI, "feeGrowthGlobal0X128"),
+		feeGrowth1: newCall(pool, &parsedPoolABI, "feeGrowthGlobal1X128"),
+		balance0:   newCall(token0, &parsedERC20ABI, "balanceOf", pool),
+		balance1:   newCall(token1, &parsedERC20ABI, "balanceOf", pool),
+	}
+}
+
+func (p *poolStateCalls) calls() []*contractCall {
+	return []*contractCall{p.slot0, p.liquidity, p.feeGrowth0, p.feeGrowth1, p.balance0, p.balance1}
+}
+
+// updatePoolStatesFromChainAt 把多个池子的完整状态合并为批量请求（Multicall3 或 JSON-RPC 批量），
+// 所有池子的所有字段都读取自同一个区块高度，blockNumber 为 nil 时使用最新区块
+func (s *Scanner) updatePoolStatesFromChainAt(poolAddrs []common.Address, blockNumber *big.Int) {
+	pools, pinned, err := s.readPoolStates(poolAddrs, blockNumber)
+	if err != nil {
+		log.Printf("Error reading state for %d pools from chain: %v", len(poolAddrs), err)
+		return
+	}
+	for _, p := range pools {
+		s.applyPoolState(p, pinned.Uint64())
+	}
+}
+
+// readPoolStates 批量读取多个池子的完整状态，返回每个池子的调用结果和实际使用的区块高度；
+// 数据库中没有记录的池子跳过，单个调用的失败记录在对应的 contractCall 中
+func (s *Scanner) readPoolStates(poolAddrs []common.Address, blockNumber *big.Int) ([]*poolStateCalls, *big.Int, error) {
+	pools := make([]*poolStateCalls, 0, len(poolAddrs))
+	var calls []*contractCall
+	for _, poolAddr := range poolAddrs {
+		token0, token1, ok := s.poolTokens(poolAddr)
+		if !ok {
+			continue
 		}
+		p := newPoolStateCalls(poolAddr, token0, token1)
+		pools = append(pools, p)
+		calls = append(calls, p.calls()...)
+	}
+	if len(pools) == 0 {
+		return nil, blockNumber, nil
 	}
 
-	// 3. 如果没有成功更新 liquidity，至少更新 sqrt_price_x96 和 tick
-	if !liquidityUpdated {
-		_, err = s.DB.Exec(`
+	pinned, err := s.batchCall(context.Background(), blockNumber, calls)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pools, pinned, nil
+}
+
+// applyPoolState 把一个池子的批量读取结果写入数据库，并通知 backend 池子状态已改变
+// slot0 失败时只更新 reserves；liquidity 失败时只更新 sqrt_price_x96 和 tick
+func (s *Scanner) applyPoolState(p *poolStateCalls, blockNumber uint64) {
+	poolAddr := p.pool
+
+	// 1. slot0: sqrtPriceX96 和 tick
+	sqrtPriceX96, err := p.slot0.bigIntValue(0)
+	if err != nil {
+		log.Printf("Error calling slot0 for pool %s: %v", poolAddr.Hex(), err)
+		// 如果 slot0 调用失败，至少尝试更新 reserves
+		s.applyPoolReserves(poolAddr, p.token0, p.token1, p.balance0, p.balance1)
+		return
+	}
+	tick, err := p.slot0.intValue(1)
+	if err != nil {
+		log.Printf("Error: slot0 tick for pool %s: %v", poolAddr.Hex(), err)
+		s.applyPoolReserves(poolAddr, p.token0, p.token1, p.balance0, p.balance1)
+		return
+	}
+
+	// 2. liquidity
+	if liq, err := p.liquidity.bigIntValue(0); err == nil {
+		// 更新数据库：包括 sqrt_price_x96, tick, liquidity
+		_, err = s.db().Exec(`
+			UPDATE pools 
+			SET sqrt_price_x96 = $1, tick = $2, liquidity = $3
+			WHERE address = $4
+		`, sqrtPriceX96.String(), tick, liq.String(), poolAddr.Hex())
+		if err != nil {
+			log.Printf("Error updating pool state from chain (pool=%s): %v", poolAddr.Hex(), err)
+		} else {
+			log.Printf("✅ Updated pool state from chain: %s (block=%d, sqrtPriceX96=%s, tick=%d, liquidity=%s)",
+				poolAddr.Hex(), blockNumber, sqrtPriceX96.String(), tick, liq.String())
+		}
+	} else {
+		// 3. 如果没有成功查询 liquidity，至少更新 sqrt_price_x96 和 tick
+		log.Printf("Error calling liquidity for pool %s: %v", poolAddr.Hex(), err)
+		_, err = s.db().Exec(`
 			UPDATE pools 
 			SET sqrt_price_x96 = $1, tick = $2
 			WHERE address = $3
@@ -877,149 +1000,71 @@ func (s *Scanner) updatePoolStateFromChain(poolAddr common.Address) {
 		if err != nil {
 			log.Printf("Error updating pool sqrt_price_x96 and tick (pool=%s): %v", poolAddr.Hex(), err)
 		} else {
-			log.Printf("✅ Updated pool sqrt_price_x96 and tick from chain: %s (sqrtPriceX96=%s, tick=%d)",
-				poolAddr.Hex(), sqrtPriceX96.String(), tick)
+			log.Printf("✅ Updated pool sqrt_price_x96 and tick from chain: %s (block=%d, sqrtPriceX96=%s, tick=%d)",
+				poolAddr.Hex(), blockNumber, sqrtPriceX96.String(), tick)
 		}
 	}
 
-	// 4. 更新 reserves
-	s.updatePoolReserves(poolAddr)
+	// 4. 更新 feeGrowthGlobal
+	feeGrowth0, feeGrowth1, err := feeGrowthValues(p.feeGrowth0, p.feeGrowth1)
+	if err == nil {
+		err = s.writePoolFeeGrowth(poolAddr, feeGrowth0, feeGrowth1)
+	}
+	if err != nil {
+		log.Printf("Error updating pool fee growth from chain (pool=%s): %v", poolAddr.Hex(), err)
+	}
+
+	// 5. 更新 reserves
+	s.applyPoolReserves(poolAddr, p.token0, p.token1, p.balance0, p.balance1)
+
+	// 6. 记录 updated_block 并通知 backend 池子状态已改变
+	if err := markPoolChanged(s.db(), poolAddr.Hex(), blockNumber); err != nil {
+		log.Printf("Error notifying pool change (pool=%s): %v", poolAddr.Hex(), err)
+	}
 }
 
 // createPoolFromChain 从链上查询池信息并创建数据库记录
+// 池子参数、pool index、代币信息和池子状态都固定在同一个区块上读取
 func (s *Scanner) createPoolFromChain(poolAddr common.Address) bool {
-	parsedABI, err := abi.JSON(strings.NewReader(poolABI))
+	calls := []*contractCall{
+		newCall(poolAddr, &parsedPoolABI, "token0"),
+		newCall(poolAddr, &parsedPoolABI, "token1"),
+		newCall(poolAddr, &parsedPoolABI, "fee"),
+		newCall(poolAddr, &parsedPoolABI, "tickLower"),
+		newCall(poolAddr, &parsedPoolABI, "tickUpper"),
+	}
+	blockNumber, err := s.batchCall(context.Background(), nil, calls)
 	if err != nil {
-		log.Printf("Error parsing Pool ABI for pool %s: %v", poolAddr.Hex(), err)
+		log.Printf("Error querying pool %s from chain: %v", poolAddr.Hex(), err)
 		return false
 	}
 
-	ctx := context.Background()
-
-	// 查询 token0
-	var token0 common.Address
-	if token0Method, ok := parsedABI.Methods["token0"]; ok {
-		data, err := parsedABI.Pack("token0")
-		if err == nil {
-			result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-				To:   &poolAddr,
-				Data: data,
-			}, nil)
-			if err == nil {
-				unpacked, err := token0Method.Outputs.Unpack(result)
-				if err == nil && len(unpacked) > 0 {
-					if addr, ok := unpacked[0].(common.Address); ok {
-						token0 = addr
-					}
-				}
-			}
-		}
-	}
+	token0, _ := calls[0].addressValue(0)
 	if token0 == (common.Address{}) {
 		log.Printf("Failed to query token0 for pool %s", poolAddr.Hex())
 		return false
 	}
-
-	// 查询 token1
-	var token1 common.Address
-	if token1Method, ok := parsedABI.Methods["token1"]; ok {
-		data, err := parsedABI.Pack("token1")
-		if err == nil {
-			result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-				To:   &poolAddr,
-				Data: data,
-			}, nil)
-			if err == nil {
-				unpacked, err := token1Method.Outputs.Unpack(result)
-				if err == nil && len(unpacked) > 0 {
-					if addr, ok := unpacked[0].(common.Address); ok {
-						token1 = addr
-					}
-				}
-			}
-		}
-	}
+	token1, _ := calls[1].addressValue(0)
 	if token1 == (common.Address{}) {
 		log.Printf("Failed to query token1 for pool %s", poolAddr.Hex())
 		return false
 	}
+	fee, _ := calls[2].intValue(0)
+	tickLower, _ := calls[3].intValue(0)
+	tickUpper, _ := calls[4].intValue(0)
 
-	// 查询 fee
-	var fee int64
-	if feeMethod, ok := parsedABI.Methods["fee"]; ok {
-		data, err := parsedABI.Pack("fee")
-		if err == nil {
-			result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-				To:   &poolAddr,
-				Data: data,
-			}, nil)
-			if err == nil {
-				unpacked, err := feeMethod.Outputs.Unpack(result)
-				if err == nil && len(unpacked) > 0 {
-					if f, ok := unpacked[0].(*big.Int); ok {
-						fee = f.Int64()
-					} else if f, ok := unpacked[0].(uint32); ok {
-						fee = int64(f)
-					}
-				}
-			}
-		}
-	}
-
-	// 查询 tickLower
-	var tickLower int32
-	if tickLowerMethod, ok := parsedABI.Methods["tickLower"]; ok {
-		data, err := parsedABI.Pack("tickLower")
-		if err == nil {
-			result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-				To:   &poolAddr,
-				Data: data,
-			}, nil)
-			if err == nil {
-				unpacked, err := tickLowerMethod.Outputs.Unpack(result)
-				if err == nil && len(unpacked) > 0 {
-					if t, ok := unpacked[0].(int32); ok {
-						tickLower = t
-					} else if t, ok := unpacked[0].(*big.Int); ok {
-						tickLower = int32(t.Int64())
-					}
-				}
-			}
-		}
-	}
-
-	// 查询 tickUpper
-	var tickUpper int32
-	if tickUpperMethod, ok := parsedABI.Methods["tickUpper"]; ok {
-		data, err := parsedABI.Pack("tickUpper")
-		if err == nil {
-			result, err := s.Client.CallContract(ctx, ethereum.CallMsg{
-				To:   &poolAddr,
-				Data: data,
-			}, nil)
-			if err == nil {
-				unpacked, err := tickUpperMethod.Outputs.Unpack(result)
-				if err == nil && len(unpacked) > 0 {
-					if t, ok := unpacked[0].(int32); ok {
-						tickUpper = t
-					} else if t, ok := unpacked[0].(*big.Int); ok {
-						tickUpper = int32(t.Int64())
-					}
-				}
-			}
-		}
-	}
+	// 查询池子在交易对中的 index（Pool 合约本身不保存 index，需要通过 Factory.getPool 反查）
+	poolIndex := s.queryPoolIndexFromChain(token0, token1, poolAddr, blockNumber)
 
 	// 确保代币存在
-	s.ensureToken(token0)
-	s.ensureToken(token1)
+	s.ensureTokens(blockNumber, token0, token1)
 
 	// 插入池记录
-	_, err = s.DB.Exec(`
-		INSERT INTO pools (address, token0, token1, fee, tick_lower, tick_upper, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	_, err = s.db().Exec(`
+		INSERT INTO pools (address, token0, token1, pool_index, fee, tick_lower, tick_upper, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (address) DO NOTHING
-	`, poolAddr.Hex(), token0.Hex(), token1.Hex(), fee, tickLower, tickUpper, time.Now())
+	`, poolAddr.Hex(), token0.Hex(), token1.Hex(), poolIndex, fee, tickLower, tickUpper, time.Now())
 
 	if err != nil {
 		log.Printf("Error creating pool from chain: %v", err)
@@ -1030,7 +1075,7 @@ func (s *Scanner) createPoolFromChain(poolAddr common.Address) bool {
 		poolAddr.Hex(), token0.Hex(), token1.Hex(), fee)
 
 	// 更新池的完整状态
-	s.updatePoolStateFromChain(poolAddr)
+	s.updatePoolStateFromChainAt(poolAddr, blockNumber)
 
 	return true
 }